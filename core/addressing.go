@@ -1,10 +1,9 @@
 package core
 
-import "fmt"
-
 func (c *CPU) direct() uint16 {
 	ea := uint16(c.dp.get())<<8 | uint16(c.read(c.pc.uint16()))
 	c.pc.inc()
+	c.deadCycles(1)
 	return ea
 }
 
@@ -20,15 +19,23 @@ func (c *CPU) limmediate() uint16 {
 	return ea
 }
 
+func (c *CPU) qimmediate() uint16 {
+	ea := c.pc.uint16()
+	c.pc.inc().inc().inc().inc()
+	return ea
+}
+
 func (c *CPU) extended() uint16 {
 	ea := c.readw(c.pc.uint16())
 	c.pc.inc().inc()
+	c.deadCycles(1)
 	return ea
 }
 
 func (c *CPU) relative() uint16 {
 	offset := int8(c.read(c.pc.uint16()))
 	c.pc.inc()
+	c.deadCycles(1)
 	address := c.pc.uint16()
 	if offset < 0 {
 		address -= uint16(-offset)
@@ -41,6 +48,7 @@ func (c *CPU) relative() uint16 {
 func (c *CPU) lrelative() uint16 {
 	offset := int16(c.readw(c.pc.uint16()))
 	c.pc.inc().inc()
+	c.deadCycles(1)
 	address := c.pc.uint16()
 	if offset < 0 {
 		address -= uint16(-offset)
@@ -53,10 +61,17 @@ func (c *CPU) lrelative() uint16 {
 func (c *CPU) indexed() uint16 {
 	postbyte := c.read(c.pc.uint16())
 	c.pc.inc()
+	if postbyte&0x9d == 0x90 && c.Faults != EmulateSilicon && !(c.variant == HD6309 && postbyte&0x1f == 0x10) { // ,R+ and ,-R have no indirect mode
+		fault(InvalidPostbyte, "undefined indirect indexed postbyte %02x", postbyte)
+	}
+	clock, bus := c.clock, c.bus
 	ea := c.getIndexedAddress(postbyte)
+	// The extra cycles of the mode which are not operand fetches are dead cycles
+	c.deadCycles(int(c.clock-clock) - int(c.bus-bus) + 1)
 	if postbyte&0x90 == 0x90 { // Indirect mode?
 		c.clock += 3
 		ea = uint16(c.readw(ea))
+		c.deadCycles(1)
 	}
 	return ea
 }
@@ -73,7 +88,8 @@ func (c *CPU) readIndexedRegister(postbyte uint8) uint16 {
 	case 3:
 		return c.s.uint16()
 	default:
-		panic(fmt.Sprintf("Undefined indexed addressing mode register code %d at pc=%x", code, c.pc))
+		fault(InvalidPostbyte, "undefined indexed addressing mode register code %d", code)
+		return 0
 	}
 }
 
@@ -81,20 +97,25 @@ func (c *CPU) writeIndexedRegister(postbyte uint8, value uint16) {
 	code := (postbyte & 0x60) >> 5
 	switch code {
 	case 0:
-		c.x.set(value)
+		c.x.set(int(value))
 	case 1:
-		c.y.set(value)
+		c.y.set(int(value))
 	case 2:
-		c.u.set(value)
+		c.u.set(int(value))
 	case 3:
-		c.s.set(value)
+		c.s.set(int(value))
 	default:
-		panic(fmt.Sprintf("Undefined indexed addressing mode register code %d at pc=%x", code, c.pc))
+		fault(InvalidPostbyte, "undefined indexed addressing mode register code %d", code)
 	}
 }
 
 func (c *CPU) getIndexedAddress(postbyte uint8) uint16 {
 	var address uint16
+	if c.variant == HD6309 && postbyte&0x80 != 0 {
+		if ea, ok := c.getHD6309IndexedAddress(postbyte); ok {
+			return ea
+		}
+	}
 	if postbyte&0x80 == 0 {
 		/* idx5off - 5 bits offset from Register */
 		address = c.readIndexedRegister(postbyte)
@@ -208,7 +229,17 @@ func (c *CPU) getIndexedAddress(postbyte uint8) uint16 {
 		c.pc.inc().inc()
 		c.clock += 2
 	} else {
-		panic(fmt.Sprintf("Undefined indexed submode code %d at pc=%x", postbyte, c.pc))
+		if c.Faults != EmulateSilicon {
+			fault(InvalidPostbyte, "undefined indexed postbyte %02x", postbyte)
+		}
+		if postbyte&0x0f == 0x0a {
+			/* PC with low byte forced to $ff */
+			address = c.pc.uint16() | 0x00ff
+			c.clock++
+		} else {
+			/* Nothing is driven on the address bus */
+			address = 0xffff
+		}
 	}
 	return address
 }
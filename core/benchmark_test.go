@@ -0,0 +1,94 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Loop mixing the common addressing modes
+//
+//	1000: LDX #$2000
+//	1003: LDA ,X+
+//	1005: ADDA #$01
+//	1007: STA -1,X
+//	1009: PSHS D
+//	100b: PULS D
+//	100d: CMPX #$3000
+//	1010: BNE $1003
+//	1012: BRA $1000
+var benchmarkProgram = []uint8{
+	0x8e, 0x20, 0x00,
+	0xa6, 0x80,
+	0x8b, 0x01,
+	0xa7, 0x1f,
+	0x34, 0x06,
+	0x35, 0x06,
+	0x8c, 0x30, 0x00,
+	0x26, 0xf1,
+	0x20, 0xec,
+}
+
+func newBenchmarkCPU(variant Variant) *CPU {
+	cpu := NewCPU(NewRam(), variant)
+	for i, b := range benchmarkProgram {
+		cpu.write(0x1000+uint16(i), b)
+	}
+	cpu.pc.set(0x1000)
+	cpu.s.set(0x8000)
+	return cpu
+}
+
+/** Run the benchmark program and report the emulated clock frequency */
+func benchmarkRun(b *testing.B, cpu *CPU) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if _, err := cpu.RunCycles(1000000); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(cpu.Clock())/time.Since(start).Seconds()/1e6, "MHz")
+}
+
+func BenchmarkMC6809(b *testing.B) {
+	benchmarkRun(b, newBenchmarkCPU(MC6809))
+}
+
+func BenchmarkHD6309(b *testing.B) {
+	benchmarkRun(b, newBenchmarkCPU(HD6309))
+}
+
+func BenchmarkDecodeCache(b *testing.B) {
+	cpu := newBenchmarkCPU(MC6809)
+	cpu.SetDecodeCache(true)
+	benchmarkRun(b, cpu)
+}
+
+func BenchmarkCycleExact(b *testing.B) {
+	cpu := newBenchmarkCPU(MC6809)
+	cpu.CycleExact = true
+	benchmarkRun(b, cpu)
+}
+
+func BenchmarkStep(b *testing.B) {
+	cpu := newBenchmarkCPU(MC6809)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cpu.Step()
+	}
+}
+
+var _ = Describe("Interpreter", func() {
+
+	It("should not allocate memory when executing instructions", func() {
+		cpu := newBenchmarkCPU(MC6809)
+		cpu.Step()
+		Expect(testing.AllocsPerRun(1000, func() { cpu.step() })).To(BeZero())
+		Expect(testing.AllocsPerRun(1000, func() { cpu.Step() })).To(BeZero())
+	})
+})
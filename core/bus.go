@@ -0,0 +1,356 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Handler serves the accesses to the addresses mapped on a Bus. The address is
+// the offset from the start of the mapping.
+type Handler interface {
+	Read(offset uint16) uint8
+	Write(offset uint16, value uint8)
+}
+
+// CycleHandler is implemented by the handlers which need to know the cycle of the
+// accesses. It is used instead of Handler when the CPU runs in cycle exact mode.
+type CycleHandler interface {
+	Handler
+	ReadCycle(cycle uint64, offset uint16) uint8
+	WriteCycle(cycle uint64, offset uint16, value uint8)
+}
+
+// Sizer is implemented by the handlers backed by a fixed amount of storage. The
+// mappings of such handlers are checked to fit in the storage.
+type Sizer interface {
+	Size() int
+}
+
+// Mapping attaches a Handler to a range of addresses of a Bus
+type Mapping struct {
+	/// First address
+	From uint16
+	/// Last address, included
+	To uint16
+	/// Mask applied to the offset, for the regions mirrored in a larger range. 0
+	/// means no mirroring.
+	Mask uint16
+	/// The mapping with the highest priority serves an address mapped several
+	/// times. The last mapping wins between equal priorities.
+	Priority int
+	/// Handler serving the accesses
+	Handler Handler
+	/// Handler tracking its changes, nil if the handler does not implement
+	/// ChangeTracker
+	tracker ChangeTracker
+}
+
+// Bus is a Memory decoding the addresses to the handlers of the mapped regions:
+// RAM, ROM, mirrored regions or memory mapped devices. A read at an unmapped
+// address returns the last value driven on the data bus (open bus) and a write is
+// ignored.
+type Bus struct {
+	/// Mappings in the order they have been added
+	mappings []*Mapping
+	/// Index in mappings plus one of the mapping serving each address, 0 if unmapped
+	decoder [0x10000]uint16
+	/// Last value driven on the data bus
+	data uint8
+	/// Stamp of the last change of the mappings
+	remapped uint64
+}
+
+// NewBus returns a bus with no mapped region
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Map attaches a handler to a range of addresses
+func (b *Bus) Map(m Mapping) error {
+	if m.Handler == nil {
+		return errors.New("bus: mapping without handler")
+	}
+	if m.To < m.From {
+		return fmt.Errorf("bus: invalid range $%04x-$%04x", m.From, m.To)
+	}
+	if m.Mask == 0 {
+		m.Mask = 0xffff
+	}
+	if s, ok := m.Handler.(Sizer); ok && int(m.largestOffset()) >= s.Size() {
+		return fmt.Errorf("bus: range $%04x-$%04x exceeds the %d bytes of the handler", m.From, m.To, s.Size())
+	}
+	m.tracker, _ = m.Handler.(ChangeTracker)
+	b.mappings = append(b.mappings, &m)
+	b.decode()
+	return nil
+}
+
+/** Largest offset passed to the handler */
+func (m *Mapping) largestOffset() uint16 {
+	if m.To-m.From < m.Mask {
+		return m.To - m.From
+	}
+	return m.Mask
+}
+
+// Unmap detaches all the mappings of a handler
+func (b *Bus) Unmap(h Handler) {
+	mappings := b.mappings[:0]
+	for _, m := range b.mappings {
+		if m.Handler != h {
+			mappings = append(mappings, m)
+		}
+	}
+	b.mappings = mappings
+	b.decode()
+}
+
+/** Rebuild the address decoder */
+func (b *Bus) decode() {
+	order := make([]int, len(b.mappings))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return b.mappings[order[i]].Priority < b.mappings[order[j]].Priority
+	})
+	b.remapped = changeNow()
+	b.decoder = [0x10000]uint16{}
+	for _, i := range order {
+		m := b.mappings[i]
+		for a := int(m.From); a <= int(m.To); a++ {
+			b.decoder[a] = uint16(i + 1)
+		}
+	}
+}
+
+/** Mapping serving an address, nil if unmapped */
+func (b *Bus) mapping(address uint16) *Mapping {
+	i := b.decoder[address]
+	if i == 0 {
+		return nil
+	}
+	return b.mappings[i-1]
+}
+
+func (b *Bus) Read(address uint16) uint8 {
+	if m := b.mapping(address); m != nil {
+		b.data = m.Handler.Read((address - m.From) & m.Mask)
+	}
+	return b.data
+}
+
+func (b *Bus) Write(address uint16, value uint8) {
+	b.data = value
+	if m := b.mapping(address); m != nil {
+		m.Handler.Write((address-m.From)&m.Mask, value)
+	}
+}
+
+// Changed returns the stamp of the last change of the page containing an address:
+// change of the mappings or of the content of the handler. The content of the
+// handlers which do not implement ChangeTracker and of the unmapped addresses is
+// considered to change at any time.
+func (b *Bus) Changed(address uint16) uint64 {
+	m := b.mapping(address)
+	if m == nil || m.tracker == nil {
+		return alwaysChanged
+	}
+	return latest(b.remapped, m.tracker.Changed((address-m.From)&m.Mask))
+}
+
+/** Latest of two change stamps */
+func latest(a uint64, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (b *Bus) Readw(address uint16) uint16 {
+	hi := b.Read(address)
+	lo := b.Read(address + 1)
+	return uint16(hi)<<8 | uint16(lo)
+}
+
+func (b *Bus) Writew(address uint16, value uint16) {
+	b.Write(address, uint8(value>>8))
+	b.Write(address+1, uint8(value&0xff))
+}
+
+// ReadCycle reads an address in cycle exact mode
+func (b *Bus) ReadCycle(cycle uint64, address uint16) uint8 {
+	if m := b.mapping(address); m != nil {
+		if h, ok := m.Handler.(CycleHandler); ok {
+			b.data = h.ReadCycle(cycle, (address-m.From)&m.Mask)
+		} else {
+			b.data = m.Handler.Read((address - m.From) & m.Mask)
+		}
+	}
+	return b.data
+}
+
+// WriteCycle writes an address in cycle exact mode
+func (b *Bus) WriteCycle(cycle uint64, address uint16, value uint8) {
+	b.data = value
+	if m := b.mapping(address); m != nil {
+		if h, ok := m.Handler.(CycleHandler); ok {
+			h.WriteCycle(cycle, (address-m.From)&m.Mask, value)
+		} else {
+			m.Handler.Write((address-m.From)&m.Mask, value)
+		}
+	}
+}
+
+// Peek reads the RAM, ROM and banked regions without side effects. The devices
+// are not read since reading them may have side effects.
+func (b *Bus) Peek(address uint16) (uint8, bool) {
+	m := b.mapping(address)
+	if m == nil {
+		return 0, false
+	}
+	switch h := m.Handler.(type) {
+	case *RAM, *ROM, *Banked:
+		return h.Read((address - m.From) & m.Mask), true
+	}
+	return 0, false
+}
+
+// Dump prints the content of the RAM, ROM and banked regions. The other addresses
+// are displayed as "--".
+func (b *Bus) Dump() {
+	DumpRange(os.Stdout, b, 0x0000, 0xffff)
+}
+
+// RAM is a read/write memory region stored in copy-on-write pages
+type RAM struct {
+	*Pages
+}
+
+// NewRAM returns a RAM region of the given size
+func NewRAM(size int) *RAM {
+	return &RAM{NewPages(size)}
+}
+
+func (r *RAM) Read(offset uint16) uint8 {
+	return r.Get(int(offset))
+}
+
+func (r *RAM) Write(offset uint16, value uint8) {
+	r.Set(int(offset), value)
+}
+
+// ROM is a read only memory region, the writes are ignored
+type ROM struct {
+	Data []uint8
+}
+
+// NewROM returns a ROM region holding the given content
+func NewROM(data []uint8) *ROM {
+	return &ROM{Data: data}
+}
+
+func (r *ROM) Read(offset uint16) uint8 {
+	return r.Data[offset]
+}
+
+func (r *ROM) Write(offset uint16, value uint8) {
+}
+
+// Size returns the size of the region
+func (r *ROM) Size() int {
+	return len(r.Data)
+}
+
+// Changed returns 0: the content of a ROM does not change
+func (r *ROM) Changed(offset uint16) uint64 {
+	return 0
+}
+
+// HandlerFuncs is a Handler calling the functions which are set, for memory
+// mapped devices. Reads return 0xff and writes are ignored when the function is
+// not set.
+type HandlerFuncs struct {
+	ReadFunc  func(offset uint16) uint8
+	WriteFunc func(offset uint16, value uint8)
+}
+
+func (h *HandlerFuncs) Read(offset uint16) uint8 {
+	if h.ReadFunc == nil {
+		return 0xff
+	}
+	return h.ReadFunc(offset)
+}
+
+func (h *HandlerFuncs) Write(offset uint16, value uint8) {
+	if h.WriteFunc != nil {
+		h.WriteFunc(offset, value)
+	}
+}
+
+// Banked is a region showing one of several banks of RAM of the same size. The
+// active bank is selected by a device signal, the other banks stay available to
+// the debuggers and the devices through Bank.
+type Banked struct {
+	/// Banks of the region
+	banks []*RAM
+	/// Index of the bank seen by the CPU
+	active int
+	/// Stamp of the last bank switch
+	selected uint64
+}
+
+// NewBanked returns a region of count banks of the given size, the first bank
+// being active
+func NewBanked(count int, size int) *Banked {
+	b := &Banked{banks: make([]*RAM, count)}
+	for i := range b.banks {
+		b.banks[i] = NewRAM(size)
+	}
+	return b
+}
+
+// Select switches the bank seen by the CPU
+func (b *Banked) Select(bank int) {
+	if bank < 0 || bank >= len(b.banks) {
+		panic(fmt.Sprintf("bus: bank %d out of %d banks", bank, len(b.banks)))
+	}
+	b.active = bank
+	b.selected = changeNow()
+}
+
+// Active returns the index of the bank seen by the CPU
+func (b *Banked) Active() int {
+	return b.active
+}
+
+// Banks returns the number of banks
+func (b *Banked) Banks() int {
+	return len(b.banks)
+}
+
+// Bank returns a bank whether it is active or not
+func (b *Banked) Bank(bank int) *RAM {
+	return b.banks[bank]
+}
+
+func (b *Banked) Read(offset uint16) uint8 {
+	return b.banks[b.active].Get(int(offset))
+}
+
+func (b *Banked) Write(offset uint16, value uint8) {
+	b.banks[b.active].Set(int(offset), value)
+}
+
+// Size returns the size of a bank
+func (b *Banked) Size() int {
+	return b.banks[0].Size()
+}
+
+// Changed returns the stamp of the last bank switch or change of the active bank
+// in the page containing an offset
+func (b *Banked) Changed(offset uint16) uint64 {
+	return latest(b.selected, b.banks[b.active].Changed(offset))
+}
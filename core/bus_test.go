@@ -0,0 +1,165 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bus", func() {
+	var (
+		bus *Bus
+		ram *RAM
+	)
+
+	BeforeEach(func() {
+		bus = NewBus()
+		ram = NewRAM(0x8000)
+		Expect(bus.Map(Mapping{From: 0x0000, To: 0x7fff, Handler: ram})).To(Succeed())
+	})
+
+	It("should read and write the RAM", func() {
+		bus.Write(0x1234, 0x5a)
+		bus.Writew(0x2000, 0xcafe)
+
+		Expect(bus.Read(0x1234)).To(BeEquivalentTo(0x5a))
+		Expect(bus.Readw(0x2000)).To(BeEquivalentTo(0xcafe))
+		Expect(ram.Get(0x2001)).To(BeEquivalentTo(0xfe))
+	})
+
+	It("should pass the offset in the region to the handler", func() {
+		rom := NewROM([]uint8{0x12, 0x34})
+		Expect(bus.Map(Mapping{From: 0xfffe, To: 0xffff, Handler: rom})).To(Succeed())
+
+		Expect(bus.Readw(0xfffe)).To(BeEquivalentTo(0x1234))
+	})
+
+	It("should ignore the writes to the ROM", func() {
+		Expect(bus.Map(Mapping{From: 0xe000, To: 0xe001, Handler: NewROM([]uint8{0x12, 0x34})})).To(Succeed())
+		bus.Write(0xe000, 0xff)
+
+		Expect(bus.Read(0xe000)).To(BeEquivalentTo(0x12))
+	})
+
+	It("should mirror a region", func() {
+		Expect(bus.Map(Mapping{From: 0x8000, To: 0x8fff, Mask: 0x00ff, Handler: NewRAM(0x100)})).To(Succeed())
+		bus.Write(0x8010, 0x42)
+
+		Expect(bus.Read(0x8110)).To(BeEquivalentTo(0x42))
+		Expect(bus.Read(0x8f10)).To(BeEquivalentTo(0x42))
+	})
+
+	It("should serve an address by the mapping with the highest priority", func() {
+		io := &HandlerFuncs{ReadFunc: func(offset uint16) uint8 { return 0xa0 + uint8(offset) }}
+		Expect(bus.Map(Mapping{From: 0x1000, To: 0x1003, Priority: 1, Handler: io})).To(Succeed())
+		Expect(bus.Map(Mapping{From: 0x0000, To: 0x1fff, Handler: NewRAM(0x2000)})).To(Succeed())
+
+		Expect(bus.Read(0x1002)).To(BeEquivalentTo(0xa2))
+		bus.Write(0x1004, 0x55)
+		Expect(ram.Get(0x1004)).To(BeEquivalentTo(0x00)) // the last mapping wins between equal priorities
+	})
+
+	It("should reveal the lower mappings when a handler is unmapped", func() {
+		io := &HandlerFuncs{ReadFunc: func(offset uint16) uint8 { return 0xa5 }}
+		Expect(bus.Map(Mapping{From: 0x1000, To: 0x1003, Priority: 1, Handler: io})).To(Succeed())
+		ram.Set(0x1000, 0x42)
+		bus.Unmap(io)
+
+		Expect(bus.Read(0x1000)).To(BeEquivalentTo(0x42))
+	})
+
+	It("should call the device on reads and writes", func() {
+		written := []uint8{}
+		reads := 0
+		io := &HandlerFuncs{
+			ReadFunc:  func(offset uint16) uint8 { reads++; return 0 },
+			WriteFunc: func(offset uint16, value uint8) { written = append(written, value) },
+		}
+		Expect(bus.Map(Mapping{From: 0xe7c0, To: 0xe7c3, Handler: io})).To(Succeed())
+		bus.Read(0xe7c1)
+		bus.Write(0xe7c2, 0x11)
+
+		Expect(reads).To(Equal(1))
+		Expect(written).To(Equal([]uint8{0x11}))
+	})
+
+	It("should read the last value of the data bus at unmapped addresses", func() {
+		bus.Write(0x0010, 0x3c)
+		Expect(bus.Read(0x9000)).To(BeEquivalentTo(0x3c))
+
+		bus.Read(0x0010)
+		bus.Write(0x9000, 0x77)
+		Expect(bus.Read(0x9000)).To(BeEquivalentTo(0x77))
+	})
+
+	It("should reject invalid mappings", func() {
+		Expect(bus.Map(Mapping{From: 0x2000, To: 0x1000, Handler: ram})).NotTo(Succeed())
+		Expect(bus.Map(Mapping{From: 0x8000, To: 0x80ff, Handler: NewROM(make([]uint8, 0x80))})).NotTo(Succeed())
+		Expect(bus.Map(Mapping{From: 0x8000, To: 0x80ff})).NotTo(Succeed())
+		Expect(bus.Map(Mapping{From: 0x0000, To: 0x10ff, Mask: 0x0fff, Handler: NewRAM(0x100)})).NotTo(Succeed())
+	})
+
+	It("should access the active bank", func() {
+		banked := NewBanked(2, 0x100)
+		Expect(bus.Map(Mapping{From: 0x8000, To: 0x80ff, Handler: banked})).To(Succeed())
+		bus.Write(0x8000, 0x01)
+		banked.Select(1)
+		bus.Write(0x8000, 0x02)
+
+		Expect(bus.Read(0x8000)).To(BeEquivalentTo(0x02))
+		Expect(banked.Bank(0).Get(0)).To(BeEquivalentTo(0x01))
+		Expect(func() { banked.Select(2) }).To(Panic())
+	})
+
+	It("should run the CPU", func() {
+		rom := NewROM([]uint8{
+			0x86, 0x2f, // LDA #$2f
+			0xb7, 0xf0, 0x00, // STA $f000
+			0xb7, 0x10, 0x00, // STA $1000
+			0x20, 0xfe, // BRA *
+		})
+		Expect(bus.Map(Mapping{From: 0xf000, To: 0xf009, Handler: rom})).To(Succeed())
+		cpu := NewCPU(bus, MC6809)
+		cpu.pc.set(0xf000)
+		for i := 0; i < 3; i++ {
+			cpu.step()
+		}
+
+		Expect(bus.Read(0xf000)).To(BeEquivalentTo(0x86))
+		Expect(bus.Read(0x1000)).To(BeEquivalentTo(0x2f))
+	})
+
+	It("should pass the cycle to the cycle handlers", func() {
+		device := &timedDevice{}
+		Expect(bus.Map(Mapping{From: 0xe7c0, To: 0xe7c3, Handler: device})).To(Succeed())
+		bus.Write(0x1000, 0xb6) // LDA $e7c1
+		bus.Writew(0x1001, 0xe7c1)
+		cpu := NewCPU(bus, MC6809)
+		cpu.CycleExact = true
+		cpu.pc.set(0x1000)
+		cpu.clock = 1000
+		cpu.step()
+
+		Expect(device.cycles).To(Equal([]uint64{1004}))
+	})
+})
+
+/** Device recording the cycle of its accesses */
+type timedDevice struct {
+	cycles []uint64
+}
+
+func (d *timedDevice) Read(offset uint16) uint8 {
+	return 0
+}
+
+func (d *timedDevice) Write(offset uint16, value uint8) {
+}
+
+func (d *timedDevice) ReadCycle(cycle uint64, offset uint16) uint8 {
+	d.cycles = append(d.cycles, cycle)
+	return 0
+}
+
+func (d *timedDevice) WriteCycle(cycle uint64, offset uint16, value uint8) {
+	d.cycles = append(d.cycles, cycle)
+}
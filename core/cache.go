@@ -0,0 +1,178 @@
+package core
+
+import "sync/atomic"
+
+/* Decoded instruction cache
+ *
+ * The instructions are decoded once and kept per address with their operands
+ * resolved: the effective address of the immediate, extended and relative modes
+ * is a constant of the decoded instruction and the direct mode only adds the
+ * direct page register. The indexed modes are still decoded when the instruction
+ * is executed.
+ *
+ * The memory tracks the changes of its content per page of 256 bytes: every
+ * change is stamped with the current value of a counter which is advanced each
+ * time an instruction is decoded. A decoded instruction is dropped when one of its
+ * pages has been stamped since it was decoded, whether the change comes from the
+ * CPU, a device, a bank switch, a fill or a restore.
+ *
+ * The cache is bypassed when the memory accesses are observed (Step, hooks and
+ * cycle exact mode) since the opcode and operand fetches are not performed.
+ */
+
+// ChangeTracker is implemented by the memories and the handlers which track the
+// changes of their content. Changed returns the stamp of the last change of the
+// page of 256 bytes containing an address: write, bank switch, fill or restore.
+type ChangeTracker interface {
+	Changed(address uint16) uint64
+}
+
+/** Counter stamping the changes of the memories, advanced when an instruction is
+ * decoded (accessed atomically) */
+var changeStamp uint64
+
+/** Stamp of a change made now */
+func changeNow() uint64 {
+	return atomic.LoadUint64(&changeStamp)
+}
+
+/** Stamp of the content which may change at any time: devices and open bus */
+const alwaysChanged = ^uint64(0)
+
+/** Maximum size of an instruction, page prefix included */
+const maxInstructionSize = 5
+
+// A decoded instruction
+type decoded struct {
+	/// Opcode including the page prefix
+	code int
+	/// Opcode definition
+	op opcode
+	/// Size of the instruction, page prefix included
+	size uint16
+	/// Execution of the instruction, the PC pointing at the operands
+	f func()
+	/// Stamp of the decoding, greater than the stamps of the changes before it
+	stamp uint64
+}
+
+// Decoded instruction cache
+type decodeCache struct {
+	/// Decoded instructions per address
+	entries [0x10000]*decoded
+}
+
+// SetDecodeCache enables or disables the decoded instruction cache. Instructions
+// are decoded once and executed from the cache afterwards, which speeds up the
+// emulation of loops. The number of cycles and the effects of the instructions are
+// the same as without the cache.
+//
+// The cache is only used when the memory of the CPU implements ChangeTracker, as
+// the memories of NewRam and the Bus do, so that any change of the program memory
+// drops the decoded instructions.
+func (c *CPU) SetDecodeCache(enabled bool) {
+	if !enabled {
+		c.cache = nil
+	} else if c.cache == nil {
+		c.cache = new(decodeCache)
+	}
+}
+
+// InvalidateDecodeCache drops all the decoded instructions
+func (c *CPU) InvalidateDecodeCache() {
+	if c.cache != nil {
+		c.cache.entries = [0x10000]*decoded{}
+	}
+}
+
+/** The pages of a decoded instruction have not changed since it was decoded */
+func (c *CPU) unchanged(pc uint16, d *decoded) bool {
+	if c.tracker.Changed(pc) >= d.stamp {
+		return false
+	}
+	last := pc + d.size - 1
+	return last>>pageBits == pc>>pageBits || c.tracker.Changed(last) < d.stamp
+}
+
+/** The cache is used when the opcode and operand fetches are not observed and the
+ * memory tracks its changes */
+func (c *CPU) cacheable() bool {
+	return c.cache != nil && c.tracker != nil && c.hooks == nil && !c.recording && !c.CycleExact
+}
+
+/** Decode the instruction at pc and keep it in the cache */
+func (c *CPU) decode(pc uint16, code int, op opcode) *decoded {
+	operand := pc + 1
+	if code > 0xff {
+		operand++
+	}
+	d := &decoded{code: code, op: op, f: op.f, stamp: atomic.AddUint64(&changeStamp, 1)}
+	d.size = operand - pc
+	switch op.mode {
+	case inherent:
+	case immediate, transfer, stack:
+		d.f = c.resolve(op.exec, operand, operand+1)
+		d.size++
+	case limmediate:
+		d.f = c.resolve(op.exec, operand, operand+2)
+		d.size += 2
+	case qimmediate:
+		d.f = c.resolve(op.exec, operand, operand+4)
+		d.size += 4
+	case extended:
+		d.f = c.resolve(op.exec, c.ram.Readw(operand), operand+2)
+		d.size += 2
+	case relative:
+		next := operand + 1
+		d.f = c.resolve(op.exec, next+uint16(int8(c.ram.Read(operand))), next)
+		d.size++
+	case lrelative:
+		next := operand + 2
+		d.f = c.resolve(op.exec, next+c.ram.Readw(operand), next)
+		d.size += 2
+	case direct:
+		if exec, ok := op.exec.(func(*CPU, uint16)); ok {
+			low := c.ram.Read(operand)
+			next := int(operand + 1)
+			d.f = func() {
+				c.pc.set(next)
+				ea := uint16(c.dp.get())<<8 | uint16(low)
+				c.ea = int(ea)
+				exec(c, ea)
+			}
+		}
+		d.size++
+	default:
+		// Indexed and HD6309 modes are decoded at execution: the size is unknown
+		d.size = maxInstructionSize
+	}
+	if d.f == nil {
+		d.f = op.f
+	}
+	c.cache.entries[pc] = d
+	return d
+}
+
+/** Execution of an instruction with a constant effective address */
+func (c *CPU) resolve(implementation interface{}, ea uint16, next uint16) func() {
+	exec, ok := implementation.(func(*CPU, uint16))
+	if !ok {
+		return nil
+	}
+	pc := int(next)
+	return func() {
+		c.pc.set(pc)
+		c.ea = int(ea)
+		exec(c, ea)
+	}
+}
+
+/** Execute a decoded instruction */
+func (c *CPU) execute(pc uint16, d *decoded) {
+	c.op = d.code
+	c.pc.set(int(pc) + 1)
+	if d.code > 0xff {
+		c.pc.inc()
+	}
+	d.f()
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Decoded instruction cache", func() {
+	var (
+		cpu *CPU
+	)
+
+	load := func(cpu *CPU, address uint16, bytes ...uint8) {
+		for i, b := range bytes {
+			cpu.write(address+uint16(i), b)
+		}
+	}
+
+	// Loop modifying the operand of its first instruction
+	//
+	//	1000: LDA #$00
+	//	1002: INCA
+	//	1003: STA $1001
+	//	1006: BRA $1000
+	selfModifying := []uint8{0x86, 0x00, 0x4c, 0xb7, 0x10, 0x01, 0x20, 0xf8}
+
+	BeforeEach(func() {
+		cpu = NewCPU(NewRam(), MC6809)
+		cpu.SetDecodeCache(true)
+		cpu.pc.set(0x1000)
+		cpu.s.set(0x8000)
+	})
+
+	It("should match the interpreter cycle for cycle", func() {
+		for _, program := range [][]uint8{benchmarkProgram, selfModifying} {
+			reference := NewCPU(NewRam(), MC6809)
+			cached := NewCPU(NewRam(), MC6809)
+			cached.SetDecodeCache(true)
+			for _, c := range []*CPU{reference, cached} {
+				load(c, 0x1000, program...)
+				c.pc.set(0x1000)
+				c.s.set(0x8000)
+			}
+			for i := 0; i < 20000; i++ {
+				reference.step()
+				cached.step()
+				Expect(cached.Registers()).To(Equal(reference.Registers()))
+				Expect(cached.Clock()).To(Equal(reference.Clock()))
+			}
+		}
+	})
+
+	It("should execute the instructions modified by the CPU", func() {
+		load(cpu, 0x1000, selfModifying...)
+		for i := 0; i < 4*3; i++ {
+			cpu.step()
+		}
+
+		ExpectA(*cpu, 0x03)
+		ExpectMemory(*cpu, 0x1001, 0x03)
+	})
+
+	It("should execute the instructions modified by another device", func() {
+		load(cpu, 0x1000, 0x7e, 0x10, 0x10) // JMP $1010
+		load(cpu, 0x1010, 0x7e, 0x10, 0x00) // JMP $1000
+		cpu.step()
+		cpu.step()
+		cpu.ram.Write(0x1002, 0x20) // JMP $1020
+		cpu.step()
+
+		ExpectPC(*cpu, 0x1020)
+	})
+
+	It("should execute the instructions of the bank switched under the cached code", func() {
+		bus := NewBus()
+		banked := NewBanked(2, 0x100)
+		Expect(bus.Map(Mapping{From: 0x1000, To: 0x10ff, Handler: banked})).To(Succeed())
+		banked.Bank(0).Load(0, []uint8{0x86, 0x01, 0x20, 0xfc}) // LDA #$01; BRA $1000
+		banked.Bank(1).Load(0, []uint8{0x86, 0x02, 0x20, 0xfc}) // LDA #$02; BRA $1000
+		cpu = NewCPU(bus, MC6809)
+		cpu.SetDecodeCache(true)
+		cpu.pc.set(0x1000)
+		for i := 0; i < 4; i++ {
+			cpu.step()
+		}
+		ExpectA(*cpu, 0x01)
+
+		banked.Select(1)
+		cpu.step()
+		ExpectA(*cpu, 0x02)
+	})
+
+	It("should drop the decoded instructions when the memory is replaced", func() {
+		other := NewRam()
+		other.Write(0x1000, 0x4a) // DECA
+		load(cpu, 0x1000, 0x4c)   // INCA
+		cpu.step()
+		cpu.Initialize(other)
+		cpu.pc.set(0x1000)
+		cpu.step()
+
+		ExpectA(*cpu, 0xff)
+	})
+
+	It("should fetch the instructions when the memory accesses are observed", func() {
+		load(cpu, 0x1000, 0x86, 0x2f, 0x20, 0xfc) // LDA #$2f; BRA $1000
+		cpu.step()
+		cpu.step()
+		result, _ := cpu.Step()
+
+		Expect(result.Accesses).To(Equal([]Access{
+			{PC: 0x1000, Opcode: -1, Address: 0x1000, Value: 0x86, Cycle: 5},
+			{PC: 0x1000, Opcode: 0x86, Address: 0x1001, Value: 0x2f, Cycle: 5},
+		}))
+	})
+
+	It("should be disabled", func() {
+		cpu.SetDecodeCache(false)
+		Expect(cpu.cache).To(BeNil())
+	})
+})
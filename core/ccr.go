@@ -17,6 +17,10 @@ func (cc *ccr) setE() {
 	*cc.r8.r |= entire
 }
 
+func (cc *ccr) clearE() {
+	*cc.r8.r &= 0xff ^ entire
+}
+
 func (cc *ccr) getH() bool {
 	return *cc.r8.r&halfCarry == halfCarry
 }
@@ -85,6 +89,10 @@ func (cc *ccr) setF() {
 	*cc.r8.r |= firqmask
 }
 
+func (cc *ccr) clearF() {
+	*cc.r8.r &= 0xff ^ firqmask
+}
+
 func (cc *ccr) getI() bool {
 	return *cc.r8.r&irqmask == irqmask
 }
@@ -93,6 +101,10 @@ func (cc *ccr) setI() {
 	*cc.r8.r |= irqmask
 }
 
+func (cc *ccr) clearI() {
+	*cc.r8.r &= 0xff ^ irqmask
+}
+
 func (c *CPU) updateNZVC(a, b, r int) {
 	c.updateZ(r)
 	c.updateN(r)
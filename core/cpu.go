@@ -1,17 +1,50 @@
 package core
 
-import "fmt"
-
 type opcode struct {
 	name   string
 	f      func()
 	cycles uint64
 	mode   addressMode
+	native uint64      // cycles in HD6309 native mode, 0 when they are the same
+	exec   interface{} // implementation from the instruction specification
+}
+
+/** Opcode dispatch table: page 0, page 1 ($10 prefix) and page 2 ($11 prefix) */
+type opcodeTable [3][256]opcode
+
+/** Index of the page of an opcode, -1 if the prefix is not a page prefix */
+func opcodePage(code int) int {
+	switch code >> 8 {
+	case 0x00:
+		return 0
+	case 0x10:
+		return 1
+	case 0x11:
+		return 2
+	default:
+		return -1
+	}
 }
 
-var (
-	opcodes map[int]opcode
-)
+/** Look up an opcode, ok is false if it is not defined */
+func (t *opcodeTable) lookup(code int) (op opcode, ok bool) {
+	page := opcodePage(code)
+	if page < 0 {
+		return opcode{}, false
+	}
+	op = t[page][code&0xff]
+	return op, op.f != nil
+}
+
+/** Opcode definition, the zero opcode if it is not defined */
+func (t *opcodeTable) get(code int) opcode {
+	op, _ := t.lookup(code)
+	return op
+}
+
+func (t *opcodeTable) set(code int, op opcode) {
+	t[opcodePage(code)][code&0xff] = op
+}
 
 const (
 	carry     = 1 << iota // 0x01
@@ -38,6 +71,28 @@ const (
 	lrelative  addressMode = 5
 	extended   addressMode = 6
 	indexed    addressMode = 7
+	// HD6309 addressing modes
+	qimmediate    addressMode = 8  // 32-bit immediate
+	imdirect      addressMode = 9  // immediate value and direct address
+	imindexed     addressMode = 10 // immediate value and indexed address
+	imextended    addressMode = 11 // immediate value and extended address
+	bitdirect     addressMode = 12 // bit manipulation postbyte and direct address
+	interregister addressMode = 13 // register to register postbyte
+	blockmove     addressMode = 14 // TFM postbyte
+	// Immediate postbyte modes
+	transfer addressMode = 15 // TFR and EXG register pair
+	stack    addressMode = 16 // PSHS, PULS, PSHU and PULU register list
+)
+
+/* Execution states */
+
+type cpuState int
+
+const (
+	running cpuState = 0
+	syncing cpuState = 1 // SYNC: waiting for an interrupt line to be asserted
+	waiting cpuState = 2 // CWAI: entire state is stacked, waiting for an interrupt
+	halted  cpuState = 3 // HCF: the CPU is locked until the next reset
 )
 
 // CPU structure
@@ -60,8 +115,60 @@ type CPU struct {
 	cc ccr
 	/// Program counter register
 	pc r16
+	/// Accumulator register (HD6309)
+	e r8
+	/// Accumulator register (HD6309)
+	f r8
+	/// Value register, preserved by reset (HD6309)
+	v r16
+	/// Mode and error register (HD6309)
+	md r8
+	/// CPU model
+	variant Variant
 	/// Memory
 	ram Memory
+	/// Memory told the cycle of each access, nil if the memory does not implement CycleMemory
+	timed CycleMemory
+	/// Memory tracking the changes of its content, nil if the memory does not implement ChangeTracker
+	tracker ChangeTracker
+	/// Opcodes dispatch table
+	opcodes *opcodeTable
+	/// Undocumented opcodes dispatch table
+	undocumented *opcodeTable
+	/// Interrupt lines
+	lines interruptLines
+	/// Execution state
+	state cpuState
+	/// Behaviour of the CPU when an undefined opcode is fetched
+	IllegalOpcodes IllegalOpcodePolicy
+	/// Behaviour of the CPU when an instruction has an undefined behaviour
+	Faults FaultPolicy
+	/// Opcode of the last executed instruction, -1 if none
+	op int
+	/// Address of the current instruction
+	instruction uint16
+	/// Memory accesses of the current instruction are recorded
+	recording bool
+	/// Memory accesses of the current instruction
+	accesses []Access
+	/// Execution hooks, nil if none is registered
+	hooks []Hook
+	/// Devices ticked by the CPU
+	devices []Device
+	/// Clock of the next tick of the devices
+	next uint64
+	/// Effective address of the current instruction, -1 if none
+	ea int
+	/// Decoded instruction cache, nil if disabled
+	cache *decodeCache
+	/// Stop has been requested (accessed atomically)
+	stop int32
+	/// Every bus cycle is issued through the memory, dead cycles included
+	CycleExact bool
+	/// Cycle of the next bus cycle in cycle exact mode
+	bus uint64
+	/// Address of the previous bus cycle if it was a read, -1 otherwise
+	lastRead int
 	///
 	clock uint64
 }
@@ -70,14 +177,35 @@ func (c *CPU) d() uint16 {
 	return uint16(c.a.get()<<8 | c.b.get())
 }
 
+func (c *CPU) setD(value uint16) {
+	c.a.set(int(value >> 8))
+	c.b.set(int(value & 0xff))
+}
+
 // Initialize the Cpu
 func (c *CPU) Initialize(ram Memory) {
-	c.ram = ram
-	c.Reset()
+	c.setMemory(ram)
+	c.clear()
 	c.initOpcodes()
+	if c.variant == HD6309 {
+		c.initHD6309Opcodes()
+	} else {
+		c.initUndocumentedOpcodes()
+	}
 }
 
-func (c *CPU) Reset() {
+/** Connect the memory and the interfaces it implements. The instructions decoded
+ * from the previous memory are dropped: the change stamps of another memory do not
+ * tell whether they are still valid. */
+func (c *CPU) setMemory(ram Memory) {
+	c.ram = ram
+	c.timed, _ = ram.(CycleMemory)
+	c.tracker, _ = ram.(ChangeTracker)
+	c.InvalidateDecodeCache()
+}
+
+/** Clear all the registers and the clock */
+func (c *CPU) clear() {
 	c.a = r8{n: "A", r: new(int)}
 	c.b = r8{n: "B", r: new(int)}
 	c.x = r16{n: "X", r: new(int)}
@@ -87,314 +215,173 @@ func (c *CPU) Reset() {
 	c.dp = r8{n: "DP", r: new(int)}
 	c.cc = ccr{r8{n: "CC", r: new(int)}}
 	c.pc = r16{n: "PC", r: new(int)}
+	c.e = r8{n: "E", r: new(int)}
+	c.f = r8{n: "F", r: new(int)}
+	c.v = r16{n: "V", r: new(int)}
+	c.md = r8{n: "MD", r: new(int)}
+	c.lines = interruptLines{}
+	c.state = running
 	c.clock = 0
+	c.bus = 0
+	c.next = 0
+}
+
+// PowerOn performs a cold start: the registers and the clock are cleared before
+// running the reset sequence. The devices restart from the cleared clock.
+func (c *CPU) PowerOn() {
+	c.clear()
+	c.setDeviceClocks()
+	c.Reset()
+}
+
+// Reset runs the hardware reset sequence (warm reset): interrupts are masked, the
+// direct page is cleared, NMI is disarmed and the program counter is loaded from
+// the reset vector. The HD6309 is switched back to emulation mode. The other
+// registers and the clock keep their values.
+func (c *CPU) Reset() {
+	c.dp.set(0)
+	c.md.set(0)
+	c.cc.setF()
+	c.cc.setI()
+	c.lines.resetLatch = false
+	c.lines.nmiLatch = false
+	c.lines.nmiArmed = false
+	c.state = running
+	c.pc.set(int(c.readw(vectorReset)))
 }
 
 func (c *CPU) initOpcodes() {
-	opcodes = make(map[int]opcode)
-	// Page 0
-	opcodes[0x00] = opcode{"NEG", func() { c.neg(c.direct()) }, 6, direct}
-	opcodes[0x03] = opcode{"COM", func() { c.com(c.direct()) }, 6, direct}
-	opcodes[0x04] = opcode{"LSR", func() { c.lsr(c.direct()) }, 6, direct}
-	opcodes[0x06] = opcode{"ROR", func() { c.ror(c.direct()) }, 6, direct}
-	opcodes[0x07] = opcode{"ASR", func() { c.asr(c.direct()) }, 6, direct}
-	opcodes[0x08] = opcode{"ASL", func() { c.asl(c.direct()) }, 6, direct}
-	opcodes[0x09] = opcode{"ROL", func() { c.rol(c.direct()) }, 6, direct}
-	opcodes[0x0a] = opcode{"DEC", func() { c.dec(c.direct()) }, 6, direct}
-	opcodes[0x0c] = opcode{"INC", func() { c.inc(c.direct()) }, 6, direct}
-	opcodes[0x0d] = opcode{"TST", func() { c.tst(c.direct()) }, 6, direct}
-	opcodes[0x0e] = opcode{"JMP", func() { c.jmp(c.direct()) }, 3, direct}
-	opcodes[0x0f] = opcode{"CLR", func() { c.clr(c.direct()) }, 6, direct}
-	opcodes[0x12] = opcode{"NOP", func() { c.nop() }, 2, inherent}
-	opcodes[0x13] = opcode{"SYNC", func() { c.sync() }, 4, inherent}
-	opcodes[0x16] = opcode{"BRA", func() { c.bra(c.lrelative()) }, 5, lrelative}
-	opcodes[0x17] = opcode{"BSR", func() { c.bsr(c.lrelative()) }, 9, lrelative}
-	opcodes[0x19] = opcode{"DAA", func() { c.daa() }, 2, inherent}
-	opcodes[0x1a] = opcode{"ORCC", func() { c.orcc(c.immediate()) }, 3, immediate}
-	opcodes[0x1c] = opcode{"ANDCC", func() { c.andcc(c.immediate()) }, 3, immediate}
-	opcodes[0x1d] = opcode{"SEX", func() { c.sex() }, 2, inherent}
-	opcodes[0x1e] = opcode{"EXG", func() { c.exg(c.immediate()) }, 8, immediate}
-	opcodes[0x1f] = opcode{"TFR", func() { c.tfr(c.immediate()) }, 6, immediate}
-	opcodes[0x20] = opcode{"BRA", func() { c.bra(c.relative()) }, 3, relative}
-	opcodes[0x21] = opcode{"BRN", func() { c.brn(c.relative()) }, 3, relative}
-	opcodes[0x22] = opcode{"BHI", func() { c.bhi(c.relative()) }, 3, relative}
-	opcodes[0x23] = opcode{"BLS", func() { c.bls(c.relative()) }, 3, relative}
-	opcodes[0x24] = opcode{"BCC", func() { c.bcc(c.relative()) }, 3, relative}
-	opcodes[0x25] = opcode{"BLO", func() { c.blo(c.relative()) }, 3, relative}
-	opcodes[0x26] = opcode{"BNE", func() { c.bne(c.relative()) }, 3, relative}
-	opcodes[0x27] = opcode{"BEQ", func() { c.beq(c.relative()) }, 3, relative}
-	opcodes[0x28] = opcode{"BVC", func() { c.bvc(c.relative()) }, 3, relative}
-	opcodes[0x29] = opcode{"BVS", func() { c.bvs(c.relative()) }, 3, relative}
-	opcodes[0x2a] = opcode{"BPL", func() { c.bpl(c.relative()) }, 3, relative}
-	opcodes[0x2b] = opcode{"BMI", func() { c.bmi(c.relative()) }, 3, relative}
-	opcodes[0x2c] = opcode{"BGE", func() { c.bge(c.relative()) }, 3, relative}
-	opcodes[0x2d] = opcode{"BLT", func() { c.blt(c.relative()) }, 3, relative}
-	opcodes[0x2e] = opcode{"BGT", func() { c.bgt(c.relative()) }, 3, relative}
-	opcodes[0x2f] = opcode{"BLE", func() { c.ble(c.relative()) }, 3, relative}
-	opcodes[0x30] = opcode{"LEAX", func() { c.leax(c.indexed()) }, 4, indexed}
-	opcodes[0x31] = opcode{"LEAY", func() { c.leay(c.indexed()) }, 4, indexed}
-	opcodes[0x32] = opcode{"LEAS", func() { c.leas(c.indexed()) }, 4, indexed}
-	opcodes[0x33] = opcode{"LEAU", func() { c.leau(c.indexed()) }, 4, indexed}
-	opcodes[0x34] = opcode{"PSHS", func() { c.pshs(c.immediate()) }, 5, immediate}
-	opcodes[0x35] = opcode{"PULS", func() { c.puls(c.immediate()) }, 5, immediate}
-	opcodes[0x36] = opcode{"PSHU", func() { c.pshu(c.immediate()) }, 5, immediate}
-	opcodes[0x37] = opcode{"PULU", func() { c.pulu(c.immediate()) }, 5, immediate}
-	opcodes[0x39] = opcode{"RTS", func() { c.rts() }, 5, inherent}
-	opcodes[0x3a] = opcode{"ABX", func() { c.abx() }, 3, inherent}
-	opcodes[0x3b] = opcode{"RTI", func() { c.rti() }, 3, inherent}
-	opcodes[0x3d] = opcode{"MUL", func() { c.mul() }, 11, inherent}
-	opcodes[0x3f] = opcode{"SWI", func() { c.swi() }, 7, inherent} // SWI is 19 cycles but part of clock increment is done in PushRegister function
-	opcodes[0x40] = opcode{"NEGA", func() { c.nega() }, 2, inherent}
-	opcodes[0x43] = opcode{"COMA", func() { c.coma() }, 2, inherent}
-	opcodes[0x44] = opcode{"LSRA", func() { c.lsra() }, 2, inherent}
-	opcodes[0x46] = opcode{"RORA", func() { c.rora() }, 2, inherent}
-	opcodes[0x47] = opcode{"ASRA", func() { c.asra() }, 2, inherent}
-	opcodes[0x48] = opcode{"ASLA", func() { c.asla() }, 2, inherent}
-	opcodes[0x49] = opcode{"ROLA", func() { c.rola() }, 2, inherent}
-	opcodes[0x4a] = opcode{"DECA", func() { c.deca() }, 2, inherent}
-	opcodes[0x4c] = opcode{"INCA", func() { c.inca() }, 2, inherent}
-	opcodes[0x4d] = opcode{"TSTA", func() { c.tsta() }, 2, inherent}
-	opcodes[0x4f] = opcode{"CLRA", func() { c.clra() }, 2, inherent}
-	opcodes[0x50] = opcode{"NEGB", func() { c.negb() }, 2, inherent}
-	opcodes[0x53] = opcode{"COMB", func() { c.comb() }, 2, inherent}
-	opcodes[0x54] = opcode{"LSRB", func() { c.lsrb() }, 2, inherent}
-	opcodes[0x56] = opcode{"RORB", func() { c.rorb() }, 2, inherent}
-	opcodes[0x57] = opcode{"ASRB", func() { c.asrb() }, 2, inherent}
-	opcodes[0x58] = opcode{"ASLB", func() { c.aslb() }, 2, inherent}
-	opcodes[0x59] = opcode{"ROLB", func() { c.rolb() }, 2, inherent}
-	opcodes[0x5a] = opcode{"DECB", func() { c.decb() }, 2, inherent}
-	opcodes[0x5c] = opcode{"INCB", func() { c.incb() }, 2, inherent}
-	opcodes[0x5d] = opcode{"TSTB", func() { c.tstb() }, 2, inherent}
-	opcodes[0x5f] = opcode{"CLRB", func() { c.clrb() }, 2, inherent}
-	opcodes[0x60] = opcode{"NEG", func() { c.neg(c.indexed()) }, 6, indexed}
-	opcodes[0x63] = opcode{"COM", func() { c.com(c.indexed()) }, 6, indexed}
-	opcodes[0x64] = opcode{"LSR", func() { c.lsr(c.indexed()) }, 6, indexed}
-	opcodes[0x66] = opcode{"ROR", func() { c.ror(c.indexed()) }, 6, indexed}
-	opcodes[0x67] = opcode{"ASR", func() { c.asr(c.indexed()) }, 6, indexed}
-	opcodes[0x68] = opcode{"ASL", func() { c.asl(c.indexed()) }, 6, indexed}
-	opcodes[0x69] = opcode{"ROL", func() { c.rol(c.indexed()) }, 6, indexed}
-	opcodes[0x6a] = opcode{"DEC", func() { c.dec(c.indexed()) }, 6, indexed}
-	opcodes[0x6c] = opcode{"INC", func() { c.inc(c.indexed()) }, 6, indexed}
-	opcodes[0x6d] = opcode{"TST", func() { c.tst(c.indexed()) }, 6, indexed}
-	opcodes[0x6e] = opcode{"JMP", func() { c.jmp(c.indexed()) }, 3, indexed}
-	opcodes[0x6f] = opcode{"CLR", func() { c.clr(c.indexed()) }, 6, indexed}
-	opcodes[0x70] = opcode{"NEG", func() { c.neg(c.extended()) }, 7, extended}
-	opcodes[0x73] = opcode{"COM", func() { c.com(c.extended()) }, 7, extended}
-	opcodes[0x74] = opcode{"LSR", func() { c.lsr(c.extended()) }, 7, extended}
-	opcodes[0x76] = opcode{"ROR", func() { c.ror(c.extended()) }, 7, extended}
-	opcodes[0x77] = opcode{"ASR", func() { c.asr(c.extended()) }, 7, extended}
-	opcodes[0x78] = opcode{"ASL", func() { c.asl(c.extended()) }, 7, extended}
-	opcodes[0x79] = opcode{"ROL", func() { c.rol(c.extended()) }, 7, extended}
-	opcodes[0x7a] = opcode{"DEC", func() { c.dec(c.extended()) }, 7, extended}
-	opcodes[0x7c] = opcode{"INC", func() { c.inc(c.extended()) }, 7, extended}
-	opcodes[0x7d] = opcode{"TST", func() { c.tst(c.extended()) }, 7, extended}
-	opcodes[0x7e] = opcode{"JMP", func() { c.jmp(c.extended()) }, 4, extended}
-	opcodes[0x7f] = opcode{"CLR", func() { c.clr(c.extended()) }, 7, extended}
-	opcodes[0x80] = opcode{"SUBA", func() { c.suba(c.immediate()) }, 2, immediate}
-	opcodes[0x81] = opcode{"CMPA", func() { c.cmpa(c.immediate()) }, 2, immediate}
-	opcodes[0x82] = opcode{"SBCA", func() { c.sbca(c.immediate()) }, 2, immediate}
-	opcodes[0x83] = opcode{"SUBD", func() { c.subd(c.limmediate()) }, 4, limmediate}
-	opcodes[0x84] = opcode{"ANDA", func() { c.anda(c.immediate()) }, 2, immediate}
-	opcodes[0x85] = opcode{"BITA", func() { c.bita(c.immediate()) }, 2, immediate}
-	opcodes[0x86] = opcode{"LDA", func() { c.lda(c.immediate()) }, 2, immediate}
-	opcodes[0x88] = opcode{"EORA", func() { c.eora(c.immediate()) }, 2, immediate}
-	opcodes[0x89] = opcode{"ADCA", func() { c.adca(c.immediate()) }, 2, immediate}
-	opcodes[0x8a] = opcode{"ORA", func() { c.ora(c.immediate()) }, 2, immediate}
-	opcodes[0x8b] = opcode{"ADDA", func() { c.adda(c.immediate()) }, 2, immediate}
-	opcodes[0x8c] = opcode{"CMPX", func() { c.cmpx(c.limmediate()) }, 4, limmediate}
-	opcodes[0x8d] = opcode{"BSR", func() { c.bsr(c.relative()) }, 7, relative}
-	opcodes[0x8e] = opcode{"LDX", func() { c.ldx(c.limmediate()) }, 3, limmediate}
-	opcodes[0x90] = opcode{"SUBA", func() { c.suba(c.direct()) }, 4, direct}
-	opcodes[0x91] = opcode{"CMPA", func() { c.cmpa(c.direct()) }, 4, direct}
-	opcodes[0x92] = opcode{"SBCA", func() { c.sbca(c.direct()) }, 4, direct}
-	opcodes[0x93] = opcode{"SUBD", func() { c.subd(c.direct()) }, 6, direct}
-	opcodes[0x94] = opcode{"ANDA", func() { c.anda(c.direct()) }, 4, direct}
-	opcodes[0x95] = opcode{"BITA", func() { c.bita(c.direct()) }, 4, direct}
-	opcodes[0x96] = opcode{"LDA", func() { c.lda(c.direct()) }, 4, direct}
-	opcodes[0x97] = opcode{"STA", func() { c.sta(c.direct()) }, 4, direct}
-	opcodes[0x98] = opcode{"EORA", func() { c.eora(c.direct()) }, 4, direct}
-	opcodes[0x99] = opcode{"ADCA", func() { c.adca(c.direct()) }, 4, direct}
-	opcodes[0x9a] = opcode{"ORA", func() { c.ora(c.direct()) }, 4, direct}
-	opcodes[0x9b] = opcode{"ADDA", func() { c.adda(c.direct()) }, 4, direct}
-	opcodes[0x9c] = opcode{"CMPX", func() { c.cmpx(c.direct()) }, 6, direct}
-	opcodes[0x9d] = opcode{"JSR", func() { c.jsr(c.direct()) }, 7, direct}
-	opcodes[0x9e] = opcode{"LDX", func() { c.ldx(c.direct()) }, 5, direct}
-	opcodes[0x9f] = opcode{"STX", func() { c.stx(c.direct()) }, 5, direct}
-	opcodes[0xa0] = opcode{"SUBA", func() { c.suba(c.indexed()) }, 4, indexed}
-	opcodes[0xa1] = opcode{"CMPA", func() { c.cmpa(c.indexed()) }, 4, indexed}
-	opcodes[0xa2] = opcode{"SBCA", func() { c.sbca(c.indexed()) }, 4, indexed}
-	opcodes[0xa3] = opcode{"SUBD", func() { c.subd(c.indexed()) }, 6, indexed}
-	opcodes[0xa4] = opcode{"ANDA", func() { c.anda(c.indexed()) }, 4, indexed}
-	opcodes[0xa5] = opcode{"BITA", func() { c.bita(c.indexed()) }, 4, indexed}
-	opcodes[0xa6] = opcode{"LDA", func() { c.lda(c.indexed()) }, 4, indexed}
-	opcodes[0xa7] = opcode{"STA", func() { c.sta(c.indexed()) }, 4, indexed}
-	opcodes[0xa8] = opcode{"EORA", func() { c.eora(c.indexed()) }, 4, indexed}
-	opcodes[0xa9] = opcode{"ADCA", func() { c.adca(c.indexed()) }, 4, indexed}
-	opcodes[0xaa] = opcode{"ORA", func() { c.ora(c.indexed()) }, 4, indexed}
-	opcodes[0xab] = opcode{"ADDA", func() { c.adda(c.indexed()) }, 4, indexed}
-	opcodes[0xac] = opcode{"CMPX", func() { c.cmpx(c.indexed()) }, 6, indexed}
-	opcodes[0xad] = opcode{"JSR", func() { c.jsr(c.indexed()) }, 7, indexed}
-	opcodes[0xae] = opcode{"LDX", func() { c.ldx(c.indexed()) }, 5, indexed}
-	opcodes[0xaf] = opcode{"STX", func() { c.stx(c.indexed()) }, 5, indexed}
-	opcodes[0xb0] = opcode{"SUBA", func() { c.suba(c.extended()) }, 5, extended}
-	opcodes[0xb1] = opcode{"CMPA", func() { c.cmpa(c.extended()) }, 5, extended}
-	opcodes[0xb2] = opcode{"SBCA", func() { c.sbca(c.extended()) }, 5, extended}
-	opcodes[0xb3] = opcode{"SUBD", func() { c.subd(c.extended()) }, 7, extended}
-	opcodes[0xb4] = opcode{"ANDA", func() { c.anda(c.extended()) }, 5, extended}
-	opcodes[0xb5] = opcode{"BITA", func() { c.bita(c.extended()) }, 5, extended}
-	opcodes[0xb6] = opcode{"LDA", func() { c.lda(c.extended()) }, 5, extended}
-	opcodes[0xb7] = opcode{"STA", func() { c.sta(c.extended()) }, 5, extended}
-	opcodes[0xb8] = opcode{"EORA", func() { c.eora(c.extended()) }, 5, extended}
-	opcodes[0xb9] = opcode{"ADCA", func() { c.adca(c.extended()) }, 5, extended}
-	opcodes[0xba] = opcode{"ORA", func() { c.ora(c.extended()) }, 5, extended}
-	opcodes[0xbb] = opcode{"ADDA", func() { c.adda(c.extended()) }, 5, extended}
-	opcodes[0xbc] = opcode{"CMPX", func() { c.cmpx(c.extended()) }, 7, extended}
-	opcodes[0xbd] = opcode{"JSR", func() { c.jsr(c.extended()) }, 8, extended}
-	opcodes[0xbe] = opcode{"LDX", func() { c.ldx(c.extended()) }, 6, extended}
-	opcodes[0xbf] = opcode{"STX", func() { c.stx(c.extended()) }, 6, extended}
-	opcodes[0xc0] = opcode{"SUBB", func() { c.subb(c.immediate()) }, 2, immediate}
-	opcodes[0xc1] = opcode{"CMPB", func() { c.cmpb(c.immediate()) }, 2, immediate}
-	opcodes[0xc2] = opcode{"SBCB", func() { c.sbcb(c.immediate()) }, 2, immediate}
-	opcodes[0xc3] = opcode{"ADDD", func() { c.addd(c.limmediate()) }, 4, limmediate}
-	opcodes[0xc4] = opcode{"ANDB", func() { c.andb(c.immediate()) }, 2, immediate}
-	opcodes[0xc5] = opcode{"BITB", func() { c.bitb(c.immediate()) }, 2, immediate}
-	opcodes[0xc6] = opcode{"LDB", func() { c.ldb(c.immediate()) }, 2, immediate}
-	opcodes[0xc8] = opcode{"EORB", func() { c.eorb(c.immediate()) }, 2, immediate}
-	opcodes[0xc9] = opcode{"ADCB", func() { c.adcb(c.immediate()) }, 2, immediate}
-	opcodes[0xca] = opcode{"ORB", func() { c.orb(c.immediate()) }, 2, immediate}
-	opcodes[0xcb] = opcode{"ADDB", func() { c.addb(c.immediate()) }, 2, immediate}
-	opcodes[0xcc] = opcode{"LDD", func() { c.ldd(c.limmediate()) }, 3, limmediate}
-	opcodes[0xce] = opcode{"LDU", func() { c.ldu(c.limmediate()) }, 3, limmediate}
-	opcodes[0xd0] = opcode{"SUBB", func() { c.subb(c.direct()) }, 4, direct}
-	opcodes[0xd1] = opcode{"CMPB", func() { c.cmpb(c.direct()) }, 4, direct}
-	opcodes[0xd2] = opcode{"SBCB", func() { c.sbcb(c.direct()) }, 4, direct}
-	opcodes[0xd3] = opcode{"ADDD", func() { c.addd(c.direct()) }, 6, direct}
-	opcodes[0xd4] = opcode{"ANDB", func() { c.andb(c.direct()) }, 4, direct}
-	opcodes[0xd5] = opcode{"BITB", func() { c.bitb(c.direct()) }, 4, direct}
-	opcodes[0xd6] = opcode{"LDB", func() { c.ldb(c.direct()) }, 4, direct}
-	opcodes[0xd7] = opcode{"STB", func() { c.stb(c.direct()) }, 4, direct}
-	opcodes[0xd8] = opcode{"EORB", func() { c.eorb(c.direct()) }, 4, direct}
-	opcodes[0xd9] = opcode{"ADCB", func() { c.adcb(c.direct()) }, 4, direct}
-	opcodes[0xda] = opcode{"ORB", func() { c.orb(c.direct()) }, 4, direct}
-	opcodes[0xdb] = opcode{"ADDB", func() { c.addb(c.direct()) }, 4, direct}
-	opcodes[0xdc] = opcode{"LDD", func() { c.ldd(c.direct()) }, 5, direct}
-	opcodes[0xdd] = opcode{"STD", func() { c.std(c.direct()) }, 5, direct}
-	opcodes[0xde] = opcode{"LDU", func() { c.ldu(c.direct()) }, 5, direct}
-	opcodes[0xdf] = opcode{"STU", func() { c.stu(c.direct()) }, 5, direct}
-	opcodes[0xe0] = opcode{"SUBB", func() { c.subb(c.indexed()) }, 4, indexed}
-	opcodes[0xe1] = opcode{"CMPB", func() { c.cmpb(c.indexed()) }, 4, indexed}
-	opcodes[0xe2] = opcode{"SBCB", func() { c.sbcb(c.indexed()) }, 4, indexed}
-	opcodes[0xe3] = opcode{"ADDD", func() { c.addd(c.indexed()) }, 6, indexed}
-	opcodes[0xe4] = opcode{"ANDB", func() { c.andb(c.indexed()) }, 4, indexed}
-	opcodes[0xe5] = opcode{"BITB", func() { c.bitb(c.indexed()) }, 4, indexed}
-	opcodes[0xe6] = opcode{"LDB", func() { c.ldb(c.indexed()) }, 4, indexed}
-	opcodes[0xe7] = opcode{"STB", func() { c.stb(c.indexed()) }, 4, indexed}
-	opcodes[0xe8] = opcode{"EORB", func() { c.eorb(c.indexed()) }, 4, indexed}
-	opcodes[0xe9] = opcode{"ADCB", func() { c.adcb(c.indexed()) }, 4, indexed}
-	opcodes[0xea] = opcode{"ORB", func() { c.orb(c.indexed()) }, 4, indexed}
-	opcodes[0xeb] = opcode{"ADDB", func() { c.addb(c.indexed()) }, 4, indexed}
-	opcodes[0xec] = opcode{"LDD", func() { c.ldd(c.indexed()) }, 5, indexed}
-	opcodes[0xed] = opcode{"STD", func() { c.std(c.indexed()) }, 5, indexed}
-	opcodes[0xee] = opcode{"LDU", func() { c.ldu(c.indexed()) }, 5, indexed}
-	opcodes[0xef] = opcode{"STU", func() { c.stu(c.indexed()) }, 5, indexed}
-	opcodes[0xf0] = opcode{"SUBB", func() { c.subb(c.extended()) }, 5, extended}
-	opcodes[0xf1] = opcode{"CMPB", func() { c.cmpb(c.extended()) }, 5, extended}
-	opcodes[0xf2] = opcode{"SBCB", func() { c.sbcb(c.extended()) }, 5, extended}
-	opcodes[0xf3] = opcode{"ADDD", func() { c.addd(c.extended()) }, 7, extended}
-	opcodes[0xf4] = opcode{"ANDB", func() { c.andb(c.extended()) }, 5, extended}
-	opcodes[0xf5] = opcode{"BITB", func() { c.bitb(c.extended()) }, 5, extended}
-	opcodes[0xf6] = opcode{"LDB", func() { c.ldb(c.extended()) }, 5, extended}
-	opcodes[0xf7] = opcode{"STB", func() { c.stb(c.extended()) }, 5, extended}
-	opcodes[0xf8] = opcode{"EORB", func() { c.eorb(c.extended()) }, 5, extended}
-	opcodes[0xf9] = opcode{"ADCB", func() { c.adcb(c.extended()) }, 5, extended}
-	opcodes[0xfa] = opcode{"ORB", func() { c.orb(c.extended()) }, 5, extended}
-	opcodes[0xfb] = opcode{"ADDB", func() { c.addb(c.extended()) }, 5, extended}
-	opcodes[0xfc] = opcode{"LDD", func() { c.ldd(c.extended()) }, 6, extended}
-	opcodes[0xfd] = opcode{"STD", func() { c.std(c.extended()) }, 6, extended}
-	opcodes[0xfe] = opcode{"LDU", func() { c.ldu(c.extended()) }, 6, extended}
-	opcodes[0xff] = opcode{"STU", func() { c.stu(c.extended()) }, 6, extended}
-	// Page 1
-	opcodes[0x1021] = opcode{"LBRN", func() { c.lbrn(c.lrelative()) }, 5, lrelative}
-	opcodes[0x1022] = opcode{"LBHI", func() { c.lbhi(c.lrelative()) }, 5, lrelative}
-	opcodes[0x1023] = opcode{"LBLS", func() { c.lbls(c.lrelative()) }, 5, lrelative}
-	opcodes[0x1024] = opcode{"LBCC", func() { c.lbcc(c.lrelative()) }, 5, lrelative}
-	opcodes[0x1025] = opcode{"LBCS", func() { c.lblo(c.lrelative()) }, 5, lrelative}
-	opcodes[0x1026] = opcode{"LBNE", func() { c.lbne(c.lrelative()) }, 5, lrelative}
-	opcodes[0x1027] = opcode{"LBEQ", func() { c.lbeq(c.lrelative()) }, 5, lrelative}
-	opcodes[0x1028] = opcode{"LBVC", func() { c.lbvc(c.lrelative()) }, 5, lrelative}
-	opcodes[0x1029] = opcode{"LBVS", func() { c.lbvs(c.lrelative()) }, 5, lrelative}
-	opcodes[0x102a] = opcode{"LBPL", func() { c.lbpl(c.lrelative()) }, 5, lrelative}
-	opcodes[0x102b] = opcode{"LBMI", func() { c.lbmi(c.lrelative()) }, 5, lrelative}
-	opcodes[0x102c] = opcode{"LBGE", func() { c.lbge(c.lrelative()) }, 5, lrelative}
-	opcodes[0x102d] = opcode{"LBLT", func() { c.lblt(c.lrelative()) }, 5, lrelative}
-	opcodes[0x102e] = opcode{"LBGT", func() { c.lbgt(c.lrelative()) }, 5, lrelative}
-	opcodes[0x102f] = opcode{"LBLE", func() { c.lble(c.lrelative()) }, 5, lrelative}
-	opcodes[0x103f] = opcode{"SWI2", func() { c.swi2() }, 8, inherent} // SWI2 is 20 cycles but part of clock increment is done in PushRegister function
-	opcodes[0x1083] = opcode{"CMPD", func() { c.cmpd(c.limmediate()) }, 5, limmediate}
-	opcodes[0x108c] = opcode{"CMPY", func() { c.cmpy(c.limmediate()) }, 5, limmediate}
-	opcodes[0x108e] = opcode{"LDY", func() { c.ldy(c.limmediate()) }, 4, limmediate}
-	opcodes[0x1093] = opcode{"CMPD", func() { c.cmpd(c.direct()) }, 7, direct}
-	opcodes[0x109c] = opcode{"CMPY", func() { c.cmpy(c.direct()) }, 7, direct}
-	opcodes[0x109e] = opcode{"LDY", func() { c.ldy(c.direct()) }, 6, direct}
-	opcodes[0x109f] = opcode{"STY", func() { c.sty(c.direct()) }, 6, direct}
-	opcodes[0x10a3] = opcode{"CMPD", func() { c.cmpd(c.indexed()) }, 7, indexed}
-	opcodes[0x10ac] = opcode{"CMPY", func() { c.cmpy(c.indexed()) }, 7, indexed}
-	opcodes[0x10ae] = opcode{"LDY", func() { c.ldy(c.indexed()) }, 6, indexed}
-	opcodes[0x10af] = opcode{"STY", func() { c.sty(c.indexed()) }, 6, indexed}
-	opcodes[0x10b3] = opcode{"CMPD", func() { c.cmpd(c.extended()) }, 8, extended}
-	opcodes[0x10bc] = opcode{"CMPY", func() { c.cmpy(c.extended()) }, 8, extended}
-	opcodes[0x10be] = opcode{"LDY", func() { c.ldy(c.extended()) }, 7, extended}
-	opcodes[0x10bf] = opcode{"STY", func() { c.sty(c.extended()) }, 7, extended}
-	opcodes[0x10ce] = opcode{"LDS", func() { c.lds(c.limmediate()) }, 4, limmediate}
-	opcodes[0x10de] = opcode{"LDS", func() { c.lds(c.direct()) }, 6, direct}
-	opcodes[0x10df] = opcode{"STS", func() { c.sts(c.direct()) }, 6, direct}
-	opcodes[0x10ee] = opcode{"LDS", func() { c.lds(c.indexed()) }, 6, indexed}
-	opcodes[0x10ef] = opcode{"STS", func() { c.sts(c.indexed()) }, 6, indexed}
-	opcodes[0x10fe] = opcode{"LDS", func() { c.lds(c.extended()) }, 7, extended}
-	opcodes[0x10ff] = opcode{"STS", func() { c.sts(c.extended()) }, 7, extended}
-	// Page 2
-	opcodes[0x113f] = opcode{"SWI3", func() { c.swi3() }, 8, inherent} // SWI3 is 20 cycles but part of clock increment is done in PushRegister function
-	opcodes[0x1183] = opcode{"CMPU", func() { c.cmpu(c.limmediate()) }, 5, limmediate}
-	opcodes[0x118c] = opcode{"CMPS", func() { c.cmps(c.limmediate()) }, 5, limmediate}
-	opcodes[0x1193] = opcode{"CMPU", func() { c.cmpu(c.direct()) }, 7, direct}
-	opcodes[0x119c] = opcode{"CMPS", func() { c.cmps(c.direct()) }, 7, direct}
-	opcodes[0x11a3] = opcode{"CMPU", func() { c.cmpu(c.indexed()) }, 7, indexed}
-	opcodes[0x11ac] = opcode{"CMPS", func() { c.cmps(c.direct()) }, 7, indexed}
-	opcodes[0x11a3] = opcode{"CMPU", func() { c.cmpu(c.extended()) }, 8, extended}
-	opcodes[0x11ac] = opcode{"CMPS", func() { c.cmps(c.extended()) }, 8, extended}
-}
-
-func (c *CPU) step() uint64 {
+	c.opcodes = new(opcodeTable)
+	for _, in := range mc6809Instructions {
+		c.opcodes.set(in.code, c.bind(in))
+	}
+}
+
+func (c *CPU) step() (cycles uint64, err error) {
+	start := c.clock
+	pc := c.pc.uint16()
+	c.instruction = pc
+	c.op = -1
+	c.ea = -1
+	c.bus = c.clock
+	c.lastRead = -1
+	defer func() {
+		if r := recover(); r != nil {
+			cycles, err = 0, c.recoverFault(pc, r)
+		} else if c.CycleExact && err == nil {
+			cycles += c.settle()
+		}
+	}()
+	if c.clock >= c.next {
+		c.tick()
+	}
+	if c.lines.reset {
+		c.clock++
+		return 1, nil
+	}
+	if c.lines.resetLatch {
+		c.Reset()
+		return 0, nil
+	}
+	if c.state == halted {
+		c.clock++
+		return 1, nil
+	}
+	if c.state == syncing {
+		if !c.lines.irq && !c.lines.firq && !c.lines.nmiLatch {
+			c.clock++
+			return 1, nil
+		}
+		c.state = running
+	}
+	if c.interrupt() {
+		c.state = running
+		return c.clock - start, nil
+	}
+	if c.state == waiting {
+		c.clock++
+		return 1, nil
+	}
+
+	pc = c.pc.uint16()
+	c.instruction = pc
+	if c.cacheable() {
+		if d := c.cache.entries[pc]; d != nil && c.unchanged(pc, d) {
+			c.execute(pc, d)
+			return c.cycles(d.op, start), nil
+		}
+	}
 	b := c.readInt(c.pc.uint16())
 	if b == 0x10 || b == 0x11 { // page 1 or page 2
 		c.pc.inc()
 		b = (b << 8) + c.readInt(c.pc.uint16())
 	}
-	opcode := opcodes[b]
-
-	instBuf := make([]uint8, 5)
-	instBuf[0] = c.read(c.pc.uint16())
-	instBuf[1] = c.read(c.pc.uint16() + 1)
-	instBuf[2] = c.read(c.pc.uint16() + 2)
-	instBuf[3] = c.read(c.pc.uint16() + 3)
-	instBuf[4] = c.read(c.pc.uint16() + 4)
-
-	// instr, len := Disassemble(opcode, instBuf)
-	// format(c.pc.uint16(), instr, instBuf[0:len])
-
-	/*
-		if c.Verbose {
-			Disassemble(opcode, instBuf)
+	opcode, ok := c.opcodes.lookup(b)
+	if !ok && c.variant == HD6309 {
+		c.pc.inc()
+		c.trap(mdIllegal)
+		return c.clock - start, nil
+	}
+	if !ok && c.IllegalOpcodes == EmulateUndocumented {
+		opcode, ok = c.undocumentedOpcode(b)
+	}
+	if !ok {
+		c.pc.set(int(pc))
+		return 0, c.illegalInstruction(pc, b)
+	}
+	c.op = b
+	if c.hooks != nil {
+		event := InstructionEvent{PC: pc, Opcode: b, Address: -1, Clock: start}
+		pending := c.stopped()
+		for _, h := range c.hooks {
+			h.BeforeInstruction(c, event)
+		}
+		cancelled := c.stopping()
+		if pending {
+			c.Stop()
 		}
-	*/
+		if cancelled {
+			// Stopped before the instruction: it is fetched again by the next step
+			c.pc.set(int(pc))
+			c.op = -1
+			c.bus = c.clock
+			return c.clock - start, nil
+		}
+	}
 
 	c.pc.inc()
+	if opcode.mode == inherent {
+		c.dummyRead(c.pc.uint16())
+	}
 
-	opcode.f()
-	c.clock += opcode.cycles
+	if c.cacheable() {
+		c.decode(pc, b, opcode).f()
+	} else {
+		opcode.f()
+	}
+	c.cycles(opcode, start)
 
-	return opcode.cycles
+	if c.hooks != nil {
+		if c.CycleExact {
+			c.settle()
+		}
+		event := InstructionEvent{PC: pc, Opcode: b, Address: c.ea, Clock: start, Cycles: c.clock - start}
+		for _, h := range c.hooks {
+			h.AfterInstruction(c, event)
+		}
+	}
+	return c.clock - start, nil
+
+}
 
+/** Count the cycles of an executed instruction and return the cycles of the step */
+func (c *CPU) cycles(op opcode, start uint64) uint64 {
+	if op.native != 0 && c.nativeMode() {
+		c.clock += op.native
+	} else {
+		c.clock += op.cycles
+	}
+	return c.clock - start
 }
 
 /***************************/
@@ -402,7 +389,14 @@ func (c *CPU) step() uint64 {
 /***************************/
 
 func (c *CPU) read(address uint16) uint8 {
-	return c.ram.Read(address)
+	if c.CycleExact {
+		return c.busRead(address, false)
+	}
+	value := c.ram.Read(address)
+	if c.recording || c.hooks != nil {
+		c.observe(Access{Address: address, Value: uint16(value)})
+	}
+	return value
 }
 
 func (c *CPU) readInt(address uint16) int {
@@ -410,27 +404,50 @@ func (c *CPU) readInt(address uint16) int {
 }
 
 func (c *CPU) readw(address uint16) uint16 {
-	return c.ram.Readw(address)
+	if c.CycleExact {
+		hi := c.busRead(address, false)
+		return uint16(hi)<<8 | uint16(c.busRead(address+1, false))
+	}
+	value := c.ram.Readw(address)
+	if c.recording || c.hooks != nil {
+		c.observe(Access{Address: address, Value: value, Word: true})
+	}
+	return value
 }
 
 func (c *CPU) readwInt(address uint16) int {
-	return int(c.ram.Readw(address))
+	return int(c.readw(address))
 }
 
 func (c *CPU) write(address uint16, value uint8) {
+	if c.CycleExact {
+		c.busWrite(address, value)
+		return
+	}
 	c.ram.Write(address, value)
+	if c.recording || c.hooks != nil {
+		c.observe(Access{Address: address, Value: uint16(value), Write: true})
+	}
 }
 
 func (c *CPU) writeInt(address uint16, value int) {
-	c.ram.Write(address, uint8(value))
+	c.write(address, uint8(value))
 }
 
 func (c *CPU) writew(address uint16, value uint16) {
+	if c.CycleExact {
+		c.busWrite(address, uint8(value>>8))
+		c.busWrite(address+1, uint8(value))
+		return
+	}
 	c.ram.Writew(address, value)
+	if c.recording || c.hooks != nil {
+		c.observe(Access{Address: address, Value: value, Write: true, Word: true})
+	}
 }
 
 func (c *CPU) writewInt(address uint16, value int) {
-	c.ram.Writew(address, uint16(value))
+	c.writew(address, uint16(value))
 }
 
 /** Negate - H?NxZxVxCx */
@@ -680,11 +697,14 @@ func (c *CPU) tstb() {
 
 /** Jump - NxZxV0 */
 func (c *CPU) jmp(address uint16) {
-	c.pc.set(address)
+	c.pc.set(int(address))
 }
 
 /** Clear N0Z1V0C0 */
 func (c *CPU) clr(address uint16) {
+	if c.CycleExact && !c.nativeMode() {
+		c.busRead(address, true) // the MC6809 reads the memory before clearing it
+	}
 	c.write(address, 0)
 	c.cc.clearN()
 	c.cc.setZ()
@@ -715,26 +735,35 @@ func (c *CPU) nop() {
 
 /** Synchronize to External Event */
 func (c *CPU) sync() {
-	// Not supported
+	c.state = syncing
+}
+
+/** Clear CC bits and Wait for Interrupt */
+func (c *CPU) cwai(address uint16) {
+	value := c.readInt(address)
+	c.cc.set(c.cc.get() & value)
+	c.pushEntireState()
+	c.state = waiting
 }
 
 /** (Long) Branch Always */
 func (c *CPU) bra(address uint16) {
-	c.pc.set(address)
+	c.pc.set(int(address))
 }
 
 /** Long Branch / Jump to Subroutine */
 func (c *CPU) bsr(address uint16) {
-	c.s.set(c.s.get() - 2)
-	c.writew(c.s.uint16(), c.pc.uint16())
-	c.pc.set(address)
+	c.pushWord(c.s, c.pc.uint16())
+	c.pc.set(int(address))
 }
 
-/** Jump to Subroutine */
+/** Jump to Subroutine: the first opcode of the subroutine is read and a dead cycle
+ * is spent before the return address is pushed */
 func (c *CPU) jsr(address uint16) {
-	c.s.set(c.s.get() - 2)
-	c.writew(c.s.uint16(), c.pc.uint16())
-	c.pc.set(address)
+	c.dummyRead(address)
+	c.deadCycles(1)
+	c.pushWord(c.s, c.pc.uint16())
+	c.pc.set(int(address))
 }
 
 /** Decimal Addition Adjust - NxZxV?Cx */
@@ -812,7 +841,13 @@ func (c *CPU) getRegisterFromCode(code int) uint16 {
 	case 11:
 		return c.dp.uint16()
 	default:
-		panic(fmt.Sprintf("Invalid register code: %d", code))
+		if c.variant == HD6309 {
+			return c.getHD6309RegisterFromCode(code)
+		}
+		if c.Faults != EmulateSilicon {
+			fault(InvalidRegister, "invalid register code %d", code)
+		}
+		return 0xffff // undefined registers read as all ones
 	}
 }
 
@@ -829,41 +864,70 @@ func (c *CPU) setRegisterFromCode(code int, value uint16) {
 		c.u.set(int(value))
 	case 4:
 		c.s.set(int(value))
+		c.armNMI()
 	case 5:
-		c.pc.set(value)
+		c.pc.set(int(value))
 	case 8:
 		c.a.set(int(value))
 	case 9:
 		c.b.set(int(value))
 	case 10:
-		c.cc.set(value)
+		c.cc.set(int(value))
 	case 11:
-		c.dp.set(value)
+		c.dp.set(int(value))
 	default:
-		panic(fmt.Sprintf("Invalid register code: %d", code))
+		if c.variant == HD6309 {
+			c.setHD6309RegisterFromCode(code, value)
+			return
+		}
+		if c.Faults != EmulateSilicon {
+			fault(InvalidRegister, "invalid register code %d", code)
+		}
+		// writes to undefined registers are lost
 	}
 }
 
+/** Convert a register value to the size of the target register the way the silicon does */
+func convertRegister(value uint16, from int, to int) uint16 {
+	if from&0x08 == to&0x08 {
+		return value
+	}
+	if to&0x08 != 0 { // 16-bit to 8-bit: low byte
+		return value & 0xff
+	}
+	return 0xff00 | value&0xff // 8-bit to 16-bit: high byte is $ff
+}
+
+/** Convert a register value, the HD6309 zero register reads as zero in both sizes */
+func (c *CPU) convertRegister(value uint16, from int, to int) uint16 {
+	if c.variant == HD6309 && from&0x0e == 0x0c {
+		return 0
+	}
+	return convertRegister(value, from, to)
+}
+
 /** Exchange Registers */
 func (c *CPU) exg(address uint16) {
 	code := c.readInt(address)
-	if ((code&0x80)>>7)^((code&0x08)>>3) == 1 {
-		panic("Try to exchange 8-bit with 16-bits registers")
+	r1, r2 := code>>4, code&0x0f
+	if (r1^r2)&0x08 != 0 && c.Faults != EmulateSilicon && c.variant != HD6309 {
+		fault(MixedRegisters, "exchange of 8-bit and 16-bit registers")
 	}
-	value1 := c.getRegisterFromCode(code >> 4)
-	value2 := c.getRegisterFromCode(code & 0x0f)
-	c.setRegisterFromCode(code>>4, value2)
-	c.setRegisterFromCode(code&0x0f, value1)
+	value1 := c.getRegisterFromCode(r1)
+	value2 := c.getRegisterFromCode(r2)
+	c.setRegisterFromCode(r1, c.convertRegister(value2, r2, r1))
+	c.setRegisterFromCode(r2, c.convertRegister(value1, r1, r2))
 }
 
 /** Transfer Register to Register */
 func (c *CPU) tfr(address uint16) {
 	code := c.readInt(address)
-	if ((code&0x80)>>7)^((code&0x08)>>3) == 1 {
-		panic("Try to transfer 8-bit and 16-bits registers")
+	r1, r2 := code>>4, code&0x0f
+	if (r1^r2)&0x08 != 0 && c.Faults != EmulateSilicon && c.variant != HD6309 {
+		fault(MixedRegisters, "transfer of 8-bit and 16-bit registers")
 	}
-	value := c.getRegisterFromCode(code >> 4)
-	c.setRegisterFromCode(code&0x0f, value)
+	value := c.getRegisterFromCode(r1)
+	c.setRegisterFromCode(r2, c.convertRegister(value, r1, r2))
 }
 
 /** Branch Never */
@@ -879,7 +943,7 @@ func (c *CPU) lbrn(address uint16) {
 /** Branch if Higher - Branch when Z = 0 && C = 0 */
 func (c *CPU) bhi(address uint16) {
 	if !c.cc.getC() && !c.cc.getZ() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -887,14 +951,14 @@ func (c *CPU) bhi(address uint16) {
 func (c *CPU) lbhi(address uint16) {
 	if !c.cc.getC() && !c.cc.getZ() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Lower or Same - Branch when Z = 1 || C = 1 */
 func (c *CPU) bls(address uint16) {
 	if c.cc.getC() || c.cc.getZ() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -902,14 +966,14 @@ func (c *CPU) bls(address uint16) {
 func (c *CPU) lbls(address uint16) {
 	if c.cc.getC() || c.cc.getZ() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Carry Clear - Branch when C = 0 */
 func (c *CPU) bcc(address uint16) {
 	if !c.cc.getC() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -917,14 +981,14 @@ func (c *CPU) bcc(address uint16) {
 func (c *CPU) lbcc(address uint16) {
 	if !c.cc.getC() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Lower - Branch when C = 1 */
 func (c *CPU) blo(address uint16) {
 	if c.cc.getC() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -932,14 +996,14 @@ func (c *CPU) blo(address uint16) {
 func (c *CPU) lblo(address uint16) {
 	if c.cc.getC() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Not Equal - Branch when Z = 0 */
 func (c *CPU) bne(address uint16) {
 	if !c.cc.getZ() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -947,14 +1011,14 @@ func (c *CPU) bne(address uint16) {
 func (c *CPU) lbne(address uint16) {
 	if !c.cc.getZ() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Equal - Branch when Z = 1 */
 func (c *CPU) beq(address uint16) {
 	if c.cc.getZ() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -962,14 +1026,14 @@ func (c *CPU) beq(address uint16) {
 func (c *CPU) lbeq(address uint16) {
 	if c.cc.getZ() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Overflow Clear - Branch when V = 0 */
 func (c *CPU) bvc(address uint16) {
 	if !c.cc.getV() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -977,14 +1041,14 @@ func (c *CPU) bvc(address uint16) {
 func (c *CPU) lbvc(address uint16) {
 	if !c.cc.getV() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Overflow Set - Branch when V = 1 */
 func (c *CPU) bvs(address uint16) {
 	if c.cc.getV() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -992,14 +1056,14 @@ func (c *CPU) bvs(address uint16) {
 func (c *CPU) lbvs(address uint16) {
 	if c.cc.getV() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Plus - Branch when N = 0 */
 func (c *CPU) bpl(address uint16) {
 	if !c.cc.getN() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -1007,14 +1071,14 @@ func (c *CPU) bpl(address uint16) {
 func (c *CPU) lbpl(address uint16) {
 	if !c.cc.getN() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Minus - Branch when N = 1 */
 func (c *CPU) bmi(address uint16) {
 	if c.cc.getN() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -1022,14 +1086,14 @@ func (c *CPU) bmi(address uint16) {
 func (c *CPU) lbmi(address uint16) {
 	if c.cc.getN() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Greater than or Equal to Zero - Branch when N ⊕ V = 0 */
 func (c *CPU) bge(address uint16) {
 	if c.cc.getN() == c.cc.getV() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -1037,14 +1101,14 @@ func (c *CPU) bge(address uint16) {
 func (c *CPU) lbge(address uint16) {
 	if c.cc.getN() == c.cc.getV() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Less than Zero - Branch when N ⊕ V = 1 */
 func (c *CPU) blt(address uint16) {
 	if c.cc.getN() != c.cc.getV() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -1052,14 +1116,14 @@ func (c *CPU) blt(address uint16) {
 func (c *CPU) lblt(address uint16) {
 	if c.cc.getN() != c.cc.getV() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Greater - Branch when Z = 0 && (N ⊕ V) = 0 */
 func (c *CPU) bgt(address uint16) {
 	if !c.cc.getZ() && c.cc.getN() == c.cc.getV() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -1067,14 +1131,14 @@ func (c *CPU) bgt(address uint16) {
 func (c *CPU) lbgt(address uint16) {
 	if !c.cc.getZ() && c.cc.getN() == c.cc.getV() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
 /** Branch on Less than or Equal to Zero - Branch when Z = 1 || (N ⊕ V) = 1 */
 func (c *CPU) ble(address uint16) {
 	if c.cc.getZ() || c.cc.getN() != c.cc.getV() {
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -1082,7 +1146,7 @@ func (c *CPU) ble(address uint16) {
 func (c *CPU) lble(address uint16) {
 	if c.cc.getZ() || c.cc.getN() != c.cc.getV() {
 		c.clock++
-		c.pc.set(address)
+		c.pc.set(int(address))
 	}
 }
 
@@ -1108,12 +1172,13 @@ func (c *CPU) leay(address uint16) {
 
 /** Load Effective Address into Register S */
 func (c *CPU) leas(address uint16) {
-	c.s.set(address)
+	c.s.set(int(address))
+	c.armNMI()
 }
 
 /** Load Effective Address into Register U */
 func (c *CPU) leau(address uint16) {
-	c.u.set(address)
+	c.u.set(int(address))
 }
 
 func isBitSet(value uint8, flag uint) bool {
@@ -1128,23 +1193,34 @@ func (c *CPU) pushRegister(value register, stack r16) {
 		c.writeInt(stack.uint16(), value.get())
 		c.clock++
 	} else if sz == 16 {
-		stack.dec().dec()
 		// fmt.Printf("Push %s(%02x) to %04x\n", value.name(), value.get(), stack.uint16())
-		c.writewInt(stack.uint16(), value.get())
+		c.pushWord(stack, uint16(value.get()))
 		c.clock += 2
 	} else {
 		// WTF
 	}
 }
 
+/** Push a word on a stack. In cycle exact mode the low byte is written first, as
+ * on the bus. */
+func (c *CPU) pushWord(stack r16, value uint16) {
+	stack.dec().dec()
+	if c.CycleExact {
+		c.write(stack.uint16()+1, uint8(value))
+		c.write(stack.uint16(), uint8(value>>8))
+		return
+	}
+	c.writew(stack.uint16(), value)
+}
+
 func (c *CPU) pullRegister(target register, stack r16) {
 	sz := target.size()
 	if sz == 8 {
-		target.set(uint8(c.read(stack.uint16())))
+		target.set(c.readInt(stack.uint16()))
 		stack.inc()
 		c.clock++
 	} else if sz == 16 {
-		target.set(uint16(c.readw(stack.uint16())))
+		target.set(c.readwInt(stack.uint16()))
 		stack.inc().inc()
 		c.clock += 2
 	} else {
@@ -1155,122 +1231,131 @@ func (c *CPU) pullRegister(target register, stack r16) {
 /** Push Registers on the Hardware Stack */
 func (c *CPU) pshs(address uint16) {
 	registers := uint8(c.read(address))
+	c.deadCycles(2)
+	c.dummyRead(c.s.uint16())
 	if isBitSet(registers, 7) {
-		c.pushRegister(c.pc, c.s)
+		c.pushRegister(&c.pc, c.s)
 	}
 	if isBitSet(registers, 6) {
-		c.pushRegister(c.u, c.s)
+		c.pushRegister(&c.u, c.s)
 	}
 	if isBitSet(registers, 5) {
-		c.pushRegister(c.y, c.s)
+		c.pushRegister(&c.y, c.s)
 	}
 	if isBitSet(registers, 4) {
-		c.pushRegister(c.x, c.s)
+		c.pushRegister(&c.x, c.s)
 	}
 	if isBitSet(registers, 3) {
-		c.pushRegister(c.dp, c.s)
+		c.pushRegister(&c.dp, c.s)
 	}
 	if isBitSet(registers, 2) {
-		c.pushRegister(c.b, c.s)
+		c.pushRegister(&c.b, c.s)
 	}
 	if isBitSet(registers, 1) {
-		c.pushRegister(c.a, c.s)
+		c.pushRegister(&c.a, c.s)
 	}
 	if isBitSet(registers, 0) {
-		c.pushRegister(c.cc, c.s)
+		c.pushRegister(&c.cc, c.s)
 	}
 }
 
 /** Pull Registers from the Hardware Stack */
 func (c *CPU) puls(address uint16) {
 	registers := uint8(c.read(address))
+	c.deadCycles(2)
 	if isBitSet(registers, 0) {
-		c.pullRegister(c.cc, c.s)
+		c.pullRegister(&c.cc, c.s)
 	}
 	if isBitSet(registers, 1) {
-		c.pullRegister(c.a, c.s)
+		c.pullRegister(&c.a, c.s)
 	}
 	if isBitSet(registers, 2) {
-		c.pullRegister(c.b, c.s)
+		c.pullRegister(&c.b, c.s)
 	}
 	if isBitSet(registers, 3) {
-		c.pullRegister(c.dp, c.s)
+		c.pullRegister(&c.dp, c.s)
 	}
 	if isBitSet(registers, 4) {
-		c.pullRegister(c.x, c.s)
+		c.pullRegister(&c.x, c.s)
 	}
 	if isBitSet(registers, 5) {
-		c.pullRegister(c.y, c.s)
+		c.pullRegister(&c.y, c.s)
 	}
 	if isBitSet(registers, 6) {
-		c.pullRegister(c.u, c.s)
+		c.pullRegister(&c.u, c.s)
 	}
 	if isBitSet(registers, 7) {
-		c.pullRegister(c.pc, c.s)
+		c.pullRegister(&c.pc, c.s)
 	}
+	c.dummyRead(c.s.uint16())
 }
 
 /** Push Registers on the User Stack */
 func (c *CPU) pshu(address uint16) {
 	registers := uint8(c.read(address))
+	c.deadCycles(2)
+	c.dummyRead(c.u.uint16())
 	if isBitSet(registers, 7) {
-		c.pushRegister(c.pc, c.u)
+		c.pushRegister(&c.pc, c.u)
 	}
 	if isBitSet(registers, 6) {
-		c.pushRegister(c.s, c.u)
+		c.pushRegister(&c.s, c.u)
 	}
 	if isBitSet(registers, 5) {
-		c.pushRegister(c.y, c.u)
+		c.pushRegister(&c.y, c.u)
 	}
 	if isBitSet(registers, 4) {
-		c.pushRegister(c.x, c.u)
+		c.pushRegister(&c.x, c.u)
 	}
 	if isBitSet(registers, 3) {
-		c.pushRegister(c.dp, c.u)
+		c.pushRegister(&c.dp, c.u)
 	}
 	if isBitSet(registers, 2) {
-		c.pushRegister(c.b, c.u)
+		c.pushRegister(&c.b, c.u)
 	}
 	if isBitSet(registers, 1) {
-		c.pushRegister(c.a, c.u)
+		c.pushRegister(&c.a, c.u)
 	}
 	if isBitSet(registers, 0) {
-		c.pushRegister(c.cc, c.u)
+		c.pushRegister(&c.cc, c.u)
 	}
 }
 
 /** Pull Registers from the User Stack */
 func (c *CPU) pulu(address uint16) {
 	registers := uint8(c.read(address))
+	c.deadCycles(2)
 	if isBitSet(registers, 0) {
-		c.pullRegister(c.cc, c.u)
+		c.pullRegister(&c.cc, c.u)
 	}
 	if isBitSet(registers, 1) {
-		c.pullRegister(c.a, c.u)
+		c.pullRegister(&c.a, c.u)
 	}
 	if isBitSet(registers, 2) {
-		c.pullRegister(c.b, c.u)
+		c.pullRegister(&c.b, c.u)
 	}
 	if isBitSet(registers, 3) {
-		c.pullRegister(c.dp, c.u)
+		c.pullRegister(&c.dp, c.u)
 	}
 	if isBitSet(registers, 4) {
-		c.pullRegister(c.x, c.u)
+		c.pullRegister(&c.x, c.u)
 	}
 	if isBitSet(registers, 5) {
-		c.pullRegister(c.y, c.u)
+		c.pullRegister(&c.y, c.u)
 	}
 	if isBitSet(registers, 6) {
-		c.pullRegister(c.s, c.u)
+		c.pullRegister(&c.s, c.u)
+		c.armNMI()
 	}
 	if isBitSet(registers, 7) {
-		c.pullRegister(c.pc, c.u)
+		c.pullRegister(&c.pc, c.u)
 	}
+	c.dummyRead(c.u.uint16())
 }
 
 /** Return from Subroutine */
 func (c *CPU) rts() {
-	c.pc.set(c.readw(c.s.uint16()))
+	c.pc.set(int(c.readw(c.s.uint16())))
 	c.s.inc().inc()
 }
 
@@ -1281,16 +1366,20 @@ func (c *CPU) abx() {
 
 /** Return from Interrupt */
 func (c *CPU) rti() {
-	c.pullRegister(c.cc, c.s)
+	c.pullRegister(&c.cc, c.s)
 	if c.cc.getE() {
-		c.pullRegister(c.a, c.s)
-		c.pullRegister(c.b, c.s)
-		c.pullRegister(c.dp, c.s)
-		c.pullRegister(c.x, c.s)
-		c.pullRegister(c.y, c.s)
-		c.pullRegister(c.u, c.s)
+		c.pullRegister(&c.a, c.s)
+		c.pullRegister(&c.b, c.s)
+		if c.nativeMode() {
+			c.pullRegister(&c.e, c.s)
+			c.pullRegister(&c.f, c.s)
+		}
+		c.pullRegister(&c.dp, c.s)
+		c.pullRegister(&c.x, c.s)
+		c.pullRegister(&c.y, c.s)
+		c.pullRegister(&c.u, c.s)
 	}
-	c.pullRegister(c.pc, c.s)
+	c.pullRegister(&c.pc, c.s)
 }
 
 /** Multiply - ZxCx */
@@ -1308,46 +1397,28 @@ func (c *CPU) mul() {
 
 /** Software Interrupt */
 func (c *CPU) swi() {
-	c.cc.setE()
-	c.pushRegister(c.pc, c.s)
-	c.pushRegister(c.u, c.s)
-	c.pushRegister(c.y, c.s)
-	c.pushRegister(c.x, c.s)
-	c.pushRegister(c.dp, c.s)
-	c.pushRegister(c.b, c.s)
-	c.pushRegister(c.a, c.s)
-	c.pushRegister(c.cc, c.s)
+	c.deadCycles(1)
+	c.pushEntireState()
+	c.deadCycles(1)
 	c.cc.setF()
 	c.cc.setI()
-	c.pc.set(c.readw(0xfffa))
+	c.pc.set(int(c.readw(vectorSWI)))
 }
 
 /** Software Interrupt 2 */
 func (c *CPU) swi2() {
-	c.cc.setE()
-	c.pushRegister(c.pc, c.s)
-	c.pushRegister(c.u, c.s)
-	c.pushRegister(c.y, c.s)
-	c.pushRegister(c.x, c.s)
-	c.pushRegister(c.dp, c.s)
-	c.pushRegister(c.b, c.s)
-	c.pushRegister(c.a, c.s)
-	c.pushRegister(c.cc, c.s)
-	c.pc.set(c.readw(0xfff4))
+	c.deadCycles(1)
+	c.pushEntireState()
+	c.deadCycles(1)
+	c.pc.set(int(c.readw(vectorSWI2)))
 }
 
 /** Software Interrupt 3 */
 func (c *CPU) swi3() {
-	c.cc.setE()
-	c.pushRegister(c.pc, c.s)
-	c.pushRegister(c.u, c.s)
-	c.pushRegister(c.y, c.s)
-	c.pushRegister(c.x, c.s)
-	c.pushRegister(c.dp, c.s)
-	c.pushRegister(c.b, c.s)
-	c.pushRegister(c.a, c.s)
-	c.pushRegister(c.cc, c.s)
-	c.pc.set(c.readw(0xfff2))
+	c.deadCycles(1)
+	c.pushEntireState()
+	c.deadCycles(1)
+	c.pc.set(int(c.readw(vectorSWI3)))
 }
 
 /** Subtract Memory - H?NxZxVxCx */
@@ -1536,6 +1607,7 @@ func (c *CPU) lds(address uint16) {
 	c.updateNZ16(value)
 	c.cc.clearV()
 	c.s.set(value)
+	c.armNMI()
 }
 
 /** Exclusive OR into Register - NxZxV0 */
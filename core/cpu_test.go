@@ -2,19 +2,25 @@ package core
 
 import (
 	"strings"
+	"sync"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
-func newCPU() *CPU {
+func newCPU(decodeCache bool) *CPU {
 	var cpu = CPU{}
 	ram := NewRam()
 	cpu.Initialize(ram)
+	cpu.SetDecodeCache(decodeCache)
 	return &cpu
 }
 
-var _ = Describe("CPU", func() {
+// The CPU specs are run by the interpreter and with the decoded instruction cache
+var _ = Describe("CPU", func() { describeCPU(false) })
+var _ = Describe("CPU with the decoded instruction cache", func() { describeCPU(true) })
+
+func describeCPU(decodeCache bool) {
 	var (
 		cpu CPU
 	)
@@ -23,6 +29,7 @@ var _ = Describe("CPU", func() {
 		cpu = CPU{}
 		ram := NewRam()
 		cpu.Initialize(ram)
+		cpu.SetDecodeCache(decodeCache)
 	})
 
 	It("Register D should be the concatenation of registers A and B", func() {
@@ -31,6 +38,98 @@ var _ = Describe("CPU", func() {
 		ExpectD(cpu, 0xe5f0)
 	})
 
+	It("should not share the dispatch table between CPUs", func() {
+		cpu1 := newCPU(decodeCache)
+		cpu2 := newCPU(decodeCache)
+		cpu1.pc.set(0x1000)
+		cpu1.write(0x1000, 0x86) // LDA #$2f
+		cpu1.write(0x1001, 0x2f)
+		cpu2.pc.set(0x1000)
+		cpu2.write(0x1000, 0x86) // LDA #$5d
+		cpu2.write(0x1001, 0x5d)
+		cpu1.step()
+		cpu2.step()
+
+		ExpectA(*cpu1, 0x2f)
+		ExpectA(*cpu2, 0x5d)
+		ExpectPC(*cpu1, 0x1002)
+		ExpectPC(*cpu2, 0x1002)
+	})
+
+	It("should run several CPUs in parallel", func() {
+		var wg sync.WaitGroup
+		cpus := make([]*CPU, 8)
+		for i := range cpus {
+			cpus[i] = newCPU(decodeCache)
+			cpus[i].pc.set(0x1000)
+			cpus[i].write(0x1000, 0x4c) // INCA
+			cpus[i].write(0x1001, 0x20) // BRA *-3
+			cpus[i].write(0x1002, 0xfd)
+		}
+		for i := range cpus {
+			wg.Add(1)
+			go func(cpu *CPU, n int) {
+				defer wg.Done()
+				for j := 0; j < 2*n; j++ {
+					cpu.step()
+				}
+			}(cpus[i], i)
+		}
+		wg.Wait()
+		for i, cpu := range cpus {
+			ExpectA(*cpu, i)
+		}
+	})
+
+	Context("[RESET]", func() {
+
+		It("should load PC from the reset vector on power on", func() {
+			cpu.a.set(0x2f)
+			cpu.dp.set(0x18)
+			cpu.writew(0xfffe, 0xf000)
+			cpu.PowerOn()
+
+			ExpectPC(cpu, 0xf000)
+			ExpectA(cpu, 0x00)
+			ExpectClock(cpu, 0)
+			Expect(cpu.dp.get()).To(BeEquivalentTo(0x00))
+			ExpectCCR(cpu, "FI", "EHNZVC")
+		})
+
+		It("should keep registers and clock on warm reset", func() {
+			cpu.a.set(0x2f)
+			cpu.x.set(0xe0ff)
+			cpu.dp.set(0x18)
+			cpu.cc.setC()
+			cpu.clock = 1000
+			cpu.writew(0xfffe, 0xf000)
+			cpu.Reset()
+
+			ExpectPC(cpu, 0xf000)
+			ExpectA(cpu, 0x2f)
+			ExpectX(cpu, 0xe0ff)
+			ExpectClock(cpu, 1000)
+			Expect(cpu.dp.get()).To(BeEquivalentTo(0x00))
+			ExpectCCR(cpu, "FIC", "")
+		})
+
+		It("should run the reset sequence when the RESET line is released", func() {
+			cpu.pc.set(0x1000)
+			cpu.write(0x1000, 0x12) // NOP
+			cpu.writew(0xfffe, 0xf000)
+			cpu.AssertReset()
+			cpu.step()
+			cpu.step()
+			ExpectPC(cpu, 0x1000)
+			ExpectClock(cpu, 2)
+
+			cpu.ReleaseReset()
+			cpu.step()
+			ExpectPC(cpu, 0xf000)
+			ExpectCCR(cpu, "FI", "")
+		})
+	})
+
 	Context("[SWI]", func() {
 
 		It("should implement SWI", func() {
@@ -2553,7 +2652,7 @@ var _ = Describe("CPU", func() {
 			branchingOpcodeTest16(0x102f, "ZNV", true, 6)
 		})
 	})
-})
+}
 
 /*
 
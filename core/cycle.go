@@ -0,0 +1,88 @@
+package core
+
+/* Cycle exact bus
+ *
+ * In cycle exact mode every access is a single byte bus cycle issued at its own
+ * clock value, and the cycles which do not transfer useful data are issued too:
+ * dead cycles put $FFFF on the address bus (VMA low) and inherent instructions
+ * read the byte following the opcode. The number of cycles of an instruction is
+ * the same in both modes: the dead cycles which are not emitted where the MC6809
+ * datasheet places them are issued at the end of the instruction. The stack
+ * instructions, JSR and the interrupt sequences follow the datasheet bus sequence,
+ * the words being pushed low byte first.
+ */
+
+/** Address driven on the bus during a dead cycle */
+const deadCycleAddress uint16 = 0xffff
+
+/** Issue a read bus cycle */
+func (c *CPU) busRead(address uint16, dummy bool) uint8 {
+	cycle := c.bus
+	c.bus++
+	var value uint8
+	if c.timed != nil {
+		value = c.timed.ReadCycle(cycle, address)
+	} else {
+		value = c.ram.Read(address)
+	}
+	if c.recording || c.hooks != nil {
+		c.observe(Access{Address: address, Value: uint16(value), Cycle: cycle, Dummy: dummy})
+	}
+	c.lastRead = int(address)
+	return value
+}
+
+/** Issue a write bus cycle */
+func (c *CPU) busWrite(address uint16, value uint8) {
+	if c.lastRead == int(address) && !c.nativeMode() {
+		c.idle() // read-modify-write instructions spend a cycle between the read and the write
+	}
+	c.lastRead = -1
+	cycle := c.bus
+	c.bus++
+	if c.timed != nil {
+		c.timed.WriteCycle(cycle, address, value)
+	} else {
+		c.ram.Write(address, value)
+	}
+	if c.recording || c.hooks != nil {
+		c.observe(Access{Address: address, Value: uint16(value), Write: true, Cycle: cycle})
+	}
+}
+
+/** Issue a dead cycle */
+func (c *CPU) idle() {
+	c.busRead(deadCycleAddress, true)
+	c.lastRead = -1
+}
+
+/** Issue the dead cycles of the 6809 bus sequence. The HD6309 skips them in native mode. */
+func (c *CPU) deadCycles(n int) {
+	if !c.CycleExact || c.nativeMode() {
+		return
+	}
+	for ; n > 0; n-- {
+		c.idle()
+	}
+}
+
+/** Inherent instructions read the next byte while the opcode is decoded */
+func (c *CPU) dummyRead(address uint16) {
+	if !c.CycleExact || c.nativeMode() {
+		return
+	}
+	c.busRead(address, true)
+}
+
+// settle issues the dead cycles remaining at the end of an instruction. When more
+// bus cycles than counted cycles have been issued, the clock is moved forward so
+// that the next instruction starts after them. It returns the number of cycles
+// the clock has been moved forward.
+func (c *CPU) settle() uint64 {
+	for c.bus < c.clock {
+		c.idle()
+	}
+	late := c.bus - c.clock
+	c.clock = c.bus
+	return late
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type busCycle struct {
+	cycle   uint64
+	address uint16
+	write   bool
+}
+
+/** Memory recording the bus cycles it sees */
+type timedRam struct {
+	Memory
+	cycles []busCycle
+}
+
+func (m *timedRam) ReadCycle(cycle uint64, address uint16) uint8 {
+	m.cycles = append(m.cycles, busCycle{cycle, address, false})
+	return m.Read(address)
+}
+
+func (m *timedRam) WriteCycle(cycle uint64, address uint16, value uint8) {
+	m.cycles = append(m.cycles, busCycle{cycle, address, true})
+	m.Write(address, value)
+}
+
+var _ = Describe("Cycle exact mode", func() {
+	var (
+		cpu *CPU
+		ram *timedRam
+	)
+
+	BeforeEach(func() {
+		ram = &timedRam{Memory: NewRam()}
+		cpu = NewCPU(ram, MC6809)
+		cpu.CycleExact = true
+		cpu.pc.set(0x1000)
+		cpu.s.set(0x2000)
+		cpu.dp.set(0x20)
+		cpu.clock = 100
+	})
+
+	It("should issue the dead cycle of the direct mode", func() {
+		cpu.write(0x1000, 0x96) // LDA <$10
+		cpu.write(0x1001, 0x10)
+		ram.cycles = nil
+		cpu.step()
+
+		Expect(ram.cycles).To(Equal([]busCycle{
+			{100, 0x1000, false},
+			{101, 0x1001, false},
+			{102, 0xffff, false},
+			{103, 0x2010, false},
+		}))
+		ExpectClock(*cpu, 104)
+	})
+
+	It("should read the next byte during an inherent instruction", func() {
+		cpu.write(0x1000, 0x12) // NOP
+		ram.cycles = nil
+		cpu.step()
+
+		Expect(ram.cycles).To(Equal([]busCycle{
+			{100, 0x1000, false},
+			{101, 0x1001, false},
+		}))
+	})
+
+	It("should spend a cycle between the read and the write of a read-modify-write", func() {
+		cpu.write(0x1000, 0x0c) // INC <$10
+		cpu.write(0x1001, 0x10)
+		cpu.write(0x2010, 0x41)
+		ram.cycles = nil
+		cpu.step()
+
+		Expect(ram.cycles).To(Equal([]busCycle{
+			{100, 0x1000, false},
+			{101, 0x1001, false},
+			{102, 0xffff, false},
+			{103, 0x2010, false},
+			{104, 0xffff, false},
+			{105, 0x2010, true},
+		}))
+		ExpectMemory(*cpu, 0x2010, 0x42)
+	})
+
+	It("should read the memory cleared by CLR", func() {
+		cpu.write(0x1000, 0x0f) // CLR <$10
+		cpu.write(0x1001, 0x10)
+		result, _ := cpu.Step()
+
+		Expect(result.Accesses).To(ContainElement(Access{PC: 0x1000, Opcode: 0x0f, Address: 0x2010, Cycle: 103, Dummy: true}))
+		Expect(result.Accesses).To(ContainElement(Access{PC: 0x1000, Opcode: 0x0f, Address: 0x2010, Write: true, Cycle: 105}))
+	})
+
+	It("should issue the dead cycles of the indexed mode before the data access", func() {
+		cpu.x.set(0x3000)
+		cpu.writew(0x1000, 0xa689) // LDA $0100,X
+		cpu.writew(0x1002, 0x0100)
+		ram.cycles = nil
+		cpu.step()
+
+		Expect(ram.cycles).To(HaveLen(8))
+		Expect(ram.cycles[4:]).To(Equal([]busCycle{
+			{104, 0xffff, false},
+			{105, 0xffff, false},
+			{106, 0xffff, false},
+			{107, 0x3100, false},
+		}))
+	})
+
+	It("should push the return address of JSR after reading the subroutine", func() {
+		cpu.write(0x1000, 0xbd) // JSR $3000
+		cpu.writew(0x1001, 0x3000)
+		ram.cycles = nil
+		cpu.step()
+
+		Expect(ram.cycles).To(Equal([]busCycle{
+			{100, 0x1000, false},
+			{101, 0x1001, false},
+			{102, 0x1002, false},
+			{103, 0xffff, false},
+			{104, 0x3000, false},
+			{105, 0xffff, false},
+			{106, 0x1fff, true},
+			{107, 0x1ffe, true},
+		}))
+		ExpectWord(*cpu, 0x1ffe, 0x1003)
+	})
+
+	It("should push and pull the registers after the dead cycles", func() {
+		cpu.writew(0x1000, 0x3406) // PSHS A,B
+		cpu.writew(0x1002, 0x3506) // PULS A,B
+		ram.cycles = nil
+		cpu.step()
+		cpu.step()
+
+		Expect(ram.cycles).To(Equal([]busCycle{
+			{100, 0x1000, false},
+			{101, 0x1001, false},
+			{102, 0xffff, false},
+			{103, 0xffff, false},
+			{104, 0x2000, false},
+			{105, 0x1fff, true},
+			{106, 0x1ffe, true},
+			{107, 0x1002, false},
+			{108, 0x1003, false},
+			{109, 0xffff, false},
+			{110, 0xffff, false},
+			{111, 0x1ffe, false},
+			{112, 0x1fff, false},
+			{113, 0x2000, false},
+		}))
+	})
+
+	It("should stack the registers at the datasheet cycles when entering an interrupt", func() {
+		cpu.writew(0xfff8, 0xc000)
+		cpu.AssertIRQ()
+		ram.cycles = nil
+		cpu.step()
+
+		expected := []busCycle{
+			{100, 0x1000, false},
+			{101, 0x1000, false},
+			{102, 0xffff, false},
+		}
+		for i := uint16(0); i < 12; i++ {
+			expected = append(expected, busCycle{103 + uint64(i), 0x1fff - i, true})
+		}
+		expected = append(expected,
+			busCycle{115, 0xffff, false},
+			busCycle{116, 0xfff8, false},
+			busCycle{117, 0xfff9, false},
+			busCycle{118, 0xffff, false},
+		)
+		Expect(ram.cycles).To(Equal(expected))
+		ExpectWord(*cpu, 0x1ffe, 0x1000)
+		ExpectClock(*cpu, 119)
+	})
+
+	It("should issue dead cycles while waiting for an interrupt", func() {
+		cpu.write(0x1000, 0x13) // SYNC
+		cpu.step()
+		ram.cycles = nil
+		cpu.step()
+
+		Expect(ram.cycles).To(Equal([]busCycle{{104, 0xffff, false}}))
+	})
+
+	It("should take as many cycles as the instruction level mode", func() {
+		for _, set := range [][]instruction{mc6809Instructions, hd6309Instructions} {
+			for _, native := range []bool{false, true} {
+				for _, in := range set {
+					cycles := [2]uint64{}
+					for i, exact := range []bool{false, true} {
+						ram := &timedRam{Memory: NewRam()}
+						cpu := NewCPU(ram, HD6309)
+						cpu.CycleExact = exact
+						if native {
+							cpu.md.set(mdNative)
+						}
+						cpu.pc.set(0x1000)
+						cpu.s.set(0x8000)
+						cpu.writew(0xfff0, 0xc000)
+						for j, b := range encode(in) {
+							cpu.write(0x1000+uint16(j), b)
+						}
+						ram.cycles = nil
+						cycles[i], _ = cpu.step()
+						if exact {
+							Expect(ram.cycles).To(HaveLen(int(cycles[i])))
+							for j, cycle := range ram.cycles {
+								Expect(cycle.cycle).To(BeEquivalentTo(j))
+							}
+						}
+					}
+					Expect(cycles[1]).To(Equal(cycles[0]), fmt.Sprintf("opcode %02x %s native=%v", in.code, in.name, native))
+				}
+			}
+		}
+	})
+})
@@ -0,0 +1,70 @@
+package core
+
+/* Devices clocked by the CPU
+ *
+ * The devices running on the clock of the CPU, like the timers, are ticked before
+ * the instructions. Each device returns the clock of its next event and the CPU
+ * only ticks its devices again when the earliest of these clocks is reached, so
+ * that the interrupt raised by an event is sampled before the next instruction.
+ */
+
+/** Clock of an event which never happens */
+const never = ^uint64(0)
+
+// Device is implemented by the devices running on the clock of the CPU. Tick
+// advances the device to a clock and returns the clock of its next event,
+// ^uint64(0) if none is scheduled.
+type Device interface {
+	Tick(clock uint64) uint64
+}
+
+// ClockSetter is implemented by the devices which keep the clock they have been
+// advanced to. SetClock is called when the clock of the CPU is set by PowerOn or
+// SetState: the device takes it as its new time base, the cycles in between are
+// not counted.
+type ClockSetter interface {
+	SetClock(clock uint64)
+}
+
+// AddDevice registers a device ticked by the CPU
+func (c *CPU) AddDevice(d Device) {
+	c.devices = append(c.devices, d)
+	c.Reschedule()
+}
+
+// RemoveDevice unregisters a device
+func (c *CPU) RemoveDevice(d Device) {
+	for i, device := range c.devices {
+		if device == d {
+			c.devices = append(c.devices[:i:i], c.devices[i+1:]...)
+			break
+		}
+	}
+	c.Reschedule()
+}
+
+// Reschedule makes the CPU tick its devices before the next instruction. It is
+// called by a device when its next event may have moved earlier, after a register
+// write for instance.
+func (c *CPU) Reschedule() {
+	c.next = 0
+}
+
+/** Move the time base of the devices to the clock of the CPU */
+func (c *CPU) setDeviceClocks() {
+	for _, d := range c.devices {
+		if s, ok := d.(ClockSetter); ok {
+			s.SetClock(c.clock)
+		}
+	}
+}
+
+/** Tick the devices and schedule the next tick */
+func (c *CPU) tick() {
+	c.next = never
+	for _, d := range c.devices {
+		if next := d.Tick(c.clock); next < c.next {
+			c.next = next
+		}
+	}
+}
@@ -12,151 +12,254 @@ var stackRegisters = [][]int{
 }
 var indexRegisters = []int{1, 2, 3, 4}
 
+/* HD6309 operands */
+var interRegisters = []string{"D", "X", "Y", "U", "S", "PC", "W", "V", "A", "B", "CC", "DP", "0", "0", "E", "F"}
+var bitRegisters = []string{"CC", "A", "B", "?"}
+var blockMoveSuffixes = [][]string{{"+", "+"}, {"-", "-"}, {"+", ""}, {"", "+"}}
+
+/** Notation of the disassembled instructions */
+type syntax struct {
+	/// Hexadecimal digits in upper case
+	upper bool
+	/// Prefix of the direct page addresses
+	direct string
+	/// Prefix of the indexed offsets
+	offset string
+	/// Digits of the 5-bit indexed offsets
+	shortOffset int
+	/// Brackets of the indirect modes
+	open, close string
+	/// Registers of TFR and EXG
+	transfer []string
+	/// Separator of the TFR and EXG registers
+	separator string
+	/// Branch targets are absolute addresses, offsets otherwise
+	absolute bool
+	/// Width of the mnemonic column, 0 for a single space before the operands
+	column int
+	/// Operand of the addressing modes which are not disassembled
+	unknown string
+}
+
+/** Notation of Disassemble */
+var nativeSyntax = syntax{
+	direct:      "<$",
+	shortOffset: 2,
+	open:        "(",
+	close:       ")",
+	transfer:    registers,
+	separator:   ", ",
+	unknown:     "??? (NYE)",
+}
+
+/** Notation of the MAME 6809 disassembler: upper case hexadecimal, absolute branch
+ * targets and brackets for the indirect modes */
+var mameSyntax = syntax{
+	upper:       true,
+	direct:      "$",
+	offset:      "$",
+	shortOffset: 1,
+	open:        "[",
+	close:       "]",
+	transfer:    interRegisters,
+	separator:   ",",
+	absolute:    true,
+	column:      6,
+}
+
+/** Format hexadecimal values in the case of the notation */
+func (s *syntax) hex(format string, args ...interface{}) string {
+	if s.upper {
+		format = strings.Replace(format, "x", "X", -1)
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+/** Signed indexed offset */
+func (s *syntax) signed(offset int, digits int) string {
+	sign := ""
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	return sign + s.offset + s.hex(fmt.Sprintf("%%0%dx", digits), offset)
+}
+
 // Disassemble an instruction and return the string representation and the size of the instruction
 func Disassemble(op opcode, instBuf []uint8) (string, int) {
-	var sb strings.Builder
+	return disassemble(op, 0, instBuf, &nativeSyntax)
+}
 
-	sb.WriteString(op.name)
+/** Disassemble an instruction with a notation. pc is the address of instBuf[0],
+ * only used by the absolute branch targets. */
+func disassemble(op opcode, pc uint16, instBuf []uint8, s *syntax) (string, int) {
+	var operands string
 	var size int
+	word := func(i int) uint16 {
+		return uint16(instBuf[i])<<8 | uint16(instBuf[i+1])
+	}
 
 	switch op.mode {
 	case inherent:
 		// else no EA to decode
 		size = 1
 	case direct:
-		sb.WriteString(fmt.Sprintf(" <$%02x", instBuf[1]))
+		operands = s.hex(s.direct+"%02x", instBuf[1])
 		size = 2
 	case relative:
-		sb.WriteString(fmt.Sprintf(" *+$%02x", instBuf[1]))
 		size = 2
+		if s.absolute {
+			operands = s.hex("$%04x", pc+uint16(size)+uint16(int8(instBuf[1])))
+		} else {
+			operands = s.hex("*+$%02x", instBuf[1])
+		}
 	case lrelative:
-		sb.WriteString(fmt.Sprintf(" *+$%02x%02x", instBuf[1], instBuf[2]))
 		size = 3
-	case immediate:
-		if op.name == "TFR" || op.name == "EXG" {
-			sb.WriteString(fmt.Sprintf(" %s, %s", registers[(instBuf[1]>>4)&0xf], registers[instBuf[1]&0xf]))
-		} else if instBuf[0]&0xfc == 0x34 { // PSHS PULS PSHU PULU
-			regs := make([]string, 0)
-			if instBuf[0]&0x01 == 0 { // Push
-				for i := 0; i <= 7; i++ {
-					if (0x80>>uint(i))&instBuf[1] != 0 {
-						regs = append(regs, registers[stackRegisters[(instBuf[0]&2)>>1][i]])
-					}
+		if s.absolute {
+			operands = s.hex("$%04x", pc+uint16(size)+word(1))
+		} else {
+			operands = s.hex("*+$%04x", word(1))
+		}
+	case transfer:
+		operands = s.transfer[(instBuf[1]>>4)&0xf] + s.separator + s.transfer[instBuf[1]&0xf]
+		size = 2
+	case stack:
+		regs := make([]string, 0)
+		if instBuf[0]&0x01 == 0 { // Push
+			for i := 0; i <= 7; i++ {
+				if (0x80>>uint(i))&instBuf[1] != 0 {
+					regs = append(regs, registers[stackRegisters[(instBuf[0]&2)>>1][i]])
 				}
-			} else { // Pull
-				for i := 7; i >= 0; i-- {
-					if (0x80>>uint(i))&instBuf[1] != 0 {
-						regs = append(regs, registers[stackRegisters[(instBuf[0]&2)>>1][i]])
-					}
+			}
+		} else { // Pull
+			for i := 7; i >= 0; i-- {
+				if (0x80>>uint(i))&instBuf[1] != 0 {
+					regs = append(regs, registers[stackRegisters[(instBuf[0]&2)>>1][i]])
 				}
 			}
-			sb.WriteString(fmt.Sprintf(" %s", strings.Join(regs, ",")))
-		} else {
-			sb.WriteString(fmt.Sprintf(" #$%02x", instBuf[1]))
 		}
+		operands = strings.Join(regs, ",")
+		size = 2
+	case immediate:
+		operands = s.hex("#$%02x", instBuf[1])
 		size = 2
 	case limmediate:
-		sb.WriteString(fmt.Sprintf(" #$%02x%02x", instBuf[1], instBuf[2]))
+		operands = s.hex("#$%04x", word(1))
 		size = 3
 	case extended:
-		sb.WriteString(fmt.Sprintf(" $%02x%02x", instBuf[1], instBuf[2]))
+		operands = s.hex("$%04x", word(1))
 		size = 3
 	case indexed:
-		postbyte := instBuf[0x01]
-		if postbyte&0x80 == 0 {
-			if postbyte&0x10 == 0 {
-				offset := postbyte & 0x0f
-				sb.WriteString(fmt.Sprintf(" %02x,%s", offset, registers[indexRegisters[(postbyte&0x60)>>5]]))
-			} else {
-				offset := ((postbyte & 0x0f) ^ 0x0f) + 1
-				sb.WriteString(fmt.Sprintf(" -%02x,%s", offset, registers[indexRegisters[(postbyte&0x60)>>5]]))
-			}
-			size = 2
-		} else {
-			sb.WriteString(" ")
-			if postbyte&0x10 == 0x10 { // Indirect mode
-				sb.WriteString("(")
-			}
-
-			switch postbyte & 0x0f {
-			case 0x00:
-				sb.WriteString(fmt.Sprintf(",%s+", registers[indexRegisters[(postbyte&0x60)>>5]]))
-				size = 2
-
-			case 0x01:
-				sb.WriteString(fmt.Sprintf(",%s++", registers[indexRegisters[(postbyte&0x60)>>5]]))
-				size = 2
-
-			case 0x02:
-				sb.WriteString(fmt.Sprintf(",-%s", registers[indexRegisters[(postbyte&0x60)>>5]]))
-				size = 2
-
-			case 0x03:
-				sb.WriteString(fmt.Sprintf(",--%s", registers[indexRegisters[(postbyte&0x60)>>5]]))
-				size = 2
-
-			case 0x04:
-				sb.WriteString(fmt.Sprintf(",%s", registers[indexRegisters[(postbyte&0x60)>>5]]))
-				size = 2
-
-			case 0x05:
-				sb.WriteString(fmt.Sprintf("B,%s", registers[indexRegisters[(postbyte&0x60)>>5]]))
-				size = 2
+		operands, size = disassembleIndexed(instBuf, s)
+	case qimmediate:
+		operands = s.hex("#$%04x%04x", word(1), word(3))
+		size = 5
+	case imdirect:
+		operands = s.hex("#$%02x,"+s.direct+"%02x", instBuf[1], instBuf[2])
+		size = 3
+	case imindexed:
+		operands, size = disassembleIndexed(instBuf[1:], s)
+		operands = s.hex("#$%02x,", instBuf[1]) + operands
+		size++
+	case imextended:
+		operands = s.hex("#$%02x,$%04x", instBuf[1], word(2))
+		size = 4
+	case bitdirect:
+		postbyte := instBuf[1]
+		operands = s.hex("%s,%d,%d,"+s.direct+"%02x", bitRegisters[postbyte>>6], (postbyte>>3)&7, postbyte&7, instBuf[2])
+		size = 3
+	case interregister:
+		operands = fmt.Sprintf("%s,%s", interRegisters[instBuf[1]>>4], interRegisters[instBuf[1]&0xf])
+		size = 2
+	case blockmove:
+		suffixes := blockMoveSuffixes[instBuf[0]&0x03]
+		operands = fmt.Sprintf("%s%s,%s%s", interRegisters[instBuf[1]>>4], suffixes[0], interRegisters[instBuf[1]&0xf], suffixes[1])
+		size = 2
+	default:
+		operands = s.unknown
+	}
 
-			case 0x06:
-				sb.WriteString(fmt.Sprintf("A,%s", registers[indexRegisters[(postbyte&0x60)>>5]]))
-				size = 2
+	if operands == "" {
+		return op.name, size
+	}
+	if s.column == 0 {
+		return op.name + " " + operands, size
+	}
+	return fmt.Sprintf("%-*s%s", s.column, op.name, operands), size
+}
 
-			case 0x08:
-				offset := int8(instBuf[2])
-				if offset < 0 {
-					sb.WriteString("-")
-					offset = -offset
-				}
-				sb.WriteString(fmt.Sprintf("%02x,%s", offset, registers[indexRegisters[(postbyte&0x60)>>5]]))
-				size = 3
-
-			case 0x09:
-				offset := int16(instBuf[2])<<8 | int16(instBuf[3])
-				if offset < 0 {
-					sb.WriteString("-")
-					offset = -offset
-				}
-				sb.WriteString(fmt.Sprintf("%04x,%s", offset, registers[indexRegisters[(postbyte&0x60)>>5]]))
-				size = 4
-
-			case 0x0b:
-				sb.WriteString(fmt.Sprintf("D,%s", registers[indexRegisters[(postbyte&0x60)>>5]]))
-				size = 2
-
-			case 0x0c:
-				offset := int8(instBuf[2])
-				if offset < 0 {
-					sb.WriteString("-")
-					offset = -offset
-				}
-				sb.WriteString(fmt.Sprintf("%02x,PC", offset))
-				size = 3
-
-			case 0x0d:
-				offset := int16(instBuf[2])<<8 | int16(instBuf[3])
-				if offset < 0 {
-					sb.WriteString("-")
-					offset = -offset
-				}
-				sb.WriteString(fmt.Sprintf("%04x,PC", offset))
-				size = 4
-			}
+// disassembleIndexed decodes the indexed addressing postbyte at instBuf[1] and
+// returns the operand and the size of the instruction, the opcode byte included
+func disassembleIndexed(instBuf []uint8, s *syntax) (string, int) {
+	postbyte := instBuf[0x01]
+	register := registers[indexRegisters[(postbyte&0x60)>>5]]
+	if postbyte&0x80 == 0 {
+		offset := int(postbyte & 0x1f)
+		if offset > 15 {
+			offset -= 32
+		}
+		return s.signed(offset, s.shortOffset) + "," + register, 2
+	}
 
-			if postbyte&0x10 == 0x10 { // Indirect mode
-				sb.WriteString(")")
-			}
+	var operand string
+	size := 2
+	switch {
+	case postbyte&0x9f == 0x8f || postbyte&0x9f == 0x90: // HD6309 W modes
+		switch (postbyte & 0x60) >> 5 {
+		case 0:
+			operand = ",W"
+		case 1:
+			operand = s.offset + s.hex("%04x,W", uint16(instBuf[2])<<8|uint16(instBuf[3]))
+			size = 4
+		case 2:
+			operand = ",W++"
+		case 3:
+			operand = ",--W"
 		}
 	default:
-		sb.WriteString(" ??? (NYE)")
+		switch postbyte & 0x0f {
+		case 0x00:
+			operand = "," + register + "+"
+		case 0x01:
+			operand = "," + register + "++"
+		case 0x02:
+			operand = ",-" + register
+		case 0x03:
+			operand = ",--" + register
+		case 0x04:
+			operand = "," + register
+		case 0x05:
+			operand = "B," + register
+		case 0x06:
+			operand = "A," + register
+		case 0x07:
+			operand = "E," + register
+		case 0x08:
+			operand = s.signed(int(int8(instBuf[2])), 2) + "," + register
+			size = 3
+		case 0x09:
+			operand = s.signed(int(int16(uint16(instBuf[2])<<8|uint16(instBuf[3]))), 4) + "," + register
+			size = 4
+		case 0x0a:
+			operand = "F," + register
+		case 0x0b:
+			operand = "D," + register
+		case 0x0c:
+			operand = s.signed(int(int8(instBuf[2])), 2) + ",PC"
+			size = 3
+		case 0x0d:
+			operand = s.signed(int(int16(uint16(instBuf[2])<<8|uint16(instBuf[3]))), 4) + ",PC"
+			size = 4
+		case 0x0e:
+			operand = "W," + register
+		case 0x0f:
+			operand = s.hex("$%04x", uint16(instBuf[2])<<8|uint16(instBuf[3]))
+			size = 4
+		}
 	}
-
-	return sb.String(), size
+	if postbyte&0x10 == 0x10 { // Indirect mode
+		operand = s.open + operand + s.close
+	}
+	return operand, size
 }
 
 func format(pc uint16, instruction string, binary []uint8) {
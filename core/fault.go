@@ -0,0 +1,82 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FaultPolicy defines how the CPU behaves when an instruction has an undefined
+// behaviour in the MC6809 datasheet
+type FaultPolicy int
+
+const (
+	// TrapFaults aborts the instruction and returns a Fault error
+	TrapFaults FaultPolicy = 0
+	// EmulateSilicon executes the instruction the way the MC6809 silicon does
+	EmulateSilicon FaultPolicy = 1
+)
+
+// FaultKind identifies the cause of a Fault
+type FaultKind int
+
+const (
+	// MixedRegisters is raised by EXG or TFR between an 8-bit and a 16-bit register
+	MixedRegisters FaultKind = 0
+	// InvalidRegister is raised by EXG or TFR with an undefined register code
+	InvalidRegister FaultKind = 1
+	// InvalidPostbyte is raised by an undefined indexed addressing mode postbyte
+	InvalidPostbyte FaultKind = 2
+)
+
+// Fault is the error returned when the CPU aborts an instruction. The program
+// counter is restored to the address of the faulty instruction.
+type Fault struct {
+	/// Cause of the fault
+	Kind FaultKind
+	/// Address of the instruction
+	PC uint16
+	/// Instruction bytes fetched before the fault, $FF for the addresses which
+	/// cannot be peeked
+	Bytes []uint8
+	/// Clock when the fault occurred
+	Clock uint64
+	/// Human readable description
+	Reason string
+}
+
+func (f *Fault) Error() string {
+	hexa := []string{}
+	for _, x := range f.Bytes {
+		hexa = append(hexa, fmt.Sprintf("%02x", x))
+	}
+	return fmt.Sprintf("%s: instruction %s at pc=%04x (clock=%d)", f.Reason, strings.Join(hexa, " "), f.PC, f.Clock)
+}
+
+/** Abort the current instruction. The fault is recovered by step() */
+func fault(kind FaultKind, format string, args ...interface{}) {
+	panic(&Fault{Kind: kind, Reason: fmt.Sprintf(format, args...)})
+}
+
+/** Turn an aborted instruction into an error. The fetched bytes are peeked, so
+ * that they are not read again from the bus. */
+func (c *CPU) recoverFault(pc uint16, r interface{}) error {
+	f, ok := r.(*Fault)
+	if !ok {
+		panic(r)
+	}
+	f.PC = pc
+	f.Clock = c.clock
+	end := c.pc.uint16()
+	if end <= pc {
+		end = pc + 1
+	}
+	for a := pc; a != end; a++ {
+		value, ok := peek(c.ram, a)
+		if !ok {
+			value = 0xff
+		}
+		f.Bytes = append(f.Bytes, value)
+	}
+	c.pc.set(int(pc))
+	return f
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Faults", func() {
+	var (
+		cpu CPU
+	)
+
+	BeforeEach(func() {
+		cpu = CPU{}
+		ram := NewRam()
+		cpu.Initialize(ram)
+		cpu.pc.set(0x1000)
+		cpu.clock = 100
+	})
+
+	Context("[Trap]", func() {
+
+		It("should abort TFR between 8-bit and 16-bit registers", func() {
+			cpu.write(0x1000, 0x1f) // TFR A,X
+			cpu.write(0x1001, 0x81)
+			cpu.a.set(0x2f)
+			_, err := cpu.step()
+
+			f, ok := err.(*Fault)
+			Expect(ok).To(BeTrue())
+			Expect(f.Kind).To(Equal(MixedRegisters))
+			Expect(f.PC).To(BeEquivalentTo(0x1000))
+			Expect(f.Bytes).To(Equal([]uint8{0x1f, 0x81}))
+			Expect(f.Clock).To(BeEquivalentTo(100))
+			ExpectPC(cpu, 0x1000)
+			ExpectX(cpu, 0x0000)
+		})
+
+		It("should not read the bytes of the faulty instruction again", func() {
+			cpu.write(0x1000, 0x1f) // TFR A,X
+			cpu.write(0x1001, 0x81)
+			result, err := cpu.Step()
+
+			Expect(err).To(HaveOccurred())
+			Expect(result.Accesses).To(Equal([]Access{
+				{PC: 0x1000, Opcode: -1, Address: 0x1000, Value: 0x1f, Cycle: 100},
+				{PC: 0x1000, Opcode: 0x1f, Address: 0x1001, Value: 0x81, Cycle: 100},
+			}))
+		})
+
+		It("should abort EXG with an undefined register", func() {
+			cpu.write(0x1000, 0x1e) // EXG X,?
+			cpu.write(0x1001, 0x16)
+			_, err := cpu.step()
+
+			Expect(err).To(MatchError("invalid register code 6: instruction 1e 16 at pc=1000 (clock=100)"))
+		})
+
+		It("should abort undefined indexed postbytes", func() {
+			cpu.write(0x1000, 0xa6) // LDA ?
+			cpu.write(0x1001, 0x87)
+			_, err := cpu.Step()
+
+			f, ok := err.(*Fault)
+			Expect(ok).To(BeTrue())
+			Expect(f.Kind).To(Equal(InvalidPostbyte))
+			Expect(f.Bytes).To(Equal([]uint8{0xa6, 0x87}))
+			ExpectPC(cpu, 0x1000)
+		})
+
+		It("should abort indirect auto-increment by 1", func() {
+			cpu.write(0x1000, 0xa6) // LDA [,X+]
+			cpu.write(0x1001, 0x90)
+			cpu.x.set(0x2000)
+			_, err := cpu.step()
+
+			Expect(err).To(HaveOccurred())
+			ExpectX(cpu, 0x2000)
+		})
+
+		It("should stop RunCycles on fault", func() {
+			cpu.write(0x1000, 0x12) // NOP
+			cpu.write(0x1001, 0x1f) // TFR B,Y
+			cpu.write(0x1002, 0x92)
+			cycles, err := cpu.RunCycles(100)
+
+			Expect(err).To(BeAssignableToTypeOf(&Fault{}))
+			Expect(cycles).To(BeEquivalentTo(2))
+			ExpectPC(cpu, 0x1001)
+		})
+	})
+
+	Context("[Silicon]", func() {
+
+		BeforeEach(func() {
+			cpu.Faults = EmulateSilicon
+		})
+
+		It("should transfer an 8-bit register into a 16-bit register", func() {
+			cpu.write(0x1000, 0x1f) // TFR A,X
+			cpu.write(0x1001, 0x81)
+			cpu.a.set(0x2f)
+			_, err := cpu.step()
+
+			Expect(err).NotTo(HaveOccurred())
+			ExpectX(cpu, 0xff2f)
+			ExpectPC(cpu, 0x1002)
+		})
+
+		It("should exchange an 8-bit register with a 16-bit register", func() {
+			cpu.write(0x1000, 0x1e) // EXG Y,B
+			cpu.write(0x1001, 0x29)
+			cpu.y.set(0x1234)
+			cpu.b.set(0x56)
+			cpu.step()
+
+			ExpectY(cpu, 0xff56)
+			ExpectB(cpu, 0x34)
+		})
+
+		It("should read undefined registers as $ffff", func() {
+			cpu.write(0x1000, 0x1f) // TFR ?,X
+			cpu.write(0x1001, 0x71)
+			cpu.step()
+
+			ExpectX(cpu, 0xffff)
+		})
+
+		It("should execute undefined indexed postbytes", func() {
+			cpu.write(0x1000, 0xa6) // LDA ?
+			cpu.write(0x1001, 0x8a)
+			cpu.write(0x10ff, 0x2f)
+			_, err := cpu.step()
+
+			Expect(err).NotTo(HaveOccurred())
+			ExpectA(cpu, 0x2f)
+		})
+	})
+})
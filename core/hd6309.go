@@ -0,0 +1,909 @@
+package core
+
+// Variant selects the CPU model emulated by a CPU
+type Variant int
+
+const (
+	// MC6809 is the Motorola MC6809 fitted in the TO7/70
+	MC6809 Variant = 0
+	// HD6309 is the Hitachi HD6309, a pin compatible MC6809 with extra registers,
+	// extra instructions and a faster native mode
+	HD6309 Variant = 1
+)
+
+/* HD6309 mode and error register (MD) bits */
+
+const (
+	mdNative       = 0x01 // native mode
+	mdFIRQ         = 0x02 // FIRQ stacks the entire state
+	mdIllegal      = 0x40 // illegal instruction trap
+	mdDivideByZero = 0x80 // division by zero trap
+)
+
+const vectorTrap uint16 = 0xfff0
+
+// NewCPU creates a CPU of the given variant connected to the memory
+func NewCPU(ram Memory, variant Variant) *CPU {
+	c := &CPU{variant: variant}
+	c.Initialize(ram)
+	return c
+}
+
+// Variant returns the CPU model
+func (c *CPU) Variant() Variant {
+	return c.variant
+}
+
+/** HD6309 running in native mode */
+func (c *CPU) nativeMode() bool {
+	return c.md.get()&mdNative != 0
+}
+
+func (c *CPU) w() uint16 {
+	return uint16(c.e.get()<<8 | c.f.get())
+}
+
+func (c *CPU) setW(value uint16) {
+	c.e.set(int(value >> 8))
+	c.f.set(int(value & 0xff))
+}
+
+func (c *CPU) q() uint32 {
+	return uint32(c.d())<<16 | uint32(c.w())
+}
+
+func (c *CPU) setQ(value uint32) {
+	c.setD(uint16(value >> 16))
+	c.setW(uint16(value))
+}
+
+func (c *CPU) updateNZ32(value uint32) {
+	if value == 0 {
+		c.cc.setZ()
+	} else {
+		c.cc.clearZ()
+	}
+	if value&0x80000000 != 0 {
+		c.cc.setN()
+	} else {
+		c.cc.clearN()
+	}
+}
+
+/** Illegal instruction and division by zero trap */
+func (c *CPU) trap(cause int) {
+	c.md.set(c.md.get() | cause)
+	c.pushEntireState()
+	c.cc.setF()
+	c.cc.setI()
+	c.pc.set(int(c.readw(vectorTrap)))
+	c.clock += 8 // the trap is 20 cycles (22 in native mode), the rest is counted when the registers are pushed
+}
+
+/** An instruction operand is undefined: the HD6309 takes the illegal instruction trap */
+func (c *CPU) illegalOperand(format string, args ...interface{}) {
+	if c.Faults != EmulateSilicon {
+		fault(InvalidRegister, format, args...)
+	}
+	c.trap(mdIllegal)
+}
+
+func (c *CPU) getHD6309RegisterFromCode(code int) uint16 {
+	switch code {
+	case 6:
+		return c.w()
+	case 7:
+		return c.v.uint16()
+	case 14:
+		return c.e.uint16()
+	case 15:
+		return c.f.uint16()
+	default:
+		return 0 // zero register
+	}
+}
+
+func (c *CPU) setHD6309RegisterFromCode(code int, value uint16) {
+	switch code {
+	case 6:
+		c.setW(value)
+	case 7:
+		c.v.set(int(value))
+	case 14:
+		c.e.set(int(value))
+	case 15:
+		c.f.set(int(value))
+	default:
+		// writes to the zero register are lost
+	}
+}
+
+/** HD6309 indexed addressing modes. Returns false if the postbyte is an MC6809 mode */
+func (c *CPU) getHD6309IndexedAddress(postbyte uint8) (uint16, bool) {
+	switch postbyte {
+	case 0x8f, 0x90:
+		/* No Offset from W */
+		return c.w(), true
+	case 0xaf, 0xb0:
+		/* 16 bits Offset from W */
+		offset := c.readw(c.pc.uint16())
+		c.pc.inc().inc()
+		c.clock += 2
+		return c.w() + offset, true
+	case 0xcf, 0xd0:
+		/* Autoincrement by 2 from W */
+		address := c.w()
+		c.setW(address + 2)
+		c.clock++
+		return address, true
+	case 0xef, 0xf0:
+		/* Autodecrement by 2 from W */
+		address := c.w() - 2
+		c.setW(address)
+		c.clock++
+		return address, true
+	}
+	var offset uint16
+	switch postbyte & 0x0f {
+	case 0x07:
+		/* E Accumulator Offset from Register */
+		offset = uint16(c.e.int8())
+	case 0x0a:
+		/* F Accumulator Offset from Register */
+		offset = uint16(c.f.int8())
+	case 0x0e:
+		/* W Accumulator Offset from Register */
+		offset = c.w()
+	default:
+		return 0, false
+	}
+	c.clock++
+	return c.readIndexedRegister(postbyte) + offset, true
+}
+
+func (c *CPU) initHD6309Opcodes() {
+	for _, in := range hd6309Instructions {
+		c.opcodes.set(in.code, c.bind(in))
+	}
+}
+
+/*********************************/
+/**     HD6309 instructions     **/
+/*********************************/
+
+/** Logical OR Immediate into Memory - NxZxV0 */
+func (c *CPU) oim(value uint16, address uint16) {
+	c.writeInt(address, c.or_(c.readInt(address), c.readInt(value)))
+}
+
+/** Logical AND Immediate into Memory - NxZxV0 */
+func (c *CPU) aim(value uint16, address uint16) {
+	c.writeInt(address, c.and_(c.readInt(address), c.readInt(value)))
+}
+
+/** Exclusive OR Immediate into Memory - NxZxV0 */
+func (c *CPU) eim(value uint16, address uint16) {
+	c.writeInt(address, c.eor_(c.readInt(address), c.readInt(value)))
+}
+
+/** Test Immediate with Memory - NxZxV0 */
+func (c *CPU) tim(value uint16, address uint16) {
+	c.and_(c.readInt(address), c.readInt(value))
+}
+
+/** Sign Extend W into Q - NxZx */
+func (c *CPU) sexw() {
+	if c.e.get()&0x80 != 0 {
+		c.setD(0xffff)
+	} else {
+		c.setD(0)
+	}
+	c.updateNZ32(c.q())
+}
+
+/** Load Register Q from Memory - NxZxV0 */
+func (c *CPU) ldq(address uint16) {
+	value := uint32(c.readw(address))<<16 | uint32(c.readw(address+2))
+	c.setQ(value)
+	c.updateNZ32(value)
+	c.cc.clearV()
+}
+
+/** Store Register Q into Memory - NxZxV0 */
+func (c *CPU) stq(address uint16) {
+	value := c.q()
+	c.writew(address, uint16(value>>16))
+	c.writew(address+2, uint16(value))
+	c.updateNZ32(value)
+	c.cc.clearV()
+}
+
+/** Register to register operation: r1 = r1 op r0, the source is truncated to an 8-bit target */
+func (c *CPU) interRegister(address uint16, op8 func(int, int) int, op16 func(int, int) int, store bool) {
+	code := c.readInt(address)
+	r0, r1 := code>>4, code&0x0f
+	value := int(c.getRegisterFromCode(r0))
+	reg := int(c.getRegisterFromCode(r1))
+	var result int
+	if r1&0x08 != 0 {
+		result = op8(reg, value&0xff)
+	} else {
+		result = op16(reg, value)
+	}
+	if store {
+		c.setRegisterFromCode(r1, uint16(result))
+	}
+}
+
+/** Add Register to Register - NxZxVxCx */
+func (c *CPU) addr(address uint16) {
+	c.interRegister(address, c.add_, c.add16_, true)
+}
+
+/** Add with Carry Register to Register - NxZxVxCx */
+func (c *CPU) adcr(address uint16) {
+	c.interRegister(address, c.adc_, c.adc16_, true)
+}
+
+/** Subtract Register from Register - NxZxVxCx */
+func (c *CPU) subr(address uint16) {
+	c.interRegister(address, c.sub_, c.sub16_, true)
+}
+
+/** Subtract with Borrow Register from Register - NxZxVxCx */
+func (c *CPU) sbcr(address uint16) {
+	c.interRegister(address, c.sbc_, c.sbc16_, true)
+}
+
+/** Logical AND Register into Register - NxZxV0 */
+func (c *CPU) andr(address uint16) {
+	c.interRegister(address, c.and_, c.and16_, true)
+}
+
+/** Logical OR Register into Register - NxZxV0 */
+func (c *CPU) orr(address uint16) {
+	c.interRegister(address, c.or_, c.or16_, true)
+}
+
+/** Exclusive OR Register into Register - NxZxV0 */
+func (c *CPU) eorr(address uint16) {
+	c.interRegister(address, c.eor_, c.eor16_, true)
+}
+
+/** Compare Register to Register - NxZxVxCx */
+func (c *CPU) cmpr(address uint16) {
+	c.interRegister(address, c.sub_, c.sub16_, false)
+}
+
+func (c *CPU) pushW(stack r16) {
+	stack.dec().dec()
+	c.writew(stack.uint16(), c.w())
+}
+
+func (c *CPU) pullW(stack r16) {
+	c.setW(c.readw(stack.uint16()))
+	stack.inc().inc()
+}
+
+/** Push Register W on the Hardware Stack */
+func (c *CPU) pshsw() {
+	c.pushW(c.s)
+}
+
+/** Pull Register W from the Hardware Stack */
+func (c *CPU) pulsw() {
+	c.pullW(c.s)
+}
+
+/** Push Register W on the User Stack */
+func (c *CPU) pshuw() {
+	c.pushW(c.u)
+}
+
+/** Pull Register W from the User Stack */
+func (c *CPU) puluw() {
+	c.pullW(c.u)
+}
+
+/** Add with Carry into Register (16 bits) - NxZxVxCx */
+func (c *CPU) adc16_(reg int, value int) int {
+	carry := 0
+	if c.cc.getC() {
+		carry = 1
+	}
+	tmp := reg + value + carry
+	c.updateNZVC16(reg, value, tmp)
+	return tmp
+}
+
+/** Subtract with Borrow (16 bits) - NxZxVxCx */
+func (c *CPU) sbc16_(reg int, value int) int {
+	borrow := 0
+	if c.cc.getC() {
+		borrow = 1
+	}
+	tmp := reg - value - borrow
+	c.updateNZVC16(reg, value, tmp)
+	return tmp
+}
+
+/** Logical AND (16 bits) - NxZxV0 */
+func (c *CPU) and16_(reg int, value int) int {
+	tmp := reg & value
+	c.updateNZ16(tmp)
+	c.cc.clearV()
+	return tmp
+}
+
+/** Logical OR (16 bits) - NxZxV0 */
+func (c *CPU) or16_(reg int, value int) int {
+	tmp := reg | value
+	c.updateNZ16(tmp)
+	c.cc.clearV()
+	return tmp
+}
+
+/** Exclusive OR (16 bits) - NxZxV0 */
+func (c *CPU) eor16_(reg int, value int) int {
+	tmp := reg ^ value
+	c.updateNZ16(tmp)
+	c.cc.clearV()
+	return tmp
+}
+
+/** Negate (16 bits) - NxZxVxCx */
+func (c *CPU) neg16_(value int) int {
+	tmp := -value
+	c.updateNZVC16(0, value, tmp)
+	return tmp
+}
+
+/** Complement (16 bits) - NxZxV0C1 */
+func (c *CPU) com16_(value int) int {
+	tmp := value ^ 0xffff
+	c.updateNZ16(tmp)
+	c.cc.clearV()
+	c.cc.setC()
+	return tmp
+}
+
+/** Logical Shift Right (16 bits) - N0ZxCx */
+func (c *CPU) lsr16_(value int) int {
+	tmp := value >> 1
+	c.updateNZ16(tmp)
+	c.updateC(value&1 == 1)
+	return tmp
+}
+
+/** Rotate Right (16 bits) - NxZxCx */
+func (c *CPU) ror16_(value int) int {
+	tmp := value >> 1
+	if c.cc.getC() {
+		tmp |= 0x8000
+	}
+	c.updateNZ16(tmp)
+	c.updateC(value&1 == 1)
+	return tmp
+}
+
+/** Arithmetic Shift Right (16 bits) - NxZxCx */
+func (c *CPU) asr16_(value int) int {
+	tmp := value>>1 | value&0x8000
+	c.updateNZ16(tmp)
+	c.updateC(value&1 == 1)
+	return tmp
+}
+
+/** Arithmetic Shift Left (16 bits) - NxZxVxCx */
+func (c *CPU) asl16_(value int) int {
+	tmp := value << 1
+	c.updateNZVC16(value, value, tmp)
+	return tmp
+}
+
+/** Rotate Left (16 bits) - NxZxVxCx */
+func (c *CPU) rol16_(value int) int {
+	tmp := value << 1
+	if c.cc.getC() {
+		tmp |= 1
+	}
+	c.updateNZVC16(value, value, tmp)
+	return tmp
+}
+
+/** Decrement (16 bits) - NxZxVx */
+func (c *CPU) dec16_(value int) int {
+	tmp := value - 1
+	c.updateNZ16(tmp)
+	c.updateV(value == 0x8000)
+	return tmp
+}
+
+/** Increment (16 bits) - NxZxVx */
+func (c *CPU) inc16_(value int) int {
+	tmp := value + 1
+	c.updateNZ16(tmp)
+	c.updateV(value == 0x7fff)
+	return tmp
+}
+
+/** Test (16 bits) - NxZxV0 */
+func (c *CPU) tst16_(value int) {
+	c.updateNZ16(value)
+	c.cc.clearV()
+}
+
+/** Clear N0Z1V0C0 */
+func (c *CPU) clr_() int {
+	c.cc.clearN()
+	c.cc.setZ()
+	c.cc.clearV()
+	c.cc.clearC()
+	return 0
+}
+
+/** Negate Register D - NxZxVxCx */
+func (c *CPU) negd() {
+	c.setD(uint16(c.neg16_(int(c.d()))))
+}
+
+/** Complement Register D - NxZxV0C1 */
+func (c *CPU) comd() {
+	c.setD(uint16(c.com16_(int(c.d()))))
+}
+
+/** Logical Shift Right Register D - N0ZxCx */
+func (c *CPU) lsrd() {
+	c.setD(uint16(c.lsr16_(int(c.d()))))
+}
+
+/** Rotate Right Register D - NxZxCx */
+func (c *CPU) rord() {
+	c.setD(uint16(c.ror16_(int(c.d()))))
+}
+
+/** Arithmetic Shift Right Register D - NxZxCx */
+func (c *CPU) asrd() {
+	c.setD(uint16(c.asr16_(int(c.d()))))
+}
+
+/** Arithmetic Shift Left Register D - NxZxVxCx */
+func (c *CPU) asld() {
+	c.setD(uint16(c.asl16_(int(c.d()))))
+}
+
+/** Rotate Left Register D - NxZxVxCx */
+func (c *CPU) rold() {
+	c.setD(uint16(c.rol16_(int(c.d()))))
+}
+
+/** Decrement Register D - NxZxVx */
+func (c *CPU) decd() {
+	c.setD(uint16(c.dec16_(int(c.d()))))
+}
+
+/** Increment Register D - NxZxVx */
+func (c *CPU) incd() {
+	c.setD(uint16(c.inc16_(int(c.d()))))
+}
+
+/** Test Register D - NxZxV0 */
+func (c *CPU) tstd() {
+	c.tst16_(int(c.d()))
+}
+
+/** Clear Register D - N0Z1V0C0 */
+func (c *CPU) clrd() {
+	c.setD(uint16(c.clr_()))
+}
+
+/** Complement Register W - NxZxV0C1 */
+func (c *CPU) comw() {
+	c.setW(uint16(c.com16_(int(c.w()))))
+}
+
+/** Logical Shift Right Register W - N0ZxCx */
+func (c *CPU) lsrw() {
+	c.setW(uint16(c.lsr16_(int(c.w()))))
+}
+
+/** Rotate Right Register W - NxZxCx */
+func (c *CPU) rorw() {
+	c.setW(uint16(c.ror16_(int(c.w()))))
+}
+
+/** Rotate Left Register W - NxZxVxCx */
+func (c *CPU) rolw() {
+	c.setW(uint16(c.rol16_(int(c.w()))))
+}
+
+/** Decrement Register W - NxZxVx */
+func (c *CPU) decw() {
+	c.setW(uint16(c.dec16_(int(c.w()))))
+}
+
+/** Increment Register W - NxZxVx */
+func (c *CPU) incw() {
+	c.setW(uint16(c.inc16_(int(c.w()))))
+}
+
+/** Test Register W - NxZxV0 */
+func (c *CPU) tstw() {
+	c.tst16_(int(c.w()))
+}
+
+/** Clear Register W - N0Z1V0C0 */
+func (c *CPU) clrw() {
+	c.setW(uint16(c.clr_()))
+}
+
+/** Complement Register E - NxZxV0C1 */
+func (c *CPU) come() {
+	c.e.set(c.com_(c.e.get()))
+}
+
+/** Decrement Register E - NxZxVx */
+func (c *CPU) dece() {
+	c.e.set(c.dec_(c.e.get()))
+}
+
+/** Increment Register E - NxZxVx */
+func (c *CPU) ince() {
+	c.e.set(c.inc_(c.e.get()))
+}
+
+/** Test Register E - NxZxV0 */
+func (c *CPU) tste() {
+	c.tst_(c.e.get())
+}
+
+/** Clear Register E - N0Z1V0C0 */
+func (c *CPU) clre() {
+	c.e.set(c.clr_())
+}
+
+/** Complement Register F - NxZxV0C1 */
+func (c *CPU) comf() {
+	c.f.set(c.com_(c.f.get()))
+}
+
+/** Decrement Register F - NxZxVx */
+func (c *CPU) decf() {
+	c.f.set(c.dec_(c.f.get()))
+}
+
+/** Increment Register F - NxZxVx */
+func (c *CPU) incf() {
+	c.f.set(c.inc_(c.f.get()))
+}
+
+/** Test Register F - NxZxV0 */
+func (c *CPU) tstf() {
+	c.tst_(c.f.get())
+}
+
+/** Clear Register F - N0Z1V0C0 */
+func (c *CPU) clrf() {
+	c.f.set(c.clr_())
+}
+
+/** Subtract Memory from Register W - NxZxVxCx */
+func (c *CPU) subw(address uint16) {
+	c.setW(uint16(c.sub16_(int(c.w()), c.readwInt(address))))
+}
+
+/** Compare Memory from Register W - NxZxVxCx */
+func (c *CPU) cmpw(address uint16) {
+	c.sub16_(int(c.w()), c.readwInt(address))
+}
+
+/** Subtract Memory with Borrow from Register D - NxZxVxCx */
+func (c *CPU) sbcd(address uint16) {
+	c.setD(uint16(c.sbc16_(int(c.d()), c.readwInt(address))))
+}
+
+/** Logical AND Memory into Register D - NxZxV0 */
+func (c *CPU) andd(address uint16) {
+	c.setD(uint16(c.and16_(int(c.d()), c.readwInt(address))))
+}
+
+/** Logical AND Memory and Register D - NxZxV0 */
+func (c *CPU) bitd(address uint16) {
+	c.and16_(int(c.d()), c.readwInt(address))
+}
+
+/** Load Register W from Memory - NxZxV0 */
+func (c *CPU) ldw(address uint16) {
+	value := c.readwInt(address)
+	c.updateNZ16(value)
+	c.cc.clearV()
+	c.setW(uint16(value))
+}
+
+/** Store Register W into Memory - NxZxV0 */
+func (c *CPU) stw(address uint16) {
+	tmp := int(c.w())
+	c.writewInt(address, tmp)
+	c.updateNZ16(tmp)
+	c.cc.clearV()
+}
+
+/** Exclusive OR Memory into Register D - NxZxV0 */
+func (c *CPU) eord(address uint16) {
+	c.setD(uint16(c.eor16_(int(c.d()), c.readwInt(address))))
+}
+
+/** Add with Carry Memory into Register D - NxZxVxCx */
+func (c *CPU) adcd(address uint16) {
+	c.setD(uint16(c.adc16_(int(c.d()), c.readwInt(address))))
+}
+
+/** Logical OR Memory into Register D - NxZxV0 */
+func (c *CPU) ord(address uint16) {
+	c.setD(uint16(c.or16_(int(c.d()), c.readwInt(address))))
+}
+
+/** Add Memory into Register W - NxZxVxCx */
+func (c *CPU) addw(address uint16) {
+	c.setW(uint16(c.add16_(int(c.w()), c.readwInt(address))))
+}
+
+/** Subtract Memory from Register E - NxZxVxCx */
+func (c *CPU) sube(address uint16) {
+	c.e.set(c.sub_(c.e.get(), c.readInt(address)))
+}
+
+/** Compare Memory from Register E - NxZxVxCx */
+func (c *CPU) cmpe(address uint16) {
+	c.sub_(c.e.get(), c.readInt(address))
+}
+
+/** Load Register E from Memory - NxZxV0 */
+func (c *CPU) lde(address uint16) {
+	value := c.readInt(address)
+	c.updateNZ(value)
+	c.cc.clearV()
+	c.e.set(value)
+}
+
+/** Store Register E into Memory - NxZxV0 */
+func (c *CPU) ste(address uint16) {
+	tmp := c.e.get()
+	c.writeInt(address, tmp)
+	c.updateNZ(tmp)
+	c.cc.clearV()
+}
+
+/** Add Memory into Register E - HxNxZxVxCx */
+func (c *CPU) adde(address uint16) {
+	c.e.set(c.add_(c.e.get(), c.readInt(address)))
+}
+
+/** Subtract Memory from Register F - NxZxVxCx */
+func (c *CPU) subf(address uint16) {
+	c.f.set(c.sub_(c.f.get(), c.readInt(address)))
+}
+
+/** Compare Memory from Register F - NxZxVxCx */
+func (c *CPU) cmpf(address uint16) {
+	c.sub_(c.f.get(), c.readInt(address))
+}
+
+/** Load Register F from Memory - NxZxV0 */
+func (c *CPU) ldf(address uint16) {
+	value := c.readInt(address)
+	c.updateNZ(value)
+	c.cc.clearV()
+	c.f.set(value)
+}
+
+/** Store Register F into Memory - NxZxV0 */
+func (c *CPU) stf(address uint16) {
+	tmp := c.f.get()
+	c.writeInt(address, tmp)
+	c.updateNZ(tmp)
+	c.cc.clearV()
+}
+
+/** Add Memory into Register F - HxNxZxVxCx */
+func (c *CPU) addf(address uint16) {
+	c.f.set(c.add_(c.f.get(), c.readInt(address)))
+}
+
+/** Signed Divide D by 8-bit Memory: quotient in B, remainder in A - NxZxVxCx */
+func (c *CPU) divd(address uint16) {
+	divisor := int(int8(c.read(address)))
+	if divisor == 0 {
+		c.trap(mdDivideByZero)
+		return
+	}
+	dividend := int(int16(c.d()))
+	quotient := dividend / divisor
+	if quotient < -128 || quotient > 127 { // the registers are left unchanged
+		c.cc.clearN()
+		c.cc.clearZ()
+		c.cc.setV()
+		c.cc.clearC()
+		return
+	}
+	c.a.set(dividend % divisor)
+	c.b.set(quotient)
+	c.updateNZ(quotient)
+	c.cc.clearV()
+	c.updateC(quotient&1 != 0)
+}
+
+/** Signed Divide Q by 16-bit Memory: quotient in W, remainder in D - NxZxVxCx */
+func (c *CPU) divq(address uint16) {
+	divisor := int(int16(c.readw(address)))
+	if divisor == 0 {
+		c.trap(mdDivideByZero)
+		return
+	}
+	dividend := int(int32(c.q()))
+	quotient := dividend / divisor
+	if quotient < -32768 || quotient > 32767 { // the registers are left unchanged
+		c.cc.clearN()
+		c.cc.clearZ()
+		c.cc.setV()
+		c.cc.clearC()
+		return
+	}
+	c.setD(uint16(dividend % divisor))
+	c.setW(uint16(quotient))
+	c.updateNZ16(quotient)
+	c.cc.clearV()
+	c.updateC(quotient&1 != 0)
+}
+
+/** Signed Multiply D by 16-bit Memory into Q - NxZxV0C0 */
+func (c *CPU) muld(address uint16) {
+	value := int32(int16(c.d())) * int32(int16(c.readw(address)))
+	c.setQ(uint32(value))
+	c.updateNZ32(uint32(value))
+	c.cc.clearV()
+	c.cc.clearC()
+}
+
+/** Register of a bit manipulation postbyte */
+func (c *CPU) bitRegister(postbyte int) (register, bool) {
+	switch postbyte >> 6 {
+	case 0:
+		return c.cc, true
+	case 1:
+		return c.a, true
+	case 2:
+		return c.b, true
+	default:
+		c.illegalOperand("invalid bit manipulation register in postbyte %02x", postbyte)
+		return nil, false
+	}
+}
+
+/** Combine a bit of a direct memory location into a register bit */
+func (c *CPU) bitOperation(code uint16, address uint16, op func(reg bool, mem bool) bool) {
+	postbyte := c.readInt(code)
+	reg, ok := c.bitRegister(postbyte)
+	if !ok {
+		return
+	}
+	src, dst := uint(postbyte>>3)&7, uint(postbyte)&7
+	mem := c.readInt(address)>>src&1 == 1
+	value := reg.get()
+	if op(value>>dst&1 == 1, mem) {
+		reg.set(value | 1<<dst)
+	} else {
+		reg.set(value &^ (1 << dst))
+	}
+}
+
+/** Logical AND Memory Bit into Register Bit */
+func (c *CPU) band(code uint16, address uint16) {
+	c.bitOperation(code, address, func(reg bool, mem bool) bool { return reg && mem })
+}
+
+/** Logical AND Inverted Memory Bit into Register Bit */
+func (c *CPU) biand(code uint16, address uint16) {
+	c.bitOperation(code, address, func(reg bool, mem bool) bool { return reg && !mem })
+}
+
+/** Logical OR Memory Bit into Register Bit */
+func (c *CPU) bor(code uint16, address uint16) {
+	c.bitOperation(code, address, func(reg bool, mem bool) bool { return reg || mem })
+}
+
+/** Logical OR Inverted Memory Bit into Register Bit */
+func (c *CPU) bior(code uint16, address uint16) {
+	c.bitOperation(code, address, func(reg bool, mem bool) bool { return reg || !mem })
+}
+
+/** Exclusive OR Memory Bit into Register Bit */
+func (c *CPU) beor(code uint16, address uint16) {
+	c.bitOperation(code, address, func(reg bool, mem bool) bool { return reg != mem })
+}
+
+/** Exclusive OR Inverted Memory Bit into Register Bit */
+func (c *CPU) bieor(code uint16, address uint16) {
+	c.bitOperation(code, address, func(reg bool, mem bool) bool { return reg == mem })
+}
+
+/** Load Memory Bit into Register Bit */
+func (c *CPU) ldbt(code uint16, address uint16) {
+	c.bitOperation(code, address, func(reg bool, mem bool) bool { return mem })
+}
+
+/** Store Register Bit into Memory Bit */
+func (c *CPU) stbt(code uint16, address uint16) {
+	postbyte := c.readInt(code)
+	reg, ok := c.bitRegister(postbyte)
+	if !ok {
+		return
+	}
+	src, dst := uint(postbyte>>3)&7, uint(postbyte)&7
+	value := c.readInt(address)
+	if reg.get()>>src&1 == 1 {
+		c.writeInt(address, value|1<<dst)
+	} else {
+		c.writeInt(address, value&^(1<<dst))
+	}
+}
+
+/** Transfer W bytes from the memory pointed by r0 to the memory pointed by r1. One
+ * byte is moved per step and the PC is left on the instruction until W reaches 0,
+ * so that the transfer is interruptible and resumes from the updated registers.
+ * The steps leaving the PC on the instruction only count the 3 cycles of the byte
+ * moved: an uninterrupted transfer takes 6+3*W cycles. */
+func (c *CPU) tfm(address uint16, srcStep int, dstStep int) {
+	code := c.readInt(address)
+	r0, r1 := code>>4, code&0x0f
+	if r0 > 4 || r1 > 4 {
+		c.illegalOperand("invalid block transfer registers %02x", code)
+		return
+	}
+	if c.w() == 0 {
+		return
+	}
+	src := c.getRegisterFromCode(r0)
+	dst := c.getRegisterFromCode(r1)
+	c.write(dst, c.read(src))
+	c.setRegisterFromCode(r0, src+uint16(srcStep))
+	c.setRegisterFromCode(r1, dst+uint16(dstStep))
+	c.setW(c.w() - 1)
+	c.clock += 3
+	if c.w() != 0 {
+		c.pc.set(int(address) - 2)
+		c.clock -= c.opcodes.get(c.op).cycles
+	}
+}
+
+/** Transfer Memory: r0+,r1+ */
+func (c *CPU) tfmForward(address uint16) {
+	c.tfm(address, 1, 1)
+}
+
+/** Transfer Memory: r0-,r1- */
+func (c *CPU) tfmBackward(address uint16) {
+	c.tfm(address, -1, -1)
+}
+
+/** Transfer Memory: r0+,r1 */
+func (c *CPU) tfmSource(address uint16) {
+	c.tfm(address, 1, 0)
+}
+
+/** Transfer Memory: r0,r1+ */
+func (c *CPU) tfmDestination(address uint16) {
+	c.tfm(address, 0, 1)
+}
+
+/** Test and clear the trap flags of the MD register - Zx */
+func (c *CPU) bitmd(address uint16) {
+	value := c.md.get() & c.readInt(address) & (mdIllegal | mdDivideByZero)
+	c.updateZ(value)
+	c.md.set(c.md.get() &^ value)
+}
+
+/** Load the mode bits of the MD register */
+func (c *CPU) ldmd(address uint16) {
+	c.md.set(c.md.get()&^(mdNative|mdFIRQ) | c.readInt(address)&(mdNative|mdFIRQ))
+}
@@ -0,0 +1,319 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HD6309", func() {
+	var (
+		cpu *CPU
+	)
+
+	BeforeEach(func() {
+		cpu = NewCPU(NewRam(), HD6309)
+		cpu.pc.set(0x1000)
+		cpu.s.set(0x2000)
+		cpu.writew(0xfff0, 0xc000)
+	})
+
+	It("should not decode HD6309 opcodes on a MC6809", func() {
+		mc6809 := NewCPU(NewRam(), MC6809)
+		mc6809.pc.set(0x1000)
+		mc6809.writew(0x1000, 0x1086) // LDW #$1234
+		_, err := mc6809.step()
+		Expect(err).To(HaveOccurred())
+		Expect(cpu.Variant()).To(Equal(HD6309))
+	})
+
+	It("should load Q with LDQ immediate", func() {
+		cpu.write(0x1000, 0xcd)
+		cpu.writew(0x1001, 0x8012)
+		cpu.writew(0x1003, 0x3456)
+		cpu.step()
+
+		ExpectD(*cpu, 0x8012)
+		Expect(cpu.w()).To(BeEquivalentTo(0x3456))
+		ExpectPC(*cpu, 0x1005)
+		ExpectClock(*cpu, 5)
+		ExpectCCR(*cpu, "N", "ZV")
+	})
+
+	It("should store Q with STQ extended", func() {
+		cpu.setQ(0x01020304)
+		cpu.writew(0x1000, 0x10fd)
+		cpu.writew(0x1002, 0x3000)
+		cpu.step()
+
+		ExpectWord(*cpu, 0x3000, 0x0102)
+		ExpectWord(*cpu, 0x3002, 0x0304)
+		ExpectClock(*cpu, 9)
+	})
+
+	It("should transfer W and V with TFR", func() {
+		cpu.setW(0xbeef)
+		cpu.writew(0x1000, 0x1f67) // TFR W,V
+		cpu.step()
+		Expect(cpu.v.uint16()).To(BeEquivalentTo(0xbeef))
+
+		cpu.writew(0x1002, 0x1fc1) // TFR 0,X
+		cpu.x.set(0x1234)
+		cpu.step()
+		ExpectX(*cpu, 0)
+	})
+
+	It("should add registers with ADDR", func() {
+		cpu.x.set(0x1234)
+		cpu.y.set(0x0101)
+		cpu.writew(0x1000, 0x1030)
+		cpu.write(0x1002, 0x12) // ADDR X,Y
+		cpu.step()
+
+		ExpectY(*cpu, 0x1335)
+		ExpectClock(*cpu, 4)
+	})
+
+	It("should compare registers with CMPR", func() {
+		cpu.a.set(0x10)
+		cpu.e.set(0x10)
+		cpu.writew(0x1000, 0x1037)
+		cpu.write(0x1002, 0x8e) // CMPR A,E
+		cpu.step()
+
+		ExpectCCR(*cpu, "Z", "NC")
+	})
+
+	It("should operate on D with the inherent 16-bit instructions", func() {
+		cpu.setD(0x8001)
+		cpu.writew(0x1000, 0x1048) // ASLD
+		cpu.step()
+
+		ExpectD(*cpu, 0x0002)
+		ExpectCCR(*cpu, "VC", "NZ")
+		ExpectClock(*cpu, 3)
+	})
+
+	It("should move a block of memory with TFM", func() {
+		for i := uint16(0); i < 4; i++ {
+			cpu.write(0x3000+i, uint8(0xa0+i))
+		}
+		cpu.x.set(0x3000)
+		cpu.y.set(0x4000)
+		cpu.setW(4)
+		cpu.writew(0x1000, 0x1138)
+		cpu.write(0x1002, 0x12) // TFM X+,Y+
+		for cpu.PC() != 0x1003 {
+			cpu.step()
+		}
+
+		ExpectWord(*cpu, 0x4000, 0xa0a1)
+		ExpectWord(*cpu, 0x4002, 0xa2a3)
+		ExpectX(*cpu, 0x3004)
+		ExpectY(*cpu, 0x4004)
+		Expect(cpu.w()).To(BeEquivalentTo(0))
+		ExpectClock(*cpu, 6+3*4)
+	})
+
+	It("should take interrupts during TFM and resume the transfer", func() {
+		cpu.x.set(0x3000)
+		cpu.y.set(0x4000)
+		cpu.setW(3)
+		cpu.writew(0x1000, 0x1138)
+		cpu.write(0x1002, 0x12) // TFM X+,Y+
+		cpu.writew(0xfff8, 0xc100)
+		cpu.write(0xc100, 0x3b) // RTI
+		cpu.step()
+		ExpectPC(*cpu, 0x1000)
+		ExpectClock(*cpu, 3)
+		ExpectX(*cpu, 0x3001)
+		Expect(cpu.w()).To(BeEquivalentTo(2))
+
+		cpu.AssertIRQ()
+		cpu.step()
+		ExpectPC(*cpu, 0xc100)
+		ExpectWord(*cpu, 0x2000-2, 0x1000)
+		cpu.ReleaseIRQ()
+		cpu.step()
+		for cpu.PC() != 0x1003 {
+			cpu.step()
+		}
+
+		ExpectX(*cpu, 0x3003)
+		ExpectY(*cpu, 0x4003)
+		Expect(cpu.w()).To(BeEquivalentTo(0))
+	})
+
+	It("should divide D with DIVD", func() {
+		cpu.setD(0xff9c)           // -100
+		cpu.writew(0x1000, 0x118d) // DIVD #7
+		cpu.write(0x1002, 0x07)
+		cpu.step()
+
+		ExpectB(*cpu, 0xf2) // -14
+		ExpectA(*cpu, 0xfe) // -2
+		ExpectCCR(*cpu, "N", "ZVC")
+		ExpectClock(*cpu, 25)
+	})
+
+	It("should set V when the quotient of DIVD overflows", func() {
+		cpu.setD(0x1000)
+		cpu.writew(0x1000, 0x118d) // DIVD #2
+		cpu.write(0x1002, 0x02)
+		cpu.step()
+
+		ExpectD(*cpu, 0x1000)
+		ExpectCCR(*cpu, "V", "NZC")
+	})
+
+	It("should divide Q with DIVQ", func() {
+		cpu.setQ(100000)
+		cpu.writew(0x1000, 0x118e) // DIVQ #7
+		cpu.writew(0x1002, 0x0007)
+		cpu.step()
+
+		Expect(cpu.w()).To(BeEquivalentTo(14285))
+		ExpectD(*cpu, 5)
+		ExpectCCR(*cpu, "C", "NZV")
+	})
+
+	It("should multiply D with MULD", func() {
+		cpu.setD(0xfffe)           // -2
+		cpu.writew(0x1000, 0x118f) // MULD #$4000
+		cpu.writew(0x1002, 0x4000)
+		cpu.step()
+
+		Expect(cpu.q()).To(BeEquivalentTo(0xffff8000))
+		ExpectCCR(*cpu, "N", "ZVC")
+		ExpectClock(*cpu, 28)
+	})
+
+	It("should take the trap on a division by zero", func() {
+		cpu.cc.set(0x00)
+		cpu.writew(0x1000, 0x118d) // DIVD #0
+		cpu.write(0x1002, 0x00)
+		cpu.step()
+
+		ExpectPC(*cpu, 0xc000)
+		ExpectWord(*cpu, 0x2000-2, 0x1003)
+		ExpectS(*cpu, 0x2000-12)
+		ExpectCCR(*cpu, "EFI", "")
+		Expect(cpu.md.get() & mdDivideByZero).NotTo(BeZero())
+
+		cpu.writew(0xc000, 0x113c) // BITMD #$80
+		cpu.write(0xc002, 0x80)
+		cpu.step()
+		ExpectCCR(*cpu, "", "Z")
+		Expect(cpu.md.get()).To(BeZero())
+	})
+
+	It("should take the trap on an illegal opcode", func() {
+		cpu.write(0x1000, 0x87)
+		_, err := cpu.step()
+
+		Expect(err).NotTo(HaveOccurred())
+		ExpectPC(*cpu, 0xc000)
+		ExpectWord(*cpu, 0x2000-2, 0x1001)
+		ExpectClock(*cpu, 20)
+		Expect(cpu.md.get() & mdIllegal).NotTo(BeZero())
+	})
+
+	It("should take the trap on an illegal opcode whatever the illegal opcode policy", func() {
+		cpu.IllegalOpcodes = EmulateUndocumented
+		cpu.writew(0x1000, 0x1087)
+		_, err := cpu.step()
+
+		Expect(err).NotTo(HaveOccurred())
+		ExpectPC(*cpu, 0xc000)
+		Expect(cpu.md.get() & mdIllegal).NotTo(BeZero())
+	})
+
+	It("should apply AIM to memory", func() {
+		cpu.dp.set(0x20)
+		cpu.write(0x1000, 0x02) // AIM #$0f,<$10
+		cpu.write(0x1001, 0x0f)
+		cpu.write(0x1002, 0x10)
+		cpu.write(0x2010, 0x5a)
+		cpu.step()
+
+		ExpectMemory(*cpu, 0x2010, 0x0a)
+		ExpectPC(*cpu, 0x1003)
+		ExpectClock(*cpu, 6)
+	})
+
+	It("should combine memory bits into register bits", func() {
+		cpu.dp.set(0x20)
+		cpu.write(0x2010, 0x08)
+		cpu.a.set(0x00)
+		cpu.writew(0x1000, 0x1136) // LDBT A,3,0,<$10
+		cpu.write(0x1002, 0x58)
+		cpu.write(0x1003, 0x10)
+		cpu.step()
+		ExpectA(*cpu, 0x01)
+
+		cpu.b.set(0x80)
+		cpu.writew(0x1004, 0x1137) // STBT B,7,1,<$10
+		cpu.write(0x1006, 0xb9)
+		cpu.write(0x1007, 0x10)
+		cpu.step()
+		ExpectMemory(*cpu, 0x2010, 0x0a)
+	})
+
+	It("should address memory with the W indexed modes", func() {
+		cpu.setW(0x3000)
+		cpu.write(0x3000, 0x42)
+		cpu.writew(0x1000, 0xa6cf) // LDA ,W++
+		cpu.step()
+
+		ExpectA(*cpu, 0x42)
+		Expect(cpu.w()).To(BeEquivalentTo(0x3002))
+
+		cpu.x.set(0x3000)
+		cpu.e.set(0xff)
+		cpu.write(0x2fff, 0x24)
+		cpu.writew(0x1002, 0xe687) // LDB E,X
+		cpu.step()
+		ExpectB(*cpu, 0x24)
+	})
+
+	Context("[Native mode]", func() {
+
+		BeforeEach(func() {
+			cpu.writew(0x1000, 0x113d) // LDMD #$01
+			cpu.write(0x1002, 0x01)
+			cpu.step()
+			cpu.clock = 0
+		})
+
+		It("should run faster", func() {
+			cpu.write(0x1003, 0x12) // NOP
+			cpu.step()
+			ExpectClock(*cpu, 1)
+		})
+
+		It("should stack W on interrupts", func() {
+			cpu.writew(0xfff8, 0xc200)
+			cpu.write(0xc200, 0x3b) // RTI
+			cpu.write(0x1003, 0x12) // NOP
+			cpu.setW(0xabcd)
+			cpu.armNMI()
+			cpu.AssertIRQ()
+			cpu.step()
+
+			ExpectS(*cpu, 0x2000-14)
+			ExpectWord(*cpu, 0x2000-11, 0xabcd)
+			ExpectClock(*cpu, 21)
+
+			cpu.ReleaseIRQ()
+			cpu.setW(0)
+			cpu.step()
+			ExpectPC(*cpu, 0x1003)
+			ExpectS(*cpu, 0x2000)
+			Expect(cpu.w()).To(BeEquivalentTo(0xabcd))
+		})
+
+		It("should be left on reset", func() {
+			cpu.Reset()
+			Expect(cpu.nativeMode()).To(BeFalse())
+		})
+	})
+})
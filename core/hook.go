@@ -0,0 +1,93 @@
+package core
+
+// InstructionEvent describes an instruction executed by the CPU
+type InstructionEvent struct {
+	/// Address of the instruction
+	PC uint16
+	/// Opcode including the page prefix
+	Opcode int
+	/// Effective address computed by the addressing mode, -1 for inherent
+	/// instructions and before the instruction is executed
+	Address int
+	/// Clock when the instruction starts
+	Clock uint64
+	/// Number of cycles of the instruction, 0 before it is executed
+	Cycles uint64
+}
+
+// Hook observes the execution of the CPU, to build tracers, profilers, coverage or
+// breakpoints. The hooks are called synchronously by the CPU and must not execute
+// instructions themselves. A hook can call Stop to end RunCycles or RunUntil after
+// the current instruction. When Stop is called from BeforeInstruction, the
+// instruction is not executed: the PC is left on it and it is fetched again by the
+// next step.
+type Hook interface {
+	// BeforeInstruction is called when an instruction has been decoded, before it
+	// is executed
+	BeforeInstruction(c *CPU, event InstructionEvent)
+	// AfterInstruction is called when an instruction has been executed
+	AfterInstruction(c *CPU, event InstructionEvent)
+	// MemoryAccess is called on every memory read or write performed by the CPU
+	MemoryAccess(c *CPU, access Access)
+}
+
+// HookFuncs is a Hook calling the functions which are set
+type HookFuncs struct {
+	Before func(c *CPU, event InstructionEvent)
+	After  func(c *CPU, event InstructionEvent)
+	Access func(c *CPU, access Access)
+}
+
+// BeforeInstruction calls Before if it is set
+func (h *HookFuncs) BeforeInstruction(c *CPU, event InstructionEvent) {
+	if h.Before != nil {
+		h.Before(c, event)
+	}
+}
+
+// AfterInstruction calls After if it is set
+func (h *HookFuncs) AfterInstruction(c *CPU, event InstructionEvent) {
+	if h.After != nil {
+		h.After(c, event)
+	}
+}
+
+// MemoryAccess calls Access if it is set
+func (h *HookFuncs) MemoryAccess(c *CPU, access Access) {
+	if h.Access != nil {
+		h.Access(c, access)
+	}
+}
+
+// AddHook registers a hook. The hooks are called in registration order.
+func (c *CPU) AddHook(h Hook) {
+	c.hooks = append(c.hooks, h)
+}
+
+// RemoveHook unregisters a hook
+func (c *CPU) RemoveHook(h Hook) {
+	for i, hook := range c.hooks {
+		if hook == h {
+			c.hooks = append(c.hooks[:i:i], c.hooks[i+1:]...)
+			break
+		}
+	}
+	if len(c.hooks) == 0 {
+		c.hooks = nil
+	}
+}
+
+/** Report a memory access to Step and to the hooks */
+func (c *CPU) observe(access Access) {
+	access.PC = c.instruction
+	access.Opcode = c.op
+	if !c.CycleExact {
+		access.Cycle = c.bus
+	}
+	if c.recording {
+		c.accesses = append(c.accesses, access)
+	}
+	for _, h := range c.hooks {
+		h.MemoryAccess(c, access)
+	}
+}
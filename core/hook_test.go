@@ -0,0 +1,113 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Execution hooks", func() {
+	var (
+		cpu    *CPU
+		before []InstructionEvent
+		after  []InstructionEvent
+		access []Access
+		hook   *HookFuncs
+	)
+
+	BeforeEach(func() {
+		cpu = NewCPU(NewRam(), MC6809)
+		cpu.pc.set(0x1000)
+		cpu.s.set(0x2000)
+		before, after, access = nil, nil, nil
+		hook = &HookFuncs{
+			Before: func(c *CPU, e InstructionEvent) { before = append(before, e) },
+			After:  func(c *CPU, e InstructionEvent) { after = append(after, e) },
+			Access: func(c *CPU, a Access) { access = append(access, a) },
+		}
+	})
+
+	It("should call the hooks around an instruction", func() {
+		cpu.write(0x1000, 0xb6) // LDA $3000
+		cpu.writew(0x1001, 0x3000)
+		cpu.write(0x3000, 0x5a)
+		cpu.clock = 10
+		cpu.AddHook(hook)
+		cpu.step()
+
+		Expect(before).To(Equal([]InstructionEvent{{PC: 0x1000, Opcode: 0xb6, Address: -1, Clock: 10}}))
+		Expect(after).To(Equal([]InstructionEvent{{PC: 0x1000, Opcode: 0xb6, Address: 0x3000, Clock: 10, Cycles: 5}}))
+		Expect(access).To(Equal([]Access{
+			{PC: 0x1000, Opcode: -1, Address: 0x1000, Value: 0xb6, Cycle: 10},
+			{PC: 0x1000, Opcode: 0xb6, Address: 0x1001, Value: 0x3000, Word: true, Cycle: 10},
+			{PC: 0x1000, Opcode: 0xb6, Address: 0x3000, Value: 0x5a, Cycle: 10},
+		}))
+	})
+
+	It("should report the accesses of the interrupt sequences with the interrupted PC", func() {
+		cpu.armNMI()
+		cpu.AssertNMI()
+		cpu.clock = 20
+		cpu.AddHook(hook)
+		cpu.step()
+
+		Expect(access).NotTo(BeEmpty())
+		for _, a := range access {
+			Expect(a.PC).To(BeEquivalentTo(0x1000))
+			Expect(a.Opcode).To(Equal(-1))
+			Expect(a.Cycle).To(BeEquivalentTo(20))
+		}
+	})
+
+	It("should report no effective address for inherent instructions", func() {
+		cpu.write(0x1000, 0x4f) // CLRA
+		cpu.AddHook(hook)
+		cpu.step()
+
+		Expect(after).To(HaveLen(1))
+		Expect(after[0].Opcode).To(Equal(0x4f))
+		Expect(after[0].Address).To(Equal(-1))
+		Expect(after[0].Cycles).To(BeEquivalentTo(2))
+	})
+
+	It("should report the write accesses", func() {
+		cpu.a.set(0x2f)
+		cpu.write(0x1000, 0x97) // STA <$10
+		cpu.write(0x1001, 0x10)
+		cpu.AddHook(hook)
+		cpu.step()
+
+		Expect(access).To(ContainElement(Access{PC: 0x1000, Opcode: 0x97, Address: 0x0010, Value: 0x2f, Write: true}))
+		Expect(after[0].Address).To(Equal(0x0010))
+	})
+
+	It("should stop the execution when a hook calls Stop", func() {
+		cpu.write(0x1000, 0x12) // NOP
+		cpu.write(0x1001, 0x12) // NOP
+		cpu.write(0x1002, 0x20) // BRA *
+		cpu.write(0x1003, 0xfe)
+		cpu.AddHook(&HookFuncs{Before: func(c *CPU, e InstructionEvent) {
+			if e.PC == 0x1002 {
+				c.Stop()
+			}
+		}})
+		cpu.RunCycles(1000)
+
+		ExpectPC(*cpu, 0x1002)
+	})
+
+	It("should not call removed hooks", func() {
+		cpu.write(0x1000, 0x12) // NOP
+		cpu.write(0x1001, 0x12) // NOP
+		other := &HookFuncs{}
+		cpu.AddHook(hook)
+		cpu.AddHook(other)
+		cpu.step()
+		cpu.RemoveHook(hook)
+		cpu.step()
+
+		Expect(before).To(HaveLen(1))
+		Expect(cpu.hooks).To(HaveLen(1))
+		cpu.RemoveHook(other)
+		Expect(cpu.hooks).To(BeNil())
+	})
+})
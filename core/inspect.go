@@ -0,0 +1,234 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+/* Memory inspection
+ *
+ * The inspection functions work on any Memory. They read through Peek when the
+ * memory implements Peeker, so that inspecting memory mapped devices has no side
+ * effect, and through Read otherwise.
+ */
+
+// Peeker is implemented by the memories which can be read without side effects.
+// Peek returns false for the addresses which cannot be read that way: unmapped
+// addresses and devices.
+type Peeker interface {
+	Peek(address uint16) (uint8, bool)
+}
+
+/** Read an address for inspection */
+func peek(m Memory, address uint16) (uint8, bool) {
+	if p, ok := m.(Peeker); ok {
+		return p.Peek(address)
+	}
+	return m.Read(address), true
+}
+
+// DumpRange writes the content of a range as hexadecimal and ASCII, 16 bytes per
+// line. The addresses which cannot be peeked are displayed as "--". Consecutive
+// identical lines are folded into a single "*" line.
+func DumpRange(w io.Writer, m Memory, from uint16, to uint16) error {
+	previous := ""
+	folded := false
+	for line := int(from) &^ 0xf; line <= int(to); line += 16 {
+		hexa := make([]string, 16)
+		ascii := make([]byte, 16)
+		for i := range hexa {
+			a := line + i
+			hexa[i], ascii[i] = "  ", ' '
+			if a < int(from) || a > int(to) {
+				continue
+			}
+			value, ok := peek(m, uint16(a))
+			if !ok {
+				hexa[i], ascii[i] = "--", '.'
+				continue
+			}
+			hexa[i], ascii[i] = fmt.Sprintf("%02x", value), '.'
+			if value >= 0x20 && value < 0x7f {
+				ascii[i] = value
+			}
+		}
+		content := strings.Join(hexa[0:8], " ") + "  " + strings.Join(hexa[8:16], " ") + " | " + string(ascii)
+		last := line+16 > int(to)
+		if content == previous && line != int(from)&^0xf && !last {
+			if !folded {
+				if _, err := io.WriteString(w, "*\n"); err != nil {
+					return err
+				}
+				folded = true
+			}
+			continue
+		}
+		previous, folded = content, false
+		if _, err := fmt.Fprintf(w, "%04x | %s\n", line, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fill writes a value to all the addresses of a range
+func Fill(m Memory, from uint16, to uint16, value uint8) {
+	for a := int(from); a <= int(to); a++ {
+		m.Write(uint16(a), value)
+	}
+}
+
+// Copy copies a range to the given destination, which must fit below $10000.
+// Overlapping ranges are copied as if through a temporary buffer and the addresses
+// which cannot be peeked are copied as $FF.
+func Copy(m Memory, from uint16, to uint16, dest uint16) error {
+	if from > to {
+		return fmt.Errorf("invalid range $%04x-$%04x", from, to)
+	}
+	n := int(to) - int(from) + 1
+	if int(dest)+n > 0x10000 {
+		return fmt.Errorf("%d bytes do not fit at $%04x", n, dest)
+	}
+	buf := make([]uint8, n)
+	for i := range buf {
+		value, ok := peek(m, from+uint16(i))
+		if !ok {
+			value = 0xff
+		}
+		buf[i] = value
+	}
+	for i, b := range buf {
+		m.Write(dest+uint16(i), b)
+	}
+	return nil
+}
+
+// Wildcard matches any byte in a Pattern
+const Wildcard = -1
+
+// Pattern is a sequence of bytes searched by Search, Wildcard matching any byte
+type Pattern []int
+
+// ParsePattern parses a pattern written as hexadecimal bytes separated by spaces,
+// "??" being a wildcard: "86 ?? b7".
+func ParsePattern(s string) (Pattern, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	p := make(Pattern, len(fields))
+	for i, f := range fields {
+		if f == "??" {
+			p[i] = Wildcard
+			continue
+		}
+		b, err := strconv.ParseUint(f, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern byte %q", f)
+		}
+		p[i] = int(b)
+	}
+	return p, nil
+}
+
+// StringPattern returns the pattern matching the bytes of a string, '?' being a
+// wildcard
+func StringPattern(s string) Pattern {
+	p := make(Pattern, len(s))
+	for i := range p {
+		p[i] = int(s[i])
+		if s[i] == '?' {
+			p[i] = Wildcard
+		}
+	}
+	return p
+}
+
+// Search returns the addresses where the pattern is found entirely within a range
+func Search(m Memory, from uint16, to uint16, p Pattern) []uint16 {
+	found := []uint16{}
+	for a := int(from); a+len(p)-1 <= int(to); a++ {
+		match := true
+		for i, b := range p {
+			if b == Wildcard {
+				continue
+			}
+			if value, ok := peek(m, uint16(a+i)); !ok || int(value) != b {
+				match = false
+				break
+			}
+		}
+		if match {
+			found = append(found, uint16(a))
+		}
+	}
+	return found
+}
+
+// CopyMemory returns a copy of the 64K of a memory, the addresses which cannot be
+// peeked being read as $FF
+func CopyMemory(m Memory) []uint8 {
+	s := make([]uint8, 0x10000)
+	for a := range s {
+		value, ok := peek(m, uint16(a))
+		if !ok {
+			value = 0xff
+		}
+		s[a] = value
+	}
+	return s
+}
+
+// Compare returns the ranges of addresses where two copies of a memory differ
+func Compare(a []uint8, b []uint8) []AddressRange {
+	ranges := []AddressRange{}
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		start := i
+		for i+1 < n && a[i+1] != b[i+1] {
+			i++
+		}
+		ranges = append(ranges, AddressRange{From: uint16(start), To: uint16(i)})
+	}
+	return ranges
+}
+
+// LoadRange writes the content of a file to memory from the given address and
+// returns the number of bytes loaded. The file must fit below $10000.
+func LoadRange(m Memory, path string, address uint16) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if int(address)+len(data) > 0x10000 {
+		return 0, fmt.Errorf("%s: %d bytes do not fit at $%04x", path, len(data), address)
+	}
+	for i, b := range data {
+		m.Write(address+uint16(i), b)
+	}
+	return len(data), nil
+}
+
+// SaveRange writes the content of a range to a file, the addresses which cannot
+// be peeked being saved as $FF
+func SaveRange(m Memory, path string, from uint16, to uint16) error {
+	var buf bytes.Buffer
+	for a := int(from); a <= int(to); a++ {
+		value, ok := peek(m, uint16(a))
+		if !ok {
+			value = 0xff
+		}
+		buf.WriteByte(value)
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory inspection", func() {
+	var (
+		ram Memory
+	)
+
+	load := func(address uint16, s string) {
+		for i := range s {
+			ram.Write(address+uint16(i), s[i])
+		}
+	}
+
+	BeforeEach(func() {
+		ram = NewRam()
+	})
+
+	It("should dump a range with the ASCII column and fold the identical lines", func() {
+		load(0x1008, "GOTO770\x01")
+		var sb strings.Builder
+		Expect(DumpRange(&sb, ram, 0x1004, 0x1043)).To(Succeed())
+
+		Expect(sb.String()).To(Equal(
+			"1000 |             00 00 00 00  47 4f 54 4f 37 37 30 01 |     ....GOTO770.\n" +
+				"1010 | 00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00 | ................\n" +
+				"*\n" +
+				"1040 | 00 00 00 00                                      | ....            \n"))
+	})
+
+	It("should not read the devices of a bus", func() {
+		bus := NewBus()
+		reads := 0
+		Expect(bus.Map(Mapping{From: 0x0000, To: 0x0003, Handler: NewROM([]uint8{0x41, 0x42, 0x43, 0x44})})).To(Succeed())
+		Expect(bus.Map(Mapping{From: 0x0004, To: 0x0007, Handler: &HandlerFuncs{ReadFunc: func(uint16) uint8 { reads++; return 0 }}})).To(Succeed())
+		var sb strings.Builder
+		DumpRange(&sb, bus, 0x0000, 0x0007)
+
+		Expect(sb.String()).To(Equal("0000 | 41 42 43 44 -- -- -- --                          | ABCD....        \n"))
+		Expect(reads).To(BeZero())
+	})
+
+	It("should fill and copy ranges", func() {
+		Fill(ram, 0x2000, 0x2003, 0xaa)
+		load(0x2004, "abcd")
+		Expect(Copy(ram, 0x2002, 0x2005, 0x2004)).To(Succeed())
+
+		Expect(CopyMemory(ram)[0x2000:0x2009]).To(Equal([]uint8{0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 'a', 'b', 0x00}))
+		Expect(Copy(ram, 0x2005, 0x2002, 0x3000)).NotTo(Succeed())
+		Expect(Copy(ram, 0x2000, 0x200f, 0xfff8)).NotTo(Succeed())
+	})
+
+	It("should not read the devices when copying from a bus", func() {
+		bus := NewBus()
+		reads := 0
+		Expect(bus.Map(Mapping{From: 0x0000, To: 0x00ff, Handler: NewRAM(0x100)})).To(Succeed())
+		Expect(bus.Map(Mapping{From: 0x0100, To: 0x0101, Handler: &HandlerFuncs{ReadFunc: func(uint16) uint8 { reads++; return 0 }}})).To(Succeed())
+		Expect(Copy(bus, 0x0100, 0x0101, 0x0000)).To(Succeed())
+
+		Expect(reads).To(BeZero())
+		Expect(bus.Readw(0x0000)).To(BeEquivalentTo(0xffff))
+	})
+
+	It("should search byte and string patterns", func() {
+		load(0x3000, "\x86\x01\xb7\x86\x02\xb7HELLO")
+		pattern, err := ParsePattern("86 ?? b7")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(Search(ram, 0x0000, 0xffff, pattern)).To(Equal([]uint16{0x3000, 0x3003}))
+		Expect(Search(ram, 0x3001, 0x3004, pattern)).To(BeEmpty())
+		Expect(Search(ram, 0x0000, 0xffff, StringPattern("HE?LO"))).To(Equal([]uint16{0x3006}))
+		_, err = ParsePattern("86 zz")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should report the ranges which differ between two copies", func() {
+		before := CopyMemory(ram)
+		load(0x4000, "ab")
+		ram.Write(0xffff, 0x01)
+
+		Expect(Compare(before, CopyMemory(ram))).To(Equal([]AddressRange{{From: 0x4000, To: 0x4001}, {From: 0xffff, To: 0xffff}}))
+	})
+
+	It("should save and load ranges", func() {
+		dir, err := ioutil.TempDir("", "inspect")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "range.bin")
+		load(0x5000, "TO7/70")
+
+		Expect(SaveRange(ram, path, 0x5000, 0x5005)).To(Succeed())
+		n, err := LoadRange(ram, path, 0x6000)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(6))
+		Expect(Search(ram, 0x6000, 0x6005, StringPattern("TO7/70"))).To(Equal([]uint16{0x6000}))
+		_, err = LoadRange(ram, path, 0xfffc)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,567 @@
+package core
+
+import "fmt"
+
+// instruction is the specification of an opcode. The dispatch tables, the
+// disassembler and the instruction set tests are all derived from it.
+type instruction struct {
+	/// Opcode, page 1 and page 2 opcodes include the $10 or $11 prefix
+	code int
+	/// Mnemonic
+	name string
+	/// Addressing mode
+	mode addressMode
+	/// Length in bytes, prefix included, indexed mode offset excluded
+	size int
+	/// Cycles from the datasheet, indexed mode extra cycles excluded
+	cycles uint64
+	/// Cycles in HD6309 native mode, 0 if unchanged
+	native uint64
+	/// Condition code flags affected
+	flags string
+	/// Implementation: func(*CPU) for inherent instructions, func(*CPU, uint16)
+	/// called with the effective address, or func(*CPU, uint16, uint16) called
+	/// with the address of an immediate operand and the effective address
+	exec interface{}
+}
+
+// Cycles counted while the registers are stacked or pulled, which are not part of
+// the cycles charged when the instruction is dispatched
+var stackedCycles = map[string]uint64{"RTI": 3, "CWAI": 12, "SWI": 12, "SWI2": 12, "SWI3": 12, "XRES": 12}
+
+// bind returns the dispatch table entry of an instruction for this CPU
+func (c *CPU) bind(in instruction) opcode {
+	var f func()
+	switch exec := in.exec.(type) {
+	case func(*CPU):
+		f = func() { exec(c) }
+	case func(*CPU, uint16):
+		address := c.addressing(in.mode)
+		f = func() {
+			ea := address()
+			c.ea = int(ea)
+			exec(c, ea)
+		}
+	case func(*CPU, uint16, uint16):
+		address := c.addressing(in.mode)
+		f = func() {
+			value := c.immediate()
+			ea := address()
+			c.ea = int(ea)
+			exec(c, value, ea)
+		}
+	default:
+		panic(fmt.Sprintf("instruction %04x: invalid implementation %T", in.code, in.exec))
+	}
+	op := opcode{in.name, f, in.cycles - stackedCycles[in.name], in.mode, 0, in.exec}
+	// Instructions which are faster in native mode. The extra cycles of the
+	// indexed modes and of the stacked registers are unchanged.
+	if in.native != 0 {
+		op.native = in.native - stackedCycles[in.name]
+	}
+	return op
+}
+
+// addressing returns the function computing the effective address of a mode
+func (c *CPU) addressing(mode addressMode) func() uint16 {
+	switch mode {
+	case direct, imdirect, bitdirect:
+		return c.direct
+	case immediate, transfer, stack, interregister, blockmove:
+		return c.immediate
+	case limmediate:
+		return c.limmediate
+	case qimmediate:
+		return c.qimmediate
+	case relative:
+		return c.relative
+	case lrelative:
+		return c.lrelative
+	case extended, imextended:
+		return c.extended
+	case indexed, imindexed:
+		return c.indexed
+	default:
+		panic(fmt.Sprintf("addressing mode %d has no effective address", mode))
+	}
+}
+
+// MC6809 instruction set
+var mc6809Instructions = []instruction{
+	{0x00, "NEG", direct, 2, 6, 5, "NZVC", (*CPU).neg},
+	{0x03, "COM", direct, 2, 6, 5, "NZVC", (*CPU).com},
+	{0x04, "LSR", direct, 2, 6, 5, "NZC", (*CPU).lsr},
+	{0x06, "ROR", direct, 2, 6, 5, "NZC", (*CPU).ror},
+	{0x07, "ASR", direct, 2, 6, 5, "NZC", (*CPU).asr},
+	{0x08, "ASL", direct, 2, 6, 5, "NZVC", (*CPU).asl},
+	{0x09, "ROL", direct, 2, 6, 5, "NZVC", (*CPU).rol},
+	{0x0a, "DEC", direct, 2, 6, 5, "NZV", (*CPU).dec},
+	{0x0c, "INC", direct, 2, 6, 5, "NZV", (*CPU).inc},
+	{0x0d, "TST", direct, 2, 6, 4, "NZV", (*CPU).tst},
+	{0x0e, "JMP", direct, 2, 3, 2, "", (*CPU).jmp},
+	{0x0f, "CLR", direct, 2, 6, 5, "NZVC", (*CPU).clr},
+	{0x12, "NOP", inherent, 1, 2, 1, "", (*CPU).nop},
+	{0x13, "SYNC", inherent, 1, 4, 3, "", (*CPU).sync},
+	{0x16, "LBRA", lrelative, 3, 5, 4, "", (*CPU).bra},
+	{0x17, "LBSR", lrelative, 3, 9, 7, "", (*CPU).bsr},
+	{0x19, "DAA", inherent, 1, 2, 1, "NZC", (*CPU).daa},
+	{0x1a, "ORCC", immediate, 2, 3, 0, "EFHINZVC", (*CPU).orcc},
+	{0x1c, "ANDCC", immediate, 2, 3, 0, "EFHINZVC", (*CPU).andcc},
+	{0x1d, "SEX", inherent, 1, 2, 1, "NZ", (*CPU).sex},
+	{0x1e, "EXG", transfer, 2, 8, 5, "", (*CPU).exg},
+	{0x1f, "TFR", transfer, 2, 6, 4, "", (*CPU).tfr},
+	{0x20, "BRA", relative, 2, 3, 0, "", (*CPU).bra},
+	{0x21, "BRN", relative, 2, 3, 0, "", (*CPU).brn},
+	{0x22, "BHI", relative, 2, 3, 0, "", (*CPU).bhi},
+	{0x23, "BLS", relative, 2, 3, 0, "", (*CPU).bls},
+	{0x24, "BCC", relative, 2, 3, 0, "", (*CPU).bcc},
+	{0x25, "BLO", relative, 2, 3, 0, "", (*CPU).blo},
+	{0x26, "BNE", relative, 2, 3, 0, "", (*CPU).bne},
+	{0x27, "BEQ", relative, 2, 3, 0, "", (*CPU).beq},
+	{0x28, "BVC", relative, 2, 3, 0, "", (*CPU).bvc},
+	{0x29, "BVS", relative, 2, 3, 0, "", (*CPU).bvs},
+	{0x2a, "BPL", relative, 2, 3, 0, "", (*CPU).bpl},
+	{0x2b, "BMI", relative, 2, 3, 0, "", (*CPU).bmi},
+	{0x2c, "BGE", relative, 2, 3, 0, "", (*CPU).bge},
+	{0x2d, "BLT", relative, 2, 3, 0, "", (*CPU).blt},
+	{0x2e, "BGT", relative, 2, 3, 0, "", (*CPU).bgt},
+	{0x2f, "BLE", relative, 2, 3, 0, "", (*CPU).ble},
+	{0x30, "LEAX", indexed, 2, 4, 0, "Z", (*CPU).leax},
+	{0x31, "LEAY", indexed, 2, 4, 0, "Z", (*CPU).leay},
+	{0x32, "LEAS", indexed, 2, 4, 0, "", (*CPU).leas},
+	{0x33, "LEAU", indexed, 2, 4, 0, "", (*CPU).leau},
+	{0x34, "PSHS", stack, 2, 5, 4, "", (*CPU).pshs},
+	{0x35, "PULS", stack, 2, 5, 4, "", (*CPU).puls},
+	{0x36, "PSHU", stack, 2, 5, 4, "", (*CPU).pshu},
+	{0x37, "PULU", stack, 2, 5, 4, "", (*CPU).pulu},
+	{0x39, "RTS", inherent, 1, 5, 4, "", (*CPU).rts},
+	{0x3a, "ABX", inherent, 1, 3, 1, "", (*CPU).abx},
+	{0x3b, "RTI", inherent, 1, 6, 0, "EFHINZVC", (*CPU).rti},
+	{0x3c, "CWAI", immediate, 2, 20, 0, "EFHINZVC", (*CPU).cwai},
+	{0x3d, "MUL", inherent, 1, 11, 10, "ZC", (*CPU).mul},
+	{0x3f, "SWI", inherent, 1, 19, 0, "EFI", (*CPU).swi},
+	{0x40, "NEGA", inherent, 1, 2, 1, "NZVC", (*CPU).nega},
+	{0x43, "COMA", inherent, 1, 2, 1, "NZVC", (*CPU).coma},
+	{0x44, "LSRA", inherent, 1, 2, 1, "NZC", (*CPU).lsra},
+	{0x46, "RORA", inherent, 1, 2, 1, "NZC", (*CPU).rora},
+	{0x47, "ASRA", inherent, 1, 2, 1, "NZC", (*CPU).asra},
+	{0x48, "ASLA", inherent, 1, 2, 1, "NZVC", (*CPU).asla},
+	{0x49, "ROLA", inherent, 1, 2, 1, "NZVC", (*CPU).rola},
+	{0x4a, "DECA", inherent, 1, 2, 1, "NZV", (*CPU).deca},
+	{0x4c, "INCA", inherent, 1, 2, 1, "NZV", (*CPU).inca},
+	{0x4d, "TSTA", inherent, 1, 2, 1, "NZV", (*CPU).tsta},
+	{0x4f, "CLRA", inherent, 1, 2, 1, "NZVC", (*CPU).clra},
+	{0x50, "NEGB", inherent, 1, 2, 1, "NZVC", (*CPU).negb},
+	{0x53, "COMB", inherent, 1, 2, 1, "NZVC", (*CPU).comb},
+	{0x54, "LSRB", inherent, 1, 2, 1, "NZC", (*CPU).lsrb},
+	{0x56, "RORB", inherent, 1, 2, 1, "NZC", (*CPU).rorb},
+	{0x57, "ASRB", inherent, 1, 2, 1, "NZC", (*CPU).asrb},
+	{0x58, "ASLB", inherent, 1, 2, 1, "NZVC", (*CPU).aslb},
+	{0x59, "ROLB", inherent, 1, 2, 1, "NZVC", (*CPU).rolb},
+	{0x5a, "DECB", inherent, 1, 2, 1, "NZV", (*CPU).decb},
+	{0x5c, "INCB", inherent, 1, 2, 1, "NZV", (*CPU).incb},
+	{0x5d, "TSTB", inherent, 1, 2, 1, "NZV", (*CPU).tstb},
+	{0x5f, "CLRB", inherent, 1, 2, 1, "NZVC", (*CPU).clrb},
+	{0x60, "NEG", indexed, 2, 6, 0, "NZVC", (*CPU).neg},
+	{0x63, "COM", indexed, 2, 6, 0, "NZVC", (*CPU).com},
+	{0x64, "LSR", indexed, 2, 6, 0, "NZC", (*CPU).lsr},
+	{0x66, "ROR", indexed, 2, 6, 0, "NZC", (*CPU).ror},
+	{0x67, "ASR", indexed, 2, 6, 0, "NZC", (*CPU).asr},
+	{0x68, "ASL", indexed, 2, 6, 0, "NZVC", (*CPU).asl},
+	{0x69, "ROL", indexed, 2, 6, 0, "NZVC", (*CPU).rol},
+	{0x6a, "DEC", indexed, 2, 6, 0, "NZV", (*CPU).dec},
+	{0x6c, "INC", indexed, 2, 6, 0, "NZV", (*CPU).inc},
+	{0x6d, "TST", indexed, 2, 6, 5, "NZV", (*CPU).tst},
+	{0x6e, "JMP", indexed, 2, 3, 0, "", (*CPU).jmp},
+	{0x6f, "CLR", indexed, 2, 6, 0, "NZVC", (*CPU).clr},
+	{0x70, "NEG", extended, 3, 7, 6, "NZVC", (*CPU).neg},
+	{0x73, "COM", extended, 3, 7, 6, "NZVC", (*CPU).com},
+	{0x74, "LSR", extended, 3, 7, 6, "NZC", (*CPU).lsr},
+	{0x76, "ROR", extended, 3, 7, 6, "NZC", (*CPU).ror},
+	{0x77, "ASR", extended, 3, 7, 6, "NZC", (*CPU).asr},
+	{0x78, "ASL", extended, 3, 7, 6, "NZVC", (*CPU).asl},
+	{0x79, "ROL", extended, 3, 7, 6, "NZVC", (*CPU).rol},
+	{0x7a, "DEC", extended, 3, 7, 6, "NZV", (*CPU).dec},
+	{0x7c, "INC", extended, 3, 7, 6, "NZV", (*CPU).inc},
+	{0x7d, "TST", extended, 3, 7, 5, "NZV", (*CPU).tst},
+	{0x7e, "JMP", extended, 3, 4, 3, "", (*CPU).jmp},
+	{0x7f, "CLR", extended, 3, 7, 6, "NZVC", (*CPU).clr},
+	{0x80, "SUBA", immediate, 2, 2, 0, "NZVC", (*CPU).suba},
+	{0x81, "CMPA", immediate, 2, 2, 0, "NZVC", (*CPU).cmpa},
+	{0x82, "SBCA", immediate, 2, 2, 0, "NZVC", (*CPU).sbca},
+	{0x83, "SUBD", limmediate, 3, 4, 3, "NZVC", (*CPU).subd},
+	{0x84, "ANDA", immediate, 2, 2, 0, "NZV", (*CPU).anda},
+	{0x85, "BITA", immediate, 2, 2, 0, "NZV", (*CPU).bita},
+	{0x86, "LDA", immediate, 2, 2, 0, "NZV", (*CPU).lda},
+	{0x88, "EORA", immediate, 2, 2, 0, "NZV", (*CPU).eora},
+	{0x89, "ADCA", immediate, 2, 2, 0, "HNZVC", (*CPU).adca},
+	{0x8a, "ORA", immediate, 2, 2, 0, "NZV", (*CPU).ora},
+	{0x8b, "ADDA", immediate, 2, 2, 0, "HNZVC", (*CPU).adda},
+	{0x8c, "CMPX", limmediate, 3, 4, 3, "NZVC", (*CPU).cmpx},
+	{0x8d, "BSR", relative, 2, 7, 6, "", (*CPU).bsr},
+	{0x8e, "LDX", limmediate, 3, 3, 0, "NZV", (*CPU).ldx},
+	{0x90, "SUBA", direct, 2, 4, 3, "NZVC", (*CPU).suba},
+	{0x91, "CMPA", direct, 2, 4, 3, "NZVC", (*CPU).cmpa},
+	{0x92, "SBCA", direct, 2, 4, 3, "NZVC", (*CPU).sbca},
+	{0x93, "SUBD", direct, 2, 6, 4, "NZVC", (*CPU).subd},
+	{0x94, "ANDA", direct, 2, 4, 3, "NZV", (*CPU).anda},
+	{0x95, "BITA", direct, 2, 4, 3, "NZV", (*CPU).bita},
+	{0x96, "LDA", direct, 2, 4, 3, "NZV", (*CPU).lda},
+	{0x97, "STA", direct, 2, 4, 3, "NZV", (*CPU).sta},
+	{0x98, "EORA", direct, 2, 4, 3, "NZV", (*CPU).eora},
+	{0x99, "ADCA", direct, 2, 4, 3, "HNZVC", (*CPU).adca},
+	{0x9a, "ORA", direct, 2, 4, 3, "NZV", (*CPU).ora},
+	{0x9b, "ADDA", direct, 2, 4, 3, "HNZVC", (*CPU).adda},
+	{0x9c, "CMPX", direct, 2, 6, 4, "NZVC", (*CPU).cmpx},
+	{0x9d, "JSR", direct, 2, 7, 6, "", (*CPU).jsr},
+	{0x9e, "LDX", direct, 2, 5, 4, "NZV", (*CPU).ldx},
+	{0x9f, "STX", direct, 2, 5, 4, "NZV", (*CPU).stx},
+	{0xa0, "SUBA", indexed, 2, 4, 0, "NZVC", (*CPU).suba},
+	{0xa1, "CMPA", indexed, 2, 4, 0, "NZVC", (*CPU).cmpa},
+	{0xa2, "SBCA", indexed, 2, 4, 0, "NZVC", (*CPU).sbca},
+	{0xa3, "SUBD", indexed, 2, 6, 5, "NZVC", (*CPU).subd},
+	{0xa4, "ANDA", indexed, 2, 4, 0, "NZV", (*CPU).anda},
+	{0xa5, "BITA", indexed, 2, 4, 0, "NZV", (*CPU).bita},
+	{0xa6, "LDA", indexed, 2, 4, 0, "NZV", (*CPU).lda},
+	{0xa7, "STA", indexed, 2, 4, 0, "NZV", (*CPU).sta},
+	{0xa8, "EORA", indexed, 2, 4, 0, "NZV", (*CPU).eora},
+	{0xa9, "ADCA", indexed, 2, 4, 0, "HNZVC", (*CPU).adca},
+	{0xaa, "ORA", indexed, 2, 4, 0, "NZV", (*CPU).ora},
+	{0xab, "ADDA", indexed, 2, 4, 0, "HNZVC", (*CPU).adda},
+	{0xac, "CMPX", indexed, 2, 6, 5, "NZVC", (*CPU).cmpx},
+	{0xad, "JSR", indexed, 2, 7, 6, "", (*CPU).jsr},
+	{0xae, "LDX", indexed, 2, 5, 0, "NZV", (*CPU).ldx},
+	{0xaf, "STX", indexed, 2, 5, 0, "NZV", (*CPU).stx},
+	{0xb0, "SUBA", extended, 3, 5, 4, "NZVC", (*CPU).suba},
+	{0xb1, "CMPA", extended, 3, 5, 4, "NZVC", (*CPU).cmpa},
+	{0xb2, "SBCA", extended, 3, 5, 4, "NZVC", (*CPU).sbca},
+	{0xb3, "SUBD", extended, 3, 7, 5, "NZVC", (*CPU).subd},
+	{0xb4, "ANDA", extended, 3, 5, 4, "NZV", (*CPU).anda},
+	{0xb5, "BITA", extended, 3, 5, 4, "NZV", (*CPU).bita},
+	{0xb6, "LDA", extended, 3, 5, 4, "NZV", (*CPU).lda},
+	{0xb7, "STA", extended, 3, 5, 4, "NZV", (*CPU).sta},
+	{0xb8, "EORA", extended, 3, 5, 4, "NZV", (*CPU).eora},
+	{0xb9, "ADCA", extended, 3, 5, 4, "HNZVC", (*CPU).adca},
+	{0xba, "ORA", extended, 3, 5, 4, "NZV", (*CPU).ora},
+	{0xbb, "ADDA", extended, 3, 5, 4, "HNZVC", (*CPU).adda},
+	{0xbc, "CMPX", extended, 3, 7, 5, "NZVC", (*CPU).cmpx},
+	{0xbd, "JSR", extended, 3, 8, 7, "", (*CPU).jsr},
+	{0xbe, "LDX", extended, 3, 6, 5, "NZV", (*CPU).ldx},
+	{0xbf, "STX", extended, 3, 6, 5, "NZV", (*CPU).stx},
+	{0xc0, "SUBB", immediate, 2, 2, 0, "NZVC", (*CPU).subb},
+	{0xc1, "CMPB", immediate, 2, 2, 0, "NZVC", (*CPU).cmpb},
+	{0xc2, "SBCB", immediate, 2, 2, 0, "NZVC", (*CPU).sbcb},
+	{0xc3, "ADDD", limmediate, 3, 4, 3, "NZVC", (*CPU).addd},
+	{0xc4, "ANDB", immediate, 2, 2, 0, "NZV", (*CPU).andb},
+	{0xc5, "BITB", immediate, 2, 2, 0, "NZV", (*CPU).bitb},
+	{0xc6, "LDB", immediate, 2, 2, 0, "NZV", (*CPU).ldb},
+	{0xc8, "EORB", immediate, 2, 2, 0, "NZV", (*CPU).eorb},
+	{0xc9, "ADCB", immediate, 2, 2, 0, "HNZVC", (*CPU).adcb},
+	{0xca, "ORB", immediate, 2, 2, 0, "NZV", (*CPU).orb},
+	{0xcb, "ADDB", immediate, 2, 2, 0, "HNZVC", (*CPU).addb},
+	{0xcc, "LDD", limmediate, 3, 3, 0, "NZV", (*CPU).ldd},
+	{0xce, "LDU", limmediate, 3, 3, 0, "NZV", (*CPU).ldu},
+	{0xd0, "SUBB", direct, 2, 4, 3, "NZVC", (*CPU).subb},
+	{0xd1, "CMPB", direct, 2, 4, 3, "NZVC", (*CPU).cmpb},
+	{0xd2, "SBCB", direct, 2, 4, 3, "NZVC", (*CPU).sbcb},
+	{0xd3, "ADDD", direct, 2, 6, 4, "NZVC", (*CPU).addd},
+	{0xd4, "ANDB", direct, 2, 4, 3, "NZV", (*CPU).andb},
+	{0xd5, "BITB", direct, 2, 4, 3, "NZV", (*CPU).bitb},
+	{0xd6, "LDB", direct, 2, 4, 3, "NZV", (*CPU).ldb},
+	{0xd7, "STB", direct, 2, 4, 3, "NZV", (*CPU).stb},
+	{0xd8, "EORB", direct, 2, 4, 3, "NZV", (*CPU).eorb},
+	{0xd9, "ADCB", direct, 2, 4, 3, "HNZVC", (*CPU).adcb},
+	{0xda, "ORB", direct, 2, 4, 3, "NZV", (*CPU).orb},
+	{0xdb, "ADDB", direct, 2, 4, 3, "HNZVC", (*CPU).addb},
+	{0xdc, "LDD", direct, 2, 5, 4, "NZV", (*CPU).ldd},
+	{0xdd, "STD", direct, 2, 5, 4, "NZV", (*CPU).std},
+	{0xde, "LDU", direct, 2, 5, 4, "NZV", (*CPU).ldu},
+	{0xdf, "STU", direct, 2, 5, 4, "NZV", (*CPU).stu},
+	{0xe0, "SUBB", indexed, 2, 4, 0, "NZVC", (*CPU).subb},
+	{0xe1, "CMPB", indexed, 2, 4, 0, "NZVC", (*CPU).cmpb},
+	{0xe2, "SBCB", indexed, 2, 4, 0, "NZVC", (*CPU).sbcb},
+	{0xe3, "ADDD", indexed, 2, 6, 5, "NZVC", (*CPU).addd},
+	{0xe4, "ANDB", indexed, 2, 4, 0, "NZV", (*CPU).andb},
+	{0xe5, "BITB", indexed, 2, 4, 0, "NZV", (*CPU).bitb},
+	{0xe6, "LDB", indexed, 2, 4, 0, "NZV", (*CPU).ldb},
+	{0xe7, "STB", indexed, 2, 4, 0, "NZV", (*CPU).stb},
+	{0xe8, "EORB", indexed, 2, 4, 0, "NZV", (*CPU).eorb},
+	{0xe9, "ADCB", indexed, 2, 4, 0, "HNZVC", (*CPU).adcb},
+	{0xea, "ORB", indexed, 2, 4, 0, "NZV", (*CPU).orb},
+	{0xeb, "ADDB", indexed, 2, 4, 0, "HNZVC", (*CPU).addb},
+	{0xec, "LDD", indexed, 2, 5, 0, "NZV", (*CPU).ldd},
+	{0xed, "STD", indexed, 2, 5, 0, "NZV", (*CPU).std},
+	{0xee, "LDU", indexed, 2, 5, 0, "NZV", (*CPU).ldu},
+	{0xef, "STU", indexed, 2, 5, 0, "NZV", (*CPU).stu},
+	{0xf0, "SUBB", extended, 3, 5, 4, "NZVC", (*CPU).subb},
+	{0xf1, "CMPB", extended, 3, 5, 4, "NZVC", (*CPU).cmpb},
+	{0xf2, "SBCB", extended, 3, 5, 4, "NZVC", (*CPU).sbcb},
+	{0xf3, "ADDD", extended, 3, 7, 5, "NZVC", (*CPU).addd},
+	{0xf4, "ANDB", extended, 3, 5, 4, "NZV", (*CPU).andb},
+	{0xf5, "BITB", extended, 3, 5, 4, "NZV", (*CPU).bitb},
+	{0xf6, "LDB", extended, 3, 5, 4, "NZV", (*CPU).ldb},
+	{0xf7, "STB", extended, 3, 5, 4, "NZV", (*CPU).stb},
+	{0xf8, "EORB", extended, 3, 5, 4, "NZV", (*CPU).eorb},
+	{0xf9, "ADCB", extended, 3, 5, 4, "HNZVC", (*CPU).adcb},
+	{0xfa, "ORB", extended, 3, 5, 4, "NZV", (*CPU).orb},
+	{0xfb, "ADDB", extended, 3, 5, 4, "HNZVC", (*CPU).addb},
+	{0xfc, "LDD", extended, 3, 6, 5, "NZV", (*CPU).ldd},
+	{0xfd, "STD", extended, 3, 6, 5, "NZV", (*CPU).std},
+	{0xfe, "LDU", extended, 3, 6, 5, "NZV", (*CPU).ldu},
+	{0xff, "STU", extended, 3, 6, 5, "NZV", (*CPU).stu},
+	{0x1021, "LBRN", lrelative, 4, 5, 0, "", (*CPU).lbrn},
+	{0x1022, "LBHI", lrelative, 4, 5, 0, "", (*CPU).lbhi},
+	{0x1023, "LBLS", lrelative, 4, 5, 0, "", (*CPU).lbls},
+	{0x1024, "LBCC", lrelative, 4, 5, 0, "", (*CPU).lbcc},
+	{0x1025, "LBCS", lrelative, 4, 5, 0, "", (*CPU).lblo},
+	{0x1026, "LBNE", lrelative, 4, 5, 0, "", (*CPU).lbne},
+	{0x1027, "LBEQ", lrelative, 4, 5, 0, "", (*CPU).lbeq},
+	{0x1028, "LBVC", lrelative, 4, 5, 0, "", (*CPU).lbvc},
+	{0x1029, "LBVS", lrelative, 4, 5, 0, "", (*CPU).lbvs},
+	{0x102a, "LBPL", lrelative, 4, 5, 0, "", (*CPU).lbpl},
+	{0x102b, "LBMI", lrelative, 4, 5, 0, "", (*CPU).lbmi},
+	{0x102c, "LBGE", lrelative, 4, 5, 0, "", (*CPU).lbge},
+	{0x102d, "LBLT", lrelative, 4, 5, 0, "", (*CPU).lblt},
+	{0x102e, "LBGT", lrelative, 4, 5, 0, "", (*CPU).lbgt},
+	{0x102f, "LBLE", lrelative, 4, 5, 0, "", (*CPU).lble},
+	{0x103f, "SWI2", inherent, 2, 20, 0, "E", (*CPU).swi2},
+	{0x1083, "CMPD", limmediate, 4, 5, 4, "NZVC", (*CPU).cmpd},
+	{0x108c, "CMPY", limmediate, 4, 5, 4, "NZVC", (*CPU).cmpy},
+	{0x108e, "LDY", limmediate, 4, 4, 0, "NZV", (*CPU).ldy},
+	{0x1093, "CMPD", direct, 3, 7, 5, "NZVC", (*CPU).cmpd},
+	{0x109c, "CMPY", direct, 3, 7, 5, "NZVC", (*CPU).cmpy},
+	{0x109e, "LDY", direct, 3, 6, 5, "NZV", (*CPU).ldy},
+	{0x109f, "STY", direct, 3, 6, 5, "NZV", (*CPU).sty},
+	{0x10a3, "CMPD", indexed, 3, 7, 6, "NZVC", (*CPU).cmpd},
+	{0x10ac, "CMPY", indexed, 3, 7, 6, "NZVC", (*CPU).cmpy},
+	{0x10ae, "LDY", indexed, 3, 6, 0, "NZV", (*CPU).ldy},
+	{0x10af, "STY", indexed, 3, 6, 0, "NZV", (*CPU).sty},
+	{0x10b3, "CMPD", extended, 4, 8, 6, "NZVC", (*CPU).cmpd},
+	{0x10bc, "CMPY", extended, 4, 8, 6, "NZVC", (*CPU).cmpy},
+	{0x10be, "LDY", extended, 4, 7, 6, "NZV", (*CPU).ldy},
+	{0x10bf, "STY", extended, 4, 7, 6, "NZV", (*CPU).sty},
+	{0x10ce, "LDS", limmediate, 4, 4, 0, "NZV", (*CPU).lds},
+	{0x10de, "LDS", direct, 3, 6, 5, "NZV", (*CPU).lds},
+	{0x10df, "STS", direct, 3, 6, 5, "NZV", (*CPU).sts},
+	{0x10ee, "LDS", indexed, 3, 6, 0, "NZV", (*CPU).lds},
+	{0x10ef, "STS", indexed, 3, 6, 0, "NZV", (*CPU).sts},
+	{0x10fe, "LDS", extended, 4, 7, 6, "NZV", (*CPU).lds},
+	{0x10ff, "STS", extended, 4, 7, 6, "NZV", (*CPU).sts},
+	{0x113f, "SWI3", inherent, 2, 20, 0, "E", (*CPU).swi3},
+	{0x1183, "CMPU", limmediate, 4, 5, 4, "NZVC", (*CPU).cmpu},
+	{0x118c, "CMPS", limmediate, 4, 5, 4, "NZVC", (*CPU).cmps},
+	{0x1193, "CMPU", direct, 3, 7, 5, "NZVC", (*CPU).cmpu},
+	{0x119c, "CMPS", direct, 3, 7, 5, "NZVC", (*CPU).cmps},
+	{0x11a3, "CMPU", indexed, 3, 7, 6, "NZVC", (*CPU).cmpu},
+	{0x11ac, "CMPS", indexed, 3, 7, 6, "NZVC", (*CPU).cmps},
+	{0x11b3, "CMPU", extended, 4, 8, 6, "NZVC", (*CPU).cmpu},
+	{0x11bc, "CMPS", extended, 4, 8, 6, "NZVC", (*CPU).cmps},
+}
+
+// Undocumented MC6809 opcodes, see IllegalOpcodePolicy
+var undocumentedInstructions = []instruction{
+	{0x01, "NEG", direct, 2, 6, 0, "NZVC", (*CPU).neg},
+	{0x02, "XNC", direct, 2, 6, 0, "NZVC", (*CPU).xnc},
+	{0x05, "LSR", direct, 2, 6, 0, "NZC", (*CPU).lsr},
+	{0x0b, "DEC", direct, 2, 6, 0, "NZV", (*CPU).dec},
+	{0x14, "HCF", inherent, 1, 2, 0, "", (*CPU).hcf},
+	{0x15, "HCF", inherent, 1, 2, 0, "", (*CPU).hcf},
+	{0x1b, "NOP", inherent, 1, 2, 0, "", (*CPU).nop},
+	{0x38, "ANDCC", immediate, 2, 4, 0, "EFHINZVC", (*CPU).andcc},
+	{0x3e, "XRES", inherent, 1, 19, 0, "EFI", (*CPU).xres},
+	{0x41, "NEGA", inherent, 1, 2, 0, "NZVC", (*CPU).nega},
+	{0x42, "XNCA", inherent, 1, 2, 0, "NZVC", (*CPU).xnca},
+	{0x45, "LSRA", inherent, 1, 2, 0, "NZC", (*CPU).lsra},
+	{0x4b, "DECA", inherent, 1, 2, 0, "NZV", (*CPU).deca},
+	{0x4e, "CLRA", inherent, 1, 2, 0, "NZVC", (*CPU).clra},
+	{0x51, "NEGB", inherent, 1, 2, 0, "NZVC", (*CPU).negb},
+	{0x52, "XNCB", inherent, 1, 2, 0, "NZVC", (*CPU).xncb},
+	{0x55, "LSRB", inherent, 1, 2, 0, "NZC", (*CPU).lsrb},
+	{0x5b, "DECB", inherent, 1, 2, 0, "NZV", (*CPU).decb},
+	{0x5e, "CLRB", inherent, 1, 2, 0, "NZVC", (*CPU).clrb},
+	{0x61, "NEG", indexed, 2, 6, 0, "NZVC", (*CPU).neg},
+	{0x62, "XNC", indexed, 2, 6, 0, "NZVC", (*CPU).xnc},
+	{0x65, "LSR", indexed, 2, 6, 0, "NZC", (*CPU).lsr},
+	{0x6b, "DEC", indexed, 2, 6, 0, "NZV", (*CPU).dec},
+	{0x71, "NEG", extended, 3, 7, 0, "NZVC", (*CPU).neg},
+	{0x72, "XNC", extended, 3, 7, 0, "NZVC", (*CPU).xnc},
+	{0x75, "LSR", extended, 3, 7, 0, "NZC", (*CPU).lsr},
+	{0x7b, "DEC", extended, 3, 7, 0, "NZV", (*CPU).dec},
+	{0x87, "STA", immediate, 2, 2, 0, "NZV", (*CPU).sta},
+	{0x8f, "STX", limmediate, 3, 3, 0, "NZV", (*CPU).stx},
+	{0xc7, "STB", immediate, 2, 2, 0, "NZV", (*CPU).stb},
+	{0xcd, "HCF", inherent, 1, 2, 0, "", (*CPU).hcf},
+	{0xcf, "STU", limmediate, 3, 3, 0, "NZV", (*CPU).stu},
+}
+
+// Instructions added by the HD6309
+var hd6309Instructions = []instruction{
+	{0x01, "OIM", imdirect, 3, 6, 0, "NZV", (*CPU).oim},
+	{0x02, "AIM", imdirect, 3, 6, 0, "NZV", (*CPU).aim},
+	{0x05, "EIM", imdirect, 3, 6, 0, "NZV", (*CPU).eim},
+	{0x0b, "TIM", imdirect, 3, 6, 0, "NZV", (*CPU).tim},
+	{0x14, "SEXW", inherent, 1, 4, 0, "NZ", (*CPU).sexw},
+	{0x61, "OIM", imindexed, 3, 7, 0, "NZV", (*CPU).oim},
+	{0x62, "AIM", imindexed, 3, 7, 0, "NZV", (*CPU).aim},
+	{0x65, "EIM", imindexed, 3, 7, 0, "NZV", (*CPU).eim},
+	{0x6b, "TIM", imindexed, 3, 7, 0, "NZV", (*CPU).tim},
+	{0x71, "OIM", imextended, 4, 7, 0, "NZV", (*CPU).oim},
+	{0x72, "AIM", imextended, 4, 7, 0, "NZV", (*CPU).aim},
+	{0x75, "EIM", imextended, 4, 7, 0, "NZV", (*CPU).eim},
+	{0x7b, "TIM", imextended, 4, 7, 0, "NZV", (*CPU).tim},
+	{0xcd, "LDQ", qimmediate, 5, 5, 0, "NZV", (*CPU).ldq},
+	{0x1030, "ADDR", interregister, 3, 4, 0, "NZVC", (*CPU).addr},
+	{0x1031, "ADCR", interregister, 3, 4, 0, "NZVC", (*CPU).adcr},
+	{0x1032, "SUBR", interregister, 3, 4, 0, "NZVC", (*CPU).subr},
+	{0x1033, "SBCR", interregister, 3, 4, 0, "NZVC", (*CPU).sbcr},
+	{0x1034, "ANDR", interregister, 3, 4, 0, "NZV", (*CPU).andr},
+	{0x1035, "ORR", interregister, 3, 4, 0, "NZV", (*CPU).orr},
+	{0x1036, "EORR", interregister, 3, 4, 0, "NZV", (*CPU).eorr},
+	{0x1037, "CMPR", interregister, 3, 4, 0, "NZVC", (*CPU).cmpr},
+	{0x1038, "PSHSW", inherent, 2, 6, 0, "", (*CPU).pshsw},
+	{0x1039, "PULSW", inherent, 2, 6, 0, "", (*CPU).pulsw},
+	{0x103a, "PSHUW", inherent, 2, 6, 0, "", (*CPU).pshuw},
+	{0x103b, "PULUW", inherent, 2, 6, 0, "", (*CPU).puluw},
+	{0x1040, "NEGD", inherent, 2, 3, 2, "NZVC", (*CPU).negd},
+	{0x1043, "COMD", inherent, 2, 3, 2, "NZVC", (*CPU).comd},
+	{0x1044, "LSRD", inherent, 2, 3, 2, "NZC", (*CPU).lsrd},
+	{0x1046, "RORD", inherent, 2, 3, 2, "NZC", (*CPU).rord},
+	{0x1047, "ASRD", inherent, 2, 3, 2, "NZC", (*CPU).asrd},
+	{0x1048, "ASLD", inherent, 2, 3, 2, "NZVC", (*CPU).asld},
+	{0x1049, "ROLD", inherent, 2, 3, 2, "NZVC", (*CPU).rold},
+	{0x104a, "DECD", inherent, 2, 3, 2, "NZV", (*CPU).decd},
+	{0x104c, "INCD", inherent, 2, 3, 2, "NZV", (*CPU).incd},
+	{0x104d, "TSTD", inherent, 2, 3, 2, "NZV", (*CPU).tstd},
+	{0x104f, "CLRD", inherent, 2, 3, 2, "NZVC", (*CPU).clrd},
+	{0x1053, "COMW", inherent, 2, 3, 2, "NZVC", (*CPU).comw},
+	{0x1054, "LSRW", inherent, 2, 3, 2, "NZC", (*CPU).lsrw},
+	{0x1056, "RORW", inherent, 2, 3, 2, "NZC", (*CPU).rorw},
+	{0x1059, "ROLW", inherent, 2, 3, 2, "NZVC", (*CPU).rolw},
+	{0x105a, "DECW", inherent, 2, 3, 2, "NZV", (*CPU).decw},
+	{0x105c, "INCW", inherent, 2, 3, 2, "NZV", (*CPU).incw},
+	{0x105d, "TSTW", inherent, 2, 3, 2, "NZV", (*CPU).tstw},
+	{0x105f, "CLRW", inherent, 2, 3, 2, "NZVC", (*CPU).clrw},
+	{0x1080, "SUBW", limmediate, 4, 5, 4, "NZVC", (*CPU).subw},
+	{0x1081, "CMPW", limmediate, 4, 5, 4, "NZVC", (*CPU).cmpw},
+	{0x1082, "SBCD", limmediate, 4, 5, 4, "NZVC", (*CPU).sbcd},
+	{0x1084, "ANDD", limmediate, 4, 5, 4, "NZV", (*CPU).andd},
+	{0x1085, "BITD", limmediate, 4, 5, 4, "NZV", (*CPU).bitd},
+	{0x1086, "LDW", limmediate, 4, 4, 0, "NZV", (*CPU).ldw},
+	{0x1088, "EORD", limmediate, 4, 5, 4, "NZV", (*CPU).eord},
+	{0x1089, "ADCD", limmediate, 4, 5, 4, "NZVC", (*CPU).adcd},
+	{0x108a, "ORD", limmediate, 4, 5, 4, "NZV", (*CPU).ord},
+	{0x108b, "ADDW", limmediate, 4, 5, 4, "NZVC", (*CPU).addw},
+	{0x1090, "SUBW", direct, 3, 7, 5, "NZVC", (*CPU).subw},
+	{0x1091, "CMPW", direct, 3, 7, 5, "NZVC", (*CPU).cmpw},
+	{0x1092, "SBCD", direct, 3, 7, 5, "NZVC", (*CPU).sbcd},
+	{0x1094, "ANDD", direct, 3, 7, 5, "NZV", (*CPU).andd},
+	{0x1095, "BITD", direct, 3, 7, 5, "NZV", (*CPU).bitd},
+	{0x1096, "LDW", direct, 3, 6, 5, "NZV", (*CPU).ldw},
+	{0x1097, "STW", direct, 3, 6, 5, "NZV", (*CPU).stw},
+	{0x1098, "EORD", direct, 3, 7, 5, "NZV", (*CPU).eord},
+	{0x1099, "ADCD", direct, 3, 7, 5, "NZVC", (*CPU).adcd},
+	{0x109a, "ORD", direct, 3, 7, 5, "NZV", (*CPU).ord},
+	{0x109b, "ADDW", direct, 3, 7, 5, "NZVC", (*CPU).addw},
+	{0x10a0, "SUBW", indexed, 3, 7, 6, "NZVC", (*CPU).subw},
+	{0x10a1, "CMPW", indexed, 3, 7, 6, "NZVC", (*CPU).cmpw},
+	{0x10a2, "SBCD", indexed, 3, 7, 6, "NZVC", (*CPU).sbcd},
+	{0x10a4, "ANDD", indexed, 3, 7, 6, "NZV", (*CPU).andd},
+	{0x10a5, "BITD", indexed, 3, 7, 6, "NZV", (*CPU).bitd},
+	{0x10a6, "LDW", indexed, 3, 6, 0, "NZV", (*CPU).ldw},
+	{0x10a7, "STW", indexed, 3, 6, 0, "NZV", (*CPU).stw},
+	{0x10a8, "EORD", indexed, 3, 7, 6, "NZV", (*CPU).eord},
+	{0x10a9, "ADCD", indexed, 3, 7, 6, "NZVC", (*CPU).adcd},
+	{0x10aa, "ORD", indexed, 3, 7, 6, "NZV", (*CPU).ord},
+	{0x10ab, "ADDW", indexed, 3, 7, 6, "NZVC", (*CPU).addw},
+	{0x10b0, "SUBW", extended, 4, 8, 6, "NZVC", (*CPU).subw},
+	{0x10b1, "CMPW", extended, 4, 8, 6, "NZVC", (*CPU).cmpw},
+	{0x10b2, "SBCD", extended, 4, 8, 6, "NZVC", (*CPU).sbcd},
+	{0x10b4, "ANDD", extended, 4, 8, 6, "NZV", (*CPU).andd},
+	{0x10b5, "BITD", extended, 4, 8, 6, "NZV", (*CPU).bitd},
+	{0x10b6, "LDW", extended, 4, 7, 6, "NZV", (*CPU).ldw},
+	{0x10b7, "STW", extended, 4, 7, 6, "NZV", (*CPU).stw},
+	{0x10b8, "EORD", extended, 4, 8, 6, "NZV", (*CPU).eord},
+	{0x10b9, "ADCD", extended, 4, 8, 6, "NZVC", (*CPU).adcd},
+	{0x10ba, "ORD", extended, 4, 8, 6, "NZV", (*CPU).ord},
+	{0x10bb, "ADDW", extended, 4, 8, 6, "NZVC", (*CPU).addw},
+	{0x10dc, "LDQ", direct, 3, 8, 7, "NZV", (*CPU).ldq},
+	{0x10dd, "STQ", direct, 3, 8, 7, "NZV", (*CPU).stq},
+	{0x10ec, "LDQ", indexed, 3, 8, 0, "NZV", (*CPU).ldq},
+	{0x10ed, "STQ", indexed, 3, 8, 0, "NZV", (*CPU).stq},
+	{0x10fc, "LDQ", extended, 4, 9, 8, "NZV", (*CPU).ldq},
+	{0x10fd, "STQ", extended, 4, 9, 8, "NZV", (*CPU).stq},
+	{0x1130, "BAND", bitdirect, 4, 7, 6, "", (*CPU).band},
+	{0x1131, "BIAND", bitdirect, 4, 7, 6, "", (*CPU).biand},
+	{0x1132, "BOR", bitdirect, 4, 7, 6, "", (*CPU).bor},
+	{0x1133, "BIOR", bitdirect, 4, 7, 6, "", (*CPU).bior},
+	{0x1134, "BEOR", bitdirect, 4, 7, 6, "", (*CPU).beor},
+	{0x1135, "BIEOR", bitdirect, 4, 7, 6, "", (*CPU).bieor},
+	{0x1136, "LDBT", bitdirect, 4, 7, 6, "", (*CPU).ldbt},
+	{0x1137, "STBT", bitdirect, 4, 8, 7, "", (*CPU).stbt},
+	{0x1138, "TFM", blockmove, 3, 6, 0, "", (*CPU).tfmForward},
+	{0x1139, "TFM", blockmove, 3, 6, 0, "", (*CPU).tfmBackward},
+	{0x113a, "TFM", blockmove, 3, 6, 0, "", (*CPU).tfmSource},
+	{0x113b, "TFM", blockmove, 3, 6, 0, "", (*CPU).tfmDestination},
+	{0x113c, "BITMD", immediate, 3, 4, 0, "Z", (*CPU).bitmd},
+	{0x113d, "LDMD", immediate, 3, 5, 0, "", (*CPU).ldmd},
+	{0x1143, "COME", inherent, 2, 3, 2, "NZVC", (*CPU).come},
+	{0x114a, "DECE", inherent, 2, 3, 2, "NZV", (*CPU).dece},
+	{0x114c, "INCE", inherent, 2, 3, 2, "NZV", (*CPU).ince},
+	{0x114d, "TSTE", inherent, 2, 3, 2, "NZV", (*CPU).tste},
+	{0x114f, "CLRE", inherent, 2, 3, 2, "NZVC", (*CPU).clre},
+	{0x1153, "COMF", inherent, 2, 3, 2, "NZVC", (*CPU).comf},
+	{0x115a, "DECF", inherent, 2, 3, 2, "NZV", (*CPU).decf},
+	{0x115c, "INCF", inherent, 2, 3, 2, "NZV", (*CPU).incf},
+	{0x115d, "TSTF", inherent, 2, 3, 2, "NZV", (*CPU).tstf},
+	{0x115f, "CLRF", inherent, 2, 3, 2, "NZVC", (*CPU).clrf},
+	{0x1180, "SUBE", immediate, 3, 3, 0, "NZVC", (*CPU).sube},
+	{0x1181, "CMPE", immediate, 3, 3, 0, "NZVC", (*CPU).cmpe},
+	{0x1186, "LDE", immediate, 3, 3, 0, "NZV", (*CPU).lde},
+	{0x118b, "ADDE", immediate, 3, 3, 0, "HNZVC", (*CPU).adde},
+	{0x118d, "DIVD", immediate, 3, 25, 24, "NZVC", (*CPU).divd},
+	{0x118e, "DIVQ", limmediate, 4, 34, 33, "NZVC", (*CPU).divq},
+	{0x118f, "MULD", limmediate, 4, 28, 0, "NZ", (*CPU).muld},
+	{0x1190, "SUBE", direct, 3, 5, 4, "NZVC", (*CPU).sube},
+	{0x1191, "CMPE", direct, 3, 5, 4, "NZVC", (*CPU).cmpe},
+	{0x1196, "LDE", direct, 3, 5, 4, "NZV", (*CPU).lde},
+	{0x1197, "STE", direct, 3, 5, 4, "NZV", (*CPU).ste},
+	{0x119b, "ADDE", direct, 3, 5, 4, "HNZVC", (*CPU).adde},
+	{0x119d, "DIVD", direct, 3, 27, 26, "NZVC", (*CPU).divd},
+	{0x119e, "DIVQ", direct, 3, 36, 35, "NZVC", (*CPU).divq},
+	{0x119f, "MULD", direct, 3, 30, 29, "NZ", (*CPU).muld},
+	{0x11a0, "SUBE", indexed, 3, 5, 0, "NZVC", (*CPU).sube},
+	{0x11a1, "CMPE", indexed, 3, 5, 0, "NZVC", (*CPU).cmpe},
+	{0x11a6, "LDE", indexed, 3, 5, 0, "NZV", (*CPU).lde},
+	{0x11a7, "STE", indexed, 3, 5, 0, "NZV", (*CPU).ste},
+	{0x11ab, "ADDE", indexed, 3, 5, 0, "HNZVC", (*CPU).adde},
+	{0x11ad, "DIVD", indexed, 3, 27, 26, "NZVC", (*CPU).divd},
+	{0x11ae, "DIVQ", indexed, 3, 36, 35, "NZVC", (*CPU).divq},
+	{0x11af, "MULD", indexed, 3, 30, 0, "NZ", (*CPU).muld},
+	{0x11b0, "SUBE", extended, 4, 6, 5, "NZVC", (*CPU).sube},
+	{0x11b1, "CMPE", extended, 4, 6, 5, "NZVC", (*CPU).cmpe},
+	{0x11b6, "LDE", extended, 4, 6, 5, "NZV", (*CPU).lde},
+	{0x11b7, "STE", extended, 4, 6, 5, "NZV", (*CPU).ste},
+	{0x11bb, "ADDE", extended, 4, 6, 5, "HNZVC", (*CPU).adde},
+	{0x11bd, "DIVD", extended, 4, 28, 27, "NZVC", (*CPU).divd},
+	{0x11be, "DIVQ", extended, 4, 37, 36, "NZVC", (*CPU).divq},
+	{0x11bf, "MULD", extended, 4, 31, 30, "NZ", (*CPU).muld},
+	{0x11c0, "SUBF", immediate, 3, 3, 0, "NZVC", (*CPU).subf},
+	{0x11c1, "CMPF", immediate, 3, 3, 0, "NZVC", (*CPU).cmpf},
+	{0x11c6, "LDF", immediate, 3, 3, 0, "NZV", (*CPU).ldf},
+	{0x11cb, "ADDF", immediate, 3, 3, 0, "HNZVC", (*CPU).addf},
+	{0x11d0, "SUBF", direct, 3, 5, 4, "NZVC", (*CPU).subf},
+	{0x11d1, "CMPF", direct, 3, 5, 4, "NZVC", (*CPU).cmpf},
+	{0x11d6, "LDF", direct, 3, 5, 4, "NZV", (*CPU).ldf},
+	{0x11d7, "STF", direct, 3, 5, 4, "NZV", (*CPU).stf},
+	{0x11db, "ADDF", direct, 3, 5, 4, "HNZVC", (*CPU).addf},
+	{0x11e0, "SUBF", indexed, 3, 5, 0, "NZVC", (*CPU).subf},
+	{0x11e1, "CMPF", indexed, 3, 5, 0, "NZVC", (*CPU).cmpf},
+	{0x11e6, "LDF", indexed, 3, 5, 0, "NZV", (*CPU).ldf},
+	{0x11e7, "STF", indexed, 3, 5, 0, "NZV", (*CPU).stf},
+	{0x11eb, "ADDF", indexed, 3, 5, 0, "HNZVC", (*CPU).addf},
+	{0x11f0, "SUBF", extended, 4, 6, 5, "NZVC", (*CPU).subf},
+	{0x11f1, "CMPF", extended, 4, 6, 5, "NZVC", (*CPU).cmpf},
+	{0x11f6, "LDF", extended, 4, 6, 5, "NZV", (*CPU).ldf},
+	{0x11f7, "STF", extended, 4, 6, 5, "NZV", (*CPU).stf},
+	{0x11fb, "ADDF", extended, 4, 6, 5, "HNZVC", (*CPU).addf},
+}
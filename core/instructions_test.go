@@ -0,0 +1,434 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// MC6809 datasheet instruction table: opcode, mnemonic, addressing mode, bytes,
+// cycles and condition code flags affected. Indexed mode cycles and bytes are the
+// ones of the ,R postbyte. Long conditional branches take one more cycle when the
+// branch is taken.
+const datasheet = `
+00    NEG   DIR 2  6 NZVC
+03    COM   DIR 2  6 NZVC
+04    LSR   DIR 2  6 NZC
+06    ROR   DIR 2  6 NZC
+07    ASR   DIR 2  6 NZC
+08    ASL   DIR 2  6 NZVC
+09    ROL   DIR 2  6 NZVC
+0A    DEC   DIR 2  6 NZV
+0C    INC   DIR 2  6 NZV
+0D    TST   DIR 2  6 NZV
+0E    JMP   DIR 2  3 -
+0F    CLR   DIR 2  6 NZVC
+12    NOP   INH 1  2 -
+13    SYNC  INH 1  4 -
+16    LBRA  REL 3  5 -
+17    LBSR  REL 3  9 -
+19    DAA   INH 1  2 NZC
+1A    ORCC  IMM 2  3 EFHINZVC
+1C    ANDCC IMM 2  3 EFHINZVC
+1D    SEX   INH 1  2 NZ
+1E    EXG   IMM 2  8 -
+1F    TFR   IMM 2  6 -
+20    BRA   REL 2  3 -
+21    BRN   REL 2  3 -
+22    BHI   REL 2  3 -
+23    BLS   REL 2  3 -
+24    BCC   REL 2  3 -
+25    BLO   REL 2  3 -
+26    BNE   REL 2  3 -
+27    BEQ   REL 2  3 -
+28    BVC   REL 2  3 -
+29    BVS   REL 2  3 -
+2A    BPL   REL 2  3 -
+2B    BMI   REL 2  3 -
+2C    BGE   REL 2  3 -
+2D    BLT   REL 2  3 -
+2E    BGT   REL 2  3 -
+2F    BLE   REL 2  3 -
+30    LEAX  IDX 2  4 Z
+31    LEAY  IDX 2  4 Z
+32    LEAS  IDX 2  4 -
+33    LEAU  IDX 2  4 -
+34    PSHS  IMM 2  5 -
+35    PULS  IMM 2  5 -
+36    PSHU  IMM 2  5 -
+37    PULU  IMM 2  5 -
+39    RTS   INH 1  5 -
+3A    ABX   INH 1  3 -
+3B    RTI   INH 1  6 EFHINZVC
+3C    CWAI  IMM 2 20 EFHINZVC
+3D    MUL   INH 1 11 ZC
+3F    SWI   INH 1 19 EFI
+40    NEGA  INH 1  2 NZVC
+43    COMA  INH 1  2 NZVC
+44    LSRA  INH 1  2 NZC
+46    RORA  INH 1  2 NZC
+47    ASRA  INH 1  2 NZC
+48    ASLA  INH 1  2 NZVC
+49    ROLA  INH 1  2 NZVC
+4A    DECA  INH 1  2 NZV
+4C    INCA  INH 1  2 NZV
+4D    TSTA  INH 1  2 NZV
+4F    CLRA  INH 1  2 NZVC
+50    NEGB  INH 1  2 NZVC
+53    COMB  INH 1  2 NZVC
+54    LSRB  INH 1  2 NZC
+56    RORB  INH 1  2 NZC
+57    ASRB  INH 1  2 NZC
+58    ASLB  INH 1  2 NZVC
+59    ROLB  INH 1  2 NZVC
+5A    DECB  INH 1  2 NZV
+5C    INCB  INH 1  2 NZV
+5D    TSTB  INH 1  2 NZV
+5F    CLRB  INH 1  2 NZVC
+60    NEG   IDX 2  6 NZVC
+63    COM   IDX 2  6 NZVC
+64    LSR   IDX 2  6 NZC
+66    ROR   IDX 2  6 NZC
+67    ASR   IDX 2  6 NZC
+68    ASL   IDX 2  6 NZVC
+69    ROL   IDX 2  6 NZVC
+6A    DEC   IDX 2  6 NZV
+6C    INC   IDX 2  6 NZV
+6D    TST   IDX 2  6 NZV
+6E    JMP   IDX 2  3 -
+6F    CLR   IDX 2  6 NZVC
+70    NEG   EXT 3  7 NZVC
+73    COM   EXT 3  7 NZVC
+74    LSR   EXT 3  7 NZC
+76    ROR   EXT 3  7 NZC
+77    ASR   EXT 3  7 NZC
+78    ASL   EXT 3  7 NZVC
+79    ROL   EXT 3  7 NZVC
+7A    DEC   EXT 3  7 NZV
+7C    INC   EXT 3  7 NZV
+7D    TST   EXT 3  7 NZV
+7E    JMP   EXT 3  4 -
+7F    CLR   EXT 3  7 NZVC
+80    SUBA  IMM 2  2 NZVC
+81    CMPA  IMM 2  2 NZVC
+82    SBCA  IMM 2  2 NZVC
+83    SUBD  IMM 3  4 NZVC
+84    ANDA  IMM 2  2 NZV
+85    BITA  IMM 2  2 NZV
+86    LDA   IMM 2  2 NZV
+88    EORA  IMM 2  2 NZV
+89    ADCA  IMM 2  2 HNZVC
+8A    ORA   IMM 2  2 NZV
+8B    ADDA  IMM 2  2 HNZVC
+8C    CMPX  IMM 3  4 NZVC
+8D    BSR   REL 2  7 -
+8E    LDX   IMM 3  3 NZV
+90    SUBA  DIR 2  4 NZVC
+91    CMPA  DIR 2  4 NZVC
+92    SBCA  DIR 2  4 NZVC
+93    SUBD  DIR 2  6 NZVC
+94    ANDA  DIR 2  4 NZV
+95    BITA  DIR 2  4 NZV
+96    LDA   DIR 2  4 NZV
+97    STA   DIR 2  4 NZV
+98    EORA  DIR 2  4 NZV
+99    ADCA  DIR 2  4 HNZVC
+9A    ORA   DIR 2  4 NZV
+9B    ADDA  DIR 2  4 HNZVC
+9C    CMPX  DIR 2  6 NZVC
+9D    JSR   DIR 2  7 -
+9E    LDX   DIR 2  5 NZV
+9F    STX   DIR 2  5 NZV
+A0    SUBA  IDX 2  4 NZVC
+A1    CMPA  IDX 2  4 NZVC
+A2    SBCA  IDX 2  4 NZVC
+A3    SUBD  IDX 2  6 NZVC
+A4    ANDA  IDX 2  4 NZV
+A5    BITA  IDX 2  4 NZV
+A6    LDA   IDX 2  4 NZV
+A7    STA   IDX 2  4 NZV
+A8    EORA  IDX 2  4 NZV
+A9    ADCA  IDX 2  4 HNZVC
+AA    ORA   IDX 2  4 NZV
+AB    ADDA  IDX 2  4 HNZVC
+AC    CMPX  IDX 2  6 NZVC
+AD    JSR   IDX 2  7 -
+AE    LDX   IDX 2  5 NZV
+AF    STX   IDX 2  5 NZV
+B0    SUBA  EXT 3  5 NZVC
+B1    CMPA  EXT 3  5 NZVC
+B2    SBCA  EXT 3  5 NZVC
+B3    SUBD  EXT 3  7 NZVC
+B4    ANDA  EXT 3  5 NZV
+B5    BITA  EXT 3  5 NZV
+B6    LDA   EXT 3  5 NZV
+B7    STA   EXT 3  5 NZV
+B8    EORA  EXT 3  5 NZV
+B9    ADCA  EXT 3  5 HNZVC
+BA    ORA   EXT 3  5 NZV
+BB    ADDA  EXT 3  5 HNZVC
+BC    CMPX  EXT 3  7 NZVC
+BD    JSR   EXT 3  8 -
+BE    LDX   EXT 3  6 NZV
+BF    STX   EXT 3  6 NZV
+C0    SUBB  IMM 2  2 NZVC
+C1    CMPB  IMM 2  2 NZVC
+C2    SBCB  IMM 2  2 NZVC
+C3    ADDD  IMM 3  4 NZVC
+C4    ANDB  IMM 2  2 NZV
+C5    BITB  IMM 2  2 NZV
+C6    LDB   IMM 2  2 NZV
+C8    EORB  IMM 2  2 NZV
+C9    ADCB  IMM 2  2 HNZVC
+CA    ORB   IMM 2  2 NZV
+CB    ADDB  IMM 2  2 HNZVC
+CC    LDD   IMM 3  3 NZV
+CE    LDU   IMM 3  3 NZV
+D0    SUBB  DIR 2  4 NZVC
+D1    CMPB  DIR 2  4 NZVC
+D2    SBCB  DIR 2  4 NZVC
+D3    ADDD  DIR 2  6 NZVC
+D4    ANDB  DIR 2  4 NZV
+D5    BITB  DIR 2  4 NZV
+D6    LDB   DIR 2  4 NZV
+D7    STB   DIR 2  4 NZV
+D8    EORB  DIR 2  4 NZV
+D9    ADCB  DIR 2  4 HNZVC
+DA    ORB   DIR 2  4 NZV
+DB    ADDB  DIR 2  4 HNZVC
+DC    LDD   DIR 2  5 NZV
+DD    STD   DIR 2  5 NZV
+DE    LDU   DIR 2  5 NZV
+DF    STU   DIR 2  5 NZV
+E0    SUBB  IDX 2  4 NZVC
+E1    CMPB  IDX 2  4 NZVC
+E2    SBCB  IDX 2  4 NZVC
+E3    ADDD  IDX 2  6 NZVC
+E4    ANDB  IDX 2  4 NZV
+E5    BITB  IDX 2  4 NZV
+E6    LDB   IDX 2  4 NZV
+E7    STB   IDX 2  4 NZV
+E8    EORB  IDX 2  4 NZV
+E9    ADCB  IDX 2  4 HNZVC
+EA    ORB   IDX 2  4 NZV
+EB    ADDB  IDX 2  4 HNZVC
+EC    LDD   IDX 2  5 NZV
+ED    STD   IDX 2  5 NZV
+EE    LDU   IDX 2  5 NZV
+EF    STU   IDX 2  5 NZV
+F0    SUBB  EXT 3  5 NZVC
+F1    CMPB  EXT 3  5 NZVC
+F2    SBCB  EXT 3  5 NZVC
+F3    ADDD  EXT 3  7 NZVC
+F4    ANDB  EXT 3  5 NZV
+F5    BITB  EXT 3  5 NZV
+F6    LDB   EXT 3  5 NZV
+F7    STB   EXT 3  5 NZV
+F8    EORB  EXT 3  5 NZV
+F9    ADCB  EXT 3  5 HNZVC
+FA    ORB   EXT 3  5 NZV
+FB    ADDB  EXT 3  5 HNZVC
+FC    LDD   EXT 3  6 NZV
+FD    STD   EXT 3  6 NZV
+FE    LDU   EXT 3  6 NZV
+FF    STU   EXT 3  6 NZV
+1021  LBRN  REL 4  5 -
+1022  LBHI  REL 4  5 -
+1023  LBLS  REL 4  5 -
+1024  LBCC  REL 4  5 -
+1025  LBCS  REL 4  5 -
+1026  LBNE  REL 4  5 -
+1027  LBEQ  REL 4  5 -
+1028  LBVC  REL 4  5 -
+1029  LBVS  REL 4  5 -
+102A  LBPL  REL 4  5 -
+102B  LBMI  REL 4  5 -
+102C  LBGE  REL 4  5 -
+102D  LBLT  REL 4  5 -
+102E  LBGT  REL 4  5 -
+102F  LBLE  REL 4  5 -
+103F  SWI2  INH 2 20 E
+1083  CMPD  IMM 4  5 NZVC
+108C  CMPY  IMM 4  5 NZVC
+108E  LDY   IMM 4  4 NZV
+1093  CMPD  DIR 3  7 NZVC
+109C  CMPY  DIR 3  7 NZVC
+109E  LDY   DIR 3  6 NZV
+109F  STY   DIR 3  6 NZV
+10A3  CMPD  IDX 3  7 NZVC
+10AC  CMPY  IDX 3  7 NZVC
+10AE  LDY   IDX 3  6 NZV
+10AF  STY   IDX 3  6 NZV
+10B3  CMPD  EXT 4  8 NZVC
+10BC  CMPY  EXT 4  8 NZVC
+10BE  LDY   EXT 4  7 NZV
+10BF  STY   EXT 4  7 NZV
+10CE  LDS   IMM 4  4 NZV
+10DE  LDS   DIR 3  6 NZV
+10DF  STS   DIR 3  6 NZV
+10EE  LDS   IDX 3  6 NZV
+10EF  STS   IDX 3  6 NZV
+10FE  LDS   EXT 4  7 NZV
+10FF  STS   EXT 4  7 NZV
+113F  SWI3  INH 2 20 E
+1183  CMPU  IMM 4  5 NZVC
+118C  CMPS  IMM 4  5 NZVC
+1193  CMPU  DIR 3  7 NZVC
+119C  CMPS  DIR 3  7 NZVC
+11A3  CMPU  IDX 3  7 NZVC
+11AC  CMPS  IDX 3  7 NZVC
+11B3  CMPU  EXT 4  8 NZVC
+11BC  CMPS  EXT 4  8 NZVC
+`
+
+type datasheetEntry struct {
+	code   int
+	name   string
+	mode   string
+	size   int
+	cycles uint64
+	flags  string
+}
+
+func parseDatasheet() []datasheetEntry {
+	entries := []datasheetEntry{}
+	for _, line := range strings.Split(strings.TrimSpace(datasheet), "\n") {
+		f := strings.Fields(line)
+		code, _ := strconv.ParseInt(f[0], 16, 32)
+		size, _ := strconv.Atoi(f[3])
+		cycles, _ := strconv.Atoi(f[4])
+		flags := f[5]
+		if flags == "-" {
+			flags = ""
+		}
+		entries = append(entries, datasheetEntry{int(code), f[1], f[2], size, uint64(cycles), flags})
+	}
+	return entries
+}
+
+var datasheetModes = map[addressMode]string{
+	inherent:   "INH",
+	direct:     "DIR",
+	immediate:  "IMM",
+	limmediate: "IMM",
+	transfer:   "IMM",
+	stack:      "IMM",
+	relative:   "REL",
+	lrelative:  "REL",
+	extended:   "EXT",
+	indexed:    "IDX",
+}
+
+// Instructions which do not continue with the next instruction when their operands are zero
+var jumps = map[string]bool{"JMP": true, "JSR": true, "RTS": true, "RTI": true, "SWI": true, "SWI2": true, "SWI3": true}
+
+/** Encode an instruction with operands selecting the base cycles of its addressing mode */
+func encode(in instruction) []uint8 {
+	bytes := []uint8{}
+	if in.code > 0xff {
+		bytes = append(bytes, uint8(in.code>>8))
+	}
+	bytes = append(bytes, uint8(in.code))
+	switch in.mode {
+	case indexed, imindexed:
+		if in.mode == imindexed {
+			bytes = append(bytes, 0x00)
+		}
+		bytes = append(bytes, 0x84) // ,X
+	case transfer:
+		bytes = append(bytes, 0x89) // A,B
+	case interregister, blockmove:
+		bytes = append(bytes, 0x12) // X,Y
+	}
+	for len(bytes) < in.size {
+		bytes = append(bytes, 0x00)
+	}
+	return bytes
+}
+
+var _ = Describe("Instruction set", func() {
+
+	It("should match the MC6809 datasheet", func() {
+		expected := parseDatasheet()
+		Expect(mc6809Instructions).To(HaveLen(len(expected)))
+		for i, in := range mc6809Instructions {
+			entry := expected[i]
+			description := fmt.Sprintf("opcode %02x", entry.code)
+			Expect(in.code).To(Equal(entry.code), description)
+			Expect(in.name).To(Equal(entry.name), description)
+			Expect(datasheetModes[in.mode]).To(Equal(entry.mode), description)
+			Expect(in.size).To(Equal(entry.size), description)
+			Expect(in.cycles).To(Equal(entry.cycles), description)
+			Expect(in.flags).To(Equal(entry.flags), description)
+		}
+	})
+
+	It("should not define an opcode twice", func() {
+		for _, set := range [][]instruction{mc6809Instructions, undocumentedInstructions, hd6309Instructions} {
+			codes := map[int]bool{}
+			for _, in := range set {
+				Expect(codes).NotTo(HaveKey(in.code), "opcode %02x", in.code)
+				codes[in.code] = true
+			}
+		}
+	})
+
+	It("should derive the dispatch table from the specification", func() {
+		cpu := NewCPU(NewRam(), MC6809)
+		defined := 0
+		for _, page := range []int{0x0000, 0x1000, 0x1100} {
+			for code := page; code < page+0x100; code++ {
+				if _, ok := cpu.opcodes.lookup(code); ok {
+					defined++
+				}
+			}
+		}
+		Expect(defined).To(Equal(len(mc6809Instructions)))
+		for _, in := range mc6809Instructions {
+			op, ok := cpu.opcodes.lookup(in.code)
+			Expect(ok).To(BeTrue())
+			Expect(op.name).To(Equal(in.name))
+			Expect(op.mode).To(Equal(in.mode))
+		}
+	})
+
+	It("should disassemble every instruction with its specified size", func() {
+		for _, set := range [][]instruction{mc6809Instructions, undocumentedInstructions, hd6309Instructions} {
+			cpu := NewCPU(NewRam(), HD6309)
+			for _, in := range set {
+				bytes := encode(in)
+				if in.code > 0xff {
+					bytes = bytes[1:]
+				}
+				_, size := Disassemble(cpu.bind(in), append(bytes, 0, 0, 0, 0))
+				Expect(size+len(encode(in))-len(bytes)).To(Equal(in.size), "opcode %02x", in.code)
+			}
+		}
+	})
+
+	It("should execute every MC6809 instruction with its datasheet size and cycles", func() {
+		for _, in := range mc6809Instructions {
+			cpu := NewCPU(NewRam(), MC6809)
+			cpu.pc.set(0x1000)
+			cpu.s.set(0x8000)
+			for i, b := range encode(in) {
+				cpu.write(0x1000+uint16(i), b)
+			}
+			cycles, err := cpu.step()
+
+			description := fmt.Sprintf("opcode %02x %s", in.code, in.name)
+			Expect(err).NotTo(HaveOccurred(), description)
+			if strings.HasPrefix(in.name, "LB") && in.name != "LBRA" && in.name != "LBSR" && in.name != "LBRN" {
+				Expect(cycles).To(BeNumerically("~", in.cycles, 1), description)
+			} else {
+				Expect(cycles).To(Equal(in.cycles), description)
+			}
+			if !jumps[in.name] {
+				Expect(cpu.pc.uint16()).To(BeEquivalentTo(0x1000+in.size), description)
+			}
+		}
+	})
+})
@@ -0,0 +1,174 @@
+package core
+
+/* Interrupt vectors */
+
+const (
+	vectorSWI3  uint16 = 0xfff2
+	vectorSWI2  uint16 = 0xfff4
+	vectorFIRQ  uint16 = 0xfff6
+	vectorIRQ   uint16 = 0xfff8
+	vectorSWI   uint16 = 0xfffa
+	vectorNMI   uint16 = 0xfffc
+	vectorReset uint16 = 0xfffe
+)
+
+// Interrupt lines state
+type interruptLines struct {
+	/// IRQ line is asserted (level sensitive)
+	irq bool
+	/// FIRQ line is asserted (level sensitive)
+	firq bool
+	/// NMI line is asserted
+	nmi bool
+	/// A falling edge has been detected on the NMI line and is not serviced yet
+	nmiLatch bool
+	/// NMI is disabled after reset until the S register is first loaded
+	nmiArmed bool
+	/// RESET line is asserted
+	reset bool
+	/// RESET has been asserted and the reset sequence has not been run yet
+	resetLatch bool
+}
+
+// AssertIRQ pulls the IRQ line low. The line is level sensitive: the interrupt is
+// taken between two instructions as long as the line stays asserted and the I flag
+// is clear.
+func (c *CPU) AssertIRQ() {
+	c.lines.irq = true
+}
+
+// ReleaseIRQ releases the IRQ line.
+func (c *CPU) ReleaseIRQ() {
+	c.lines.irq = false
+}
+
+// AssertFIRQ pulls the FIRQ line low. The line is level sensitive: the interrupt is
+// taken between two instructions as long as the line stays asserted and the F flag
+// is clear.
+func (c *CPU) AssertFIRQ() {
+	c.lines.firq = true
+}
+
+// ReleaseFIRQ releases the FIRQ line.
+func (c *CPU) ReleaseFIRQ() {
+	c.lines.firq = false
+}
+
+// AssertNMI pulls the NMI line low. The NMI is edge triggered: a single interrupt is
+// latched on the transition and the line must be released before another one can
+// be detected.
+func (c *CPU) AssertNMI() {
+	if !c.lines.nmi {
+		c.lines.nmiLatch = true
+	}
+	c.lines.nmi = true
+}
+
+// ReleaseNMI releases the NMI line.
+func (c *CPU) ReleaseNMI() {
+	c.lines.nmi = false
+}
+
+// AssertReset pulls the RESET line low. The CPU is halted as long as the line is
+// asserted and runs the reset sequence at the next instruction boundary once it is
+// released. It is meant to be used by devices (reset button, software reset).
+func (c *CPU) AssertReset() {
+	c.lines.reset = true
+	c.lines.resetLatch = true
+}
+
+// ReleaseReset releases the RESET line.
+func (c *CPU) ReleaseReset() {
+	c.lines.reset = false
+}
+
+/** NMI is not recognized after reset until the hardware stack pointer is loaded */
+func (c *CPU) armNMI() {
+	c.lines.nmiArmed = true
+}
+
+/** Push the entire machine state on the hardware stack, W included in HD6309 native mode */
+func (c *CPU) pushEntireState() {
+	c.cc.setE()
+	c.pushRegister(&c.pc, c.s)
+	c.pushRegister(&c.u, c.s)
+	c.pushRegister(&c.y, c.s)
+	c.pushRegister(&c.x, c.s)
+	c.pushRegister(&c.dp, c.s)
+	if c.nativeMode() {
+		c.pushRegister(&c.f, c.s)
+		c.pushRegister(&c.e, c.s)
+	}
+	c.pushRegister(&c.b, c.s)
+	c.pushRegister(&c.a, c.s)
+	c.pushRegister(&c.cc, c.s)
+}
+
+/** Push PC and CC on the hardware stack (FIRQ) */
+func (c *CPU) pushFastState() {
+	c.cc.clearE()
+	c.pushRegister(&c.pc, c.s)
+	c.pushRegister(&c.cc, c.s)
+}
+
+/** Stack the entire state unless it has already been done by CWAI. The bus
+ * sequence reads the next opcode twice and spends a dead cycle before and after
+ * the registers are pushed. */
+func (c *CPU) stackEntireState() {
+	if c.state == waiting {
+		return
+	}
+	c.interruptCycles()
+	c.pushEntireState()
+	c.deadCycles(1)
+	c.clock += 7 // the rest is counted when the registers are pushed
+}
+
+/** Stack PC and CC unless the entire state has already been stacked by CWAI */
+func (c *CPU) stackFastState() {
+	if c.state == waiting {
+		return
+	}
+	c.interruptCycles()
+	c.pushFastState()
+	c.deadCycles(1)
+	c.clock += 7 // the rest is counted when the registers are pushed
+}
+
+/** Cycles of the interrupt sequence before the registers are pushed */
+func (c *CPU) interruptCycles() {
+	c.dummyRead(c.pc.uint16())
+	c.dummyRead(c.pc.uint16())
+	c.deadCycles(1)
+}
+
+// interrupt samples the interrupt lines and enters the highest priority pending
+// interrupt. It returns true if an interrupt has been taken.
+func (c *CPU) interrupt() bool {
+	if c.lines.nmiLatch && c.lines.nmiArmed {
+		c.lines.nmiLatch = false
+		c.stackEntireState() // NMI is 19 cycles
+		c.cc.setF()
+		c.cc.setI()
+		c.pc.set(int(c.readw(vectorNMI)))
+		return true
+	}
+	if c.lines.firq && !c.cc.getF() {
+		if c.md.get()&mdFIRQ != 0 {
+			c.stackEntireState() // the HD6309 can be set to stack the entire state
+		} else {
+			c.stackFastState() // FIRQ is 10 cycles
+		}
+		c.cc.setF()
+		c.cc.setI()
+		c.pc.set(int(c.readw(vectorFIRQ)))
+		return true
+	}
+	if c.lines.irq && !c.cc.getI() {
+		c.stackEntireState() // IRQ is 19 cycles
+		c.cc.setI()
+		c.pc.set(int(c.readw(vectorIRQ)))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+)
+
+var _ = Describe("Interrupts", func() {
+	var (
+		cpu CPU
+	)
+
+	BeforeEach(func() {
+		cpu = CPU{}
+		ram := NewRam()
+		cpu.Initialize(ram)
+		cpu.pc.set(0x1000)
+		cpu.s.set(0x2000)
+		cpu.armNMI()
+		cpu.a.set(0x2f)
+		cpu.x.set(0xe0ff)
+		cpu.write(0x1000, 0x12) // NOP
+		cpu.writew(0xfff6, 0xc100)
+		cpu.writew(0xfff8, 0xc200)
+		cpu.writew(0xfffc, 0xc300)
+	})
+
+	It("should take IRQ when the I flag is clear", func() {
+		cpu.AssertIRQ()
+		cpu.step()
+
+		ExpectWord(cpu, 0x2000-2, 0x1000)
+		ExpectWord(cpu, 0x2000-8, 0xe0ff)
+		ExpectMemory(cpu, 0x2000-11, 0x2f)
+		ExpectMemory(cpu, 0x2000-12, 0x80)
+		ExpectS(cpu, 0x2000-12)
+		ExpectPC(cpu, 0xc200)
+		ExpectClock(cpu, 19)
+		ExpectCCR(cpu, "EI", "F")
+	})
+
+	It("should ignore IRQ when the I flag is set", func() {
+		cpu.cc.setI()
+		cpu.AssertIRQ()
+		cpu.step()
+
+		ExpectPC(cpu, 0x1001)
+		ExpectS(cpu, 0x2000)
+		ExpectClock(cpu, 2)
+	})
+
+	It("should take IRQ again as long as the line is asserted", func() {
+		cpu.AssertIRQ()
+		cpu.step()
+		cpu.cc.clearI()
+		cpu.step()
+		ExpectS(cpu, 0x2000-24)
+
+		cpu.ReleaseIRQ()
+		cpu.cc.clearI()
+		cpu.write(0xc200, 0x12) // NOP
+		cpu.step()
+		ExpectPC(cpu, 0xc201)
+	})
+
+	It("should take FIRQ when the F flag is clear", func() {
+		cpu.cc.setC()
+		cpu.AssertFIRQ()
+		cpu.step()
+
+		ExpectWord(cpu, 0x2000-2, 0x1000)
+		ExpectMemory(cpu, 0x2000-3, 0x01)
+		ExpectS(cpu, 0x2000-3)
+		ExpectPC(cpu, 0xc100)
+		ExpectClock(cpu, 10)
+		ExpectCCR(cpu, "FIC", "E")
+	})
+
+	It("should ignore FIRQ when the F flag is set", func() {
+		cpu.cc.setF()
+		cpu.AssertFIRQ()
+		cpu.step()
+
+		ExpectPC(cpu, 0x1001)
+		ExpectS(cpu, 0x2000)
+	})
+
+	It("should give FIRQ priority over IRQ", func() {
+		cpu.AssertIRQ()
+		cpu.AssertFIRQ()
+		cpu.step()
+
+		ExpectPC(cpu, 0xc100)
+	})
+
+	It("should take NMI once on the falling edge", func() {
+		cpu.cc.setI()
+		cpu.cc.setF()
+		cpu.AssertNMI()
+		cpu.step()
+
+		ExpectWord(cpu, 0x2000-2, 0x1000)
+		ExpectMemory(cpu, 0x2000-12, 0xd0)
+		ExpectPC(cpu, 0xc300)
+		ExpectClock(cpu, 19)
+		ExpectCCR(cpu, "EFI", "")
+
+		cpu.write(0xc300, 0x12) // NOP
+		cpu.step()
+		ExpectPC(cpu, 0xc301)
+
+		cpu.ReleaseNMI()
+		cpu.AssertNMI()
+		cpu.step()
+		ExpectPC(cpu, 0xc300)
+	})
+
+	It("should give NMI priority over FIRQ and IRQ", func() {
+		cpu.AssertIRQ()
+		cpu.AssertFIRQ()
+		cpu.AssertNMI()
+		cpu.step()
+
+		ExpectPC(cpu, 0xc300)
+	})
+
+	It("should not take NMI until S is loaded after reset", func() {
+		cpu.Reset()
+		cpu.pc.set(0x1000)
+		cpu.AssertNMI()
+		cpu.step()
+		ExpectPC(cpu, 0x1001)
+
+		cpu.pc.set(0x1000)
+		cpu.writew(0x1000, 0x10ce) // LDS #$2000
+		cpu.writew(0x1002, 0x2000)
+		cpu.step()
+		ExpectPC(cpu, 0x1004)
+		cpu.step()
+		ExpectPC(cpu, 0xc300)
+	})
+
+	It("should return from IRQ with RTI", func() {
+		cpu.AssertIRQ()
+		cpu.step()
+		cpu.ReleaseIRQ()
+		cpu.a.set(0)
+		cpu.write(0xc200, 0x3b) // RTI
+		cpu.step()
+
+		ExpectPC(cpu, 0x1000)
+		ExpectA(cpu, 0x2f)
+		ExpectS(cpu, 0x2000)
+	})
+
+	It("should return from FIRQ with RTI", func() {
+		cpu.AssertFIRQ()
+		cpu.step()
+		cpu.ReleaseFIRQ()
+		cpu.write(0xc100, 0x3b) // RTI
+		cpu.step()
+
+		ExpectPC(cpu, 0x1000)
+		ExpectS(cpu, 0x2000)
+		ExpectCCR(cpu, "", "FI")
+	})
+
+	Context("[CWAI]", func() {
+
+		It("should stack the entire state and wait for an interrupt", func() {
+			cpu.cc.set(0xd5)
+			cpu.write(0x1000, 0x3c) // CWAI #$ef
+			cpu.write(0x1001, 0xef)
+			cpu.step()
+
+			ExpectPC(cpu, 0x1002)
+			ExpectWord(cpu, 0x2000-2, 0x1002)
+			ExpectMemory(cpu, 0x2000-12, 0xc5)
+			ExpectS(cpu, 0x2000-12)
+			ExpectClock(cpu, 20)
+
+			cpu.step()
+			cpu.step()
+			ExpectPC(cpu, 0x1002)
+			ExpectClock(cpu, 22)
+
+			cpu.AssertIRQ()
+			cpu.step()
+			ExpectPC(cpu, 0xc200)
+			ExpectS(cpu, 0x2000-12)
+			ExpectCCR(cpu, "EI", "")
+		})
+
+		It("should not stack the state again on FIRQ", func() {
+			cpu.write(0x1000, 0x3c) // CWAI #$bf
+			cpu.write(0x1001, 0xbf)
+			cpu.step()
+			cpu.AssertFIRQ()
+			cpu.step()
+
+			ExpectPC(cpu, 0xc100)
+			ExpectS(cpu, 0x2000-12)
+			ExpectCCR(cpu, "EFI", "")
+
+			cpu.ReleaseFIRQ()
+			cpu.write(0xc100, 0x3b) // RTI
+			cpu.step()
+			ExpectPC(cpu, 0x1002)
+			ExpectA(cpu, 0x2f)
+			ExpectS(cpu, 0x2000)
+		})
+
+		It("should keep waiting while interrupts are masked", func() {
+			cpu.write(0x1000, 0x3c) // CWAI #$ff
+			cpu.write(0x1001, 0xff)
+			cpu.cc.setI()
+			cpu.step()
+			cpu.AssertIRQ()
+			cpu.step()
+
+			ExpectPC(cpu, 0x1002)
+			ExpectS(cpu, 0x2000-12)
+		})
+	})
+
+	Context("[SYNC]", func() {
+
+		It("should halt until an interrupt line is asserted", func() {
+			cpu.write(0x1000, 0x13) // SYNC
+			cpu.write(0x1001, 0x12) // NOP
+			cpu.step()
+			ExpectPC(cpu, 0x1001)
+			ExpectClock(cpu, 4)
+
+			cpu.step()
+			cpu.step()
+			ExpectPC(cpu, 0x1001)
+			ExpectClock(cpu, 6)
+
+			cpu.AssertIRQ()
+			cpu.step()
+			ExpectPC(cpu, 0xc200)
+			ExpectWord(cpu, 0x2000-2, 0x1001)
+		})
+
+		It("should resume execution when the interrupt is masked", func() {
+			cpu.write(0x1000, 0x13) // SYNC
+			cpu.write(0x1001, 0x12) // NOP
+			cpu.cc.setI()
+			cpu.step()
+			cpu.step()
+			ExpectPC(cpu, 0x1001)
+
+			cpu.AssertIRQ()
+			cpu.step()
+			ExpectPC(cpu, 0x1002)
+			ExpectS(cpu, 0x2000)
+		})
+	})
+})
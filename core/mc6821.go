@@ -0,0 +1,116 @@
+package core
+
+/* MC6821 PIA
+ *
+ * Registers in the order of the Thomson machines, the address being taken modulo
+ * 4:
+ *
+ *	0  port A: data register when CRA2 is set, data direction register otherwise
+ *	1  port B: data register when CRB2 is set, data direction register otherwise
+ *	2  control register A
+ *	3  control register B
+ *
+ * Only the ports are emulated: the control lines CA1, CA2, CB1 and CB2 are not
+ * connected, so the interrupt flags CRx6 and CRx7 always read as 0.
+ */
+
+// Control register bits
+const (
+	crData  = 0x04
+	crFlags = 0xc0
+)
+
+// MC6821 emulates the two ports of the MC6821 PIA as a bus device
+type MC6821 struct {
+	/// Called when port A is written, with the level of the pins: the pins
+	/// configured as inputs are pulled high
+	OutputA func(value uint8)
+	/// Called when port B is written, with the level of the pins
+	OutputB func(value uint8)
+
+	/// Data direction registers, 1 for the outputs
+	ddr [2]uint8
+	/// Output latches of the data registers
+	pdr [2]uint8
+	/// Control registers
+	cr [2]uint8
+	/// Level of the input pins
+	input [2]uint8
+}
+
+// NewMC6821 returns a MC6821 after a hardware reset
+func NewMC6821() *MC6821 {
+	p := &MC6821{input: [2]uint8{0xff, 0xff}}
+	p.Reset()
+	return p
+}
+
+// Reset is the hardware reset: the registers are cleared, the ports are
+// configured as inputs
+func (p *MC6821) Reset() {
+	p.ddr, p.pdr, p.cr = [2]uint8{}, [2]uint8{}, [2]uint8{}
+	p.port(0)
+	p.port(1)
+}
+
+// SetInputA sets the level of the port A pins
+func (p *MC6821) SetInputA(value uint8) {
+	p.input[0] = value
+}
+
+// SetInputB sets the level of the port B pins
+func (p *MC6821) SetInputB(value uint8) {
+	p.input[1] = value
+}
+
+/** Report the level of the pins of a port */
+func (p *MC6821) port(i int) {
+	output := p.OutputA
+	if i == 1 {
+		output = p.OutputB
+	}
+	if output != nil {
+		output(p.pdr[i]&p.ddr[i] | ^p.ddr[i])
+	}
+}
+
+func (p *MC6821) Read(offset uint16) uint8 {
+	i := int(offset & 1)
+	if offset&2 != 0 {
+		return p.cr[i]
+	}
+	if p.cr[i]&crData == 0 {
+		return p.ddr[i]
+	}
+	return p.pdr[i]&p.ddr[i] | p.input[i]&^p.ddr[i]
+}
+
+func (p *MC6821) Write(offset uint16, value uint8) {
+	i := int(offset & 1)
+	switch {
+	case offset&2 != 0:
+		p.cr[i] = value &^ crFlags
+	case p.cr[i]&crData == 0:
+		p.ddr[i] = value
+		p.port(i)
+	default:
+		p.pdr[i] = value
+		p.port(i)
+	}
+}
+
+// Snapshot saves the registers
+func (p *MC6821) Snapshot() MemoryState {
+	s := *p
+	return &s
+}
+
+// Restore gives back the registers. The callbacks are kept and are called with the
+// restored levels.
+func (p *MC6821) Restore(s MemoryState) {
+	saved := *s.(*MC6821)
+	saved.OutputA, saved.OutputB = p.OutputA, p.OutputB
+	*p = saved
+	p.port(0)
+	p.port(1)
+}
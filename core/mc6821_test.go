@@ -0,0 +1,48 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MC6821", func() {
+	var (
+		p       *MC6821
+		outputs []uint8
+	)
+
+	BeforeEach(func() {
+		outputs = nil
+		p = NewMC6821()
+		p.OutputB = func(value uint8) { outputs = append(outputs, value) }
+	})
+
+	It("should select the data direction register or the data register with CR2", func() {
+		p.Write(1, 0x0f)
+		Expect(p.Read(1)).To(BeEquivalentTo(0x0f))
+		p.Write(3, crData)
+		p.SetInputB(0xa5)
+		p.Write(1, 0x3c)
+
+		Expect(p.Read(1)).To(BeEquivalentTo(0xac))
+		Expect(p.Read(3)).To(BeEquivalentTo(crData))
+		Expect(outputs).To(Equal([]uint8{0xf0, 0xfc}))
+	})
+
+	It("should keep the interrupt flags of the control registers clear", func() {
+		p.Write(2, 0xff)
+		Expect(p.Read(2)).To(BeEquivalentTo(0x3f))
+	})
+
+	It("should restore the registers", func() {
+		p.Write(1, 0xff)
+		p.Write(3, crData)
+		p.Write(1, 0x12)
+		s := p.Snapshot()
+		p.Write(1, 0x34)
+		p.Restore(s)
+
+		Expect(p.Read(1)).To(BeEquivalentTo(0x12))
+		Expect(outputs[len(outputs)-1]).To(BeEquivalentTo(0x12))
+	})
+})
@@ -0,0 +1,541 @@
+package core
+
+/* MC6846 ROM - I/O - Timer
+ *
+ * Registers, the address being taken modulo 8:
+ *
+ *	0  composite status register (read only)
+ *	1  peripheral control register
+ *	2  data direction register
+ *	3  peripheral data register
+ *	4  peripheral data register, the accesses do not clear the CP1 and CP2 flags
+ *	5  timer control register
+ *	6  timer MSB: counter MSB when read, MSB buffer when written
+ *	7  timer LSB: counter LSB buffered by the MSB read when read, latches when
+ *	   written
+ *
+ * The timer counts down from the latches value to 0 and is reloaded on the next
+ * clock: a time-out happens every latches+1 clocks. The clock is the E clock of
+ * the CPU or the CTC input, optionally divided by 8. The operating modes are
+ * selected by TCR3-TCR5:
+ *
+ *	TCR5 TCR4 TCR3
+ *	 0    x    0   continuous, the output toggles at each time-out
+ *	 1    x    0   single-shot, the output is high until the first time-out
+ *	 x    0    0   the counter is initialized when the latches are written
+ *	 x    1    0   the writes to the latches do not initialize the counter
+ *	 0    0    1   frequency comparison, interrupt if the CTG period is shorter
+ *	               than the time-out
+ *	 1    0    1   frequency comparison, interrupt if the time-out comes first
+ *	 0    1    1   pulse width comparison, interrupt if the CTG low pulse is
+ *	               shorter than the time-out
+ *	 1    1    1   pulse width comparison, interrupt if the time-out comes first
+ *
+ * The counter is also initialized by a falling edge of the CTG input, which must
+ * be low for the continuous and single-shot modes to count.
+ */
+
+// Timer control register bits
+const (
+	tcrReset     = 0x01
+	tcrClockE    = 0x02
+	tcrPrescaler = 0x04
+	tcrCompare   = 0x08
+	tcrNoInit    = 0x10
+	tcrSingle    = 0x20
+	tcrIRQ       = 0x40
+	tcrOutput    = 0x80
+)
+
+// Peripheral control register bits
+const (
+	pcrCP1IRQ    = 0x01
+	pcrCP1Rising = 0x02
+	pcrLatch     = 0x04
+	pcrCP2IRQ    = 0x08
+	pcrCP2Rising = 0x10
+	pcrCP2Output = 0x20
+	pcrReset     = 0x80
+)
+
+// Composite status register bits
+const (
+	csrTimer = 0x01
+	csrCP1   = 0x02
+	csrCP2   = 0x04
+	csrIRQ   = 0x80
+)
+
+// MC6846 emulates the timer and the parallel port of the MC6846 as a bus device.
+// The ROM of the chip is mapped separately.
+//
+// The timer is advanced to the clock of the accesses: the cycle in cycle exact
+// mode, the value returned by Clock otherwise. Registered with CPU.AddDevice, the
+// timer is also ticked at its time-outs so that they raise their interrupt in
+// time, and follows the clock of the CPU when it is cleared or restored. Sync must
+// be called between the accesses otherwise.
+type MC6846 struct {
+	/// Clock of the CPU used to advance the timer on the register accesses outside
+	/// of the cycle exact mode, the timer is only advanced by Sync if nil
+	Clock func() uint64
+	/// Called when the IRQ output changes, true when asserted
+	IRQ func(asserted bool)
+	/// Called when the port is written, with the level of the pins: the pins
+	/// configured as inputs are pulled high
+	Output func(value uint8)
+	/// Called when the CTO timer output changes
+	TimerOutput func(level bool)
+	/// Called when the CP2 output changes
+	CP2Output func(level bool)
+	/// Called when the next time-out may come before the clock returned by Tick
+	Reschedule func()
+
+	/// Peripheral control register
+	pcr uint8
+	/// Data direction register, 1 for the outputs
+	ddr uint8
+	/// Output latch of the peripheral data register
+	pdr uint8
+	/// Timer control register
+	tcr uint8
+	/// Interrupt flags of the composite status register
+	flags uint8
+	/// Level of the input pins
+	input uint8
+	/// Inputs latched by the CP1 active edge
+	latched uint8
+	/// The inputs have been latched and not read yet
+	inputLatched bool
+	/// Level of CP1, CP2 and CTG inputs
+	cp1, cp2, gate bool
+	/// Level of the CP2 output
+	cp2Out bool
+
+	/// Timer latches
+	latches uint16
+	/// Timer counter
+	counter uint16
+	/// MSB buffer of the latches writes
+	msb uint8
+	/// LSB buffer of the counter reads
+	lsb uint8
+	/// Clocks counted by the prescaler
+	prescaler uint64
+	/// Clock up to which the timer has been advanced
+	clock uint64
+	/// Timer output before the output enable
+	output bool
+	/// The single-shot time-out has happened
+	fired bool
+	/// A comparison measurement is running
+	measuring bool
+	/// The time-out has happened during the measurement
+	timedOut bool
+	/// The status register has been read with the timer flag set
+	statusRead bool
+
+	/// Last level reported to IRQ and TimerOutput
+	irq, cto bool
+}
+
+// NewMC6846 returns a MC6846 after a hardware reset
+func NewMC6846() *MC6846 {
+	t := &MC6846{input: 0xff}
+	t.Reset()
+	return t
+}
+
+// Reset is the hardware reset: the timer is held in reset with its latches set to
+// $FFFF, the port is configured as inputs and the interrupt flags are cleared. The
+// timer restarts from the clock of the CPU.
+func (t *MC6846) Reset() {
+	t.align()
+	t.pcr, t.ddr, t.pdr, t.flags = 0, 0, 0, 0
+	t.inputLatched = false
+	t.cp2Out = false
+	t.tcr = tcrReset
+	t.latches, t.counter = 0xffff, 0xffff
+	t.msb, t.lsb = 0xff, 0xff
+	t.prescaler = 0
+	t.output, t.fired, t.measuring, t.timedOut, t.statusRead = false, false, false, false, false
+	t.update()
+	t.port()
+	t.reschedule()
+}
+
+// Sync advances the timer to the given clock. A clock in the past is ignored.
+func (t *MC6846) Sync(clock uint64) {
+	if clock <= t.clock {
+		return
+	}
+	cycles := clock - t.clock
+	t.clock = clock
+	if t.tcr&tcrClockE != 0 {
+		t.count(cycles)
+		t.update()
+	}
+}
+
+// SetClock moves the time base of the timer to the given clock without counting
+// the cycles in between
+func (t *MC6846) SetClock(clock uint64) {
+	t.clock = clock
+	t.reschedule()
+}
+
+/** Move the time base of the timer to the clock of the CPU */
+func (t *MC6846) align() {
+	if t.Clock != nil {
+		t.clock = t.Clock()
+	}
+}
+
+// Tick advances the timer to the clock of the CPU and returns the clock of the
+// next time-out
+func (t *MC6846) Tick(clock uint64) uint64 {
+	t.Sync(clock)
+	if t.tcr&tcrReset != 0 || t.tcr&tcrClockE == 0 || t.tcr&tcrCompare == 0 && t.gate {
+		return never
+	}
+	cycles := uint64(t.counter) + 1
+	if t.tcr&tcrPrescaler != 0 {
+		cycles = cycles*8 - t.prescaler
+	}
+	return t.clock + cycles
+}
+
+/** Ask the CPU to tick the timer again */
+func (t *MC6846) reschedule() {
+	if t.Reschedule != nil {
+		t.Reschedule()
+	}
+}
+
+// ClockInput counts a pulse of the CTC input, used as clock when TCR1 is clear
+func (t *MC6846) ClockInput() {
+	if t.tcr&tcrClockE == 0 {
+		t.count(1)
+		t.update()
+	}
+}
+
+// SetInput sets the level of the port pins
+func (t *MC6846) SetInput(value uint8) {
+	t.input = value
+}
+
+// SetCP1 sets the level of the CP1 input. Its active edge sets the CP1 flag and
+// latches the inputs when PCR2 is set.
+func (t *MC6846) SetCP1(level bool) {
+	previous := t.cp1
+	t.cp1 = level
+	if previous == level || level != (t.pcr&pcrCP1Rising != 0) || t.pcr&pcrReset != 0 {
+		return
+	}
+	t.flags |= csrCP1
+	if t.pcr&pcrLatch != 0 && !t.inputLatched {
+		t.latched = t.input
+		t.inputLatched = true
+	}
+	if t.pcr&(pcrCP2Output|pcrCP2Rising) == pcrCP2Output {
+		t.setCP2Output(true) // handshake: CP2 goes high on CP1 active edge
+	}
+	t.update()
+}
+
+// SetCP2 sets the level of the CP2 line when it is configured as an input. Its
+// active edge sets the CP2 flag.
+func (t *MC6846) SetCP2(level bool) {
+	previous := t.cp2
+	t.cp2 = level
+	if t.pcr&pcrCP2Output != 0 || previous == level || level != (t.pcr&pcrCP2Rising != 0) || t.pcr&pcrReset != 0 {
+		return
+	}
+	t.flags |= csrCP2
+	t.update()
+}
+
+// SetGate sets the level of the CTG input
+func (t *MC6846) SetGate(level bool) {
+	t.now()
+	defer t.reschedule()
+	previous := t.gate
+	t.gate = level
+	if previous == level || t.tcr&tcrReset != 0 {
+		return
+	}
+	if t.tcr&tcrCompare == 0 {
+		if !level {
+			t.initialize()
+		}
+		t.update()
+		return
+	}
+	pulse := t.tcr&tcrNoInit != 0
+	if t.measuring && (level == pulse) {
+		// end of the CTG period or of the low pulse
+		if !t.timedOut && t.tcr&tcrSingle == 0 {
+			t.flags |= csrTimer
+		}
+		t.measuring = false
+	}
+	if !level {
+		t.initialize()
+		t.measuring = true
+		t.timedOut = false
+	}
+	t.update()
+}
+
+/** Advance the timer to the clock of the CPU */
+func (t *MC6846) now() {
+	if t.Clock != nil {
+		t.Sync(t.Clock())
+	}
+}
+
+/** Count clock pulses through the prescaler */
+func (t *MC6846) count(pulses uint64) {
+	if t.tcr&tcrReset != 0 {
+		return
+	}
+	if t.tcr&tcrCompare == 0 && t.gate {
+		return
+	}
+	if t.tcr&tcrPrescaler != 0 {
+		pulses += t.prescaler
+		t.prescaler = pulses % 8
+		pulses /= 8
+	}
+	if pulses <= uint64(t.counter) {
+		t.counter -= uint16(pulses)
+		return
+	}
+	pulses -= uint64(t.counter) + 1
+	period := uint64(t.latches) + 1
+	t.counter = t.latches - uint16(pulses%period)
+	t.timeout(1 + pulses/period)
+}
+
+/** Handle time-outs */
+func (t *MC6846) timeout(n uint64) {
+	switch {
+	case t.tcr&tcrCompare != 0:
+		if t.measuring && !t.timedOut {
+			t.timedOut = true
+			if t.tcr&tcrSingle != 0 {
+				t.flags |= csrTimer
+			}
+		}
+	case t.tcr&tcrSingle != 0:
+		if !t.fired {
+			t.fired = true
+			t.output = false
+			t.flags |= csrTimer
+		}
+	default:
+		if n%2 == 1 {
+			t.output = !t.output
+		}
+		t.flags |= csrTimer
+	}
+}
+
+/** Load the counter from the latches */
+func (t *MC6846) initialize() {
+	t.counter = t.latches
+	t.prescaler = 0
+	t.fired = false
+	t.output = t.tcr&tcrSingle != 0 && t.tcr&tcrCompare == 0
+}
+
+/** Report the changes of the IRQ and CTO outputs */
+func (t *MC6846) update() {
+	irq := t.flags&csrTimer != 0 && t.tcr&tcrIRQ != 0 ||
+		t.flags&csrCP1 != 0 && t.pcr&pcrCP1IRQ != 0 ||
+		t.flags&csrCP2 != 0 && t.pcr&pcrCP2IRQ != 0 && t.pcr&pcrCP2Output == 0
+	if irq != t.irq {
+		t.irq = irq
+		if t.IRQ != nil {
+			t.IRQ(irq)
+		}
+	}
+	cto := t.output && t.tcr&tcrOutput != 0
+	if cto != t.cto {
+		t.cto = cto
+		if t.TimerOutput != nil {
+			t.TimerOutput(cto)
+		}
+	}
+}
+
+/** Report the level of the port pins */
+func (t *MC6846) port() {
+	if t.Output != nil {
+		t.Output(t.pdr&t.ddr | ^t.ddr)
+	}
+}
+
+/** Drive the CP2 output */
+func (t *MC6846) setCP2Output(level bool) {
+	if level != t.cp2Out {
+		t.cp2Out = level
+		if t.CP2Output != nil {
+			t.CP2Output(level)
+		}
+	}
+}
+
+/** Read the port, the inputs latched by CP1 replacing the pins */
+func (t *MC6846) readPort() uint8 {
+	input := t.input
+	if t.inputLatched {
+		input = t.latched
+	}
+	return t.pdr&t.ddr | input&^t.ddr
+}
+
+func (t *MC6846) Read(offset uint16) uint8 {
+	t.now()
+	return t.read(offset)
+}
+
+func (t *MC6846) Write(offset uint16, value uint8) {
+	t.now()
+	t.write(offset, value)
+}
+
+// ReadCycle reads a register in cycle exact mode
+func (t *MC6846) ReadCycle(cycle uint64, offset uint16) uint8 {
+	t.Sync(cycle)
+	return t.read(offset)
+}
+
+// WriteCycle writes a register in cycle exact mode
+func (t *MC6846) WriteCycle(cycle uint64, offset uint16, value uint8) {
+	t.Sync(cycle)
+	t.write(offset, value)
+}
+
+/** Read a register */
+func (t *MC6846) read(offset uint16) uint8 {
+	switch offset & 7 {
+	case 0:
+		status := t.flags
+		if t.irq {
+			status |= csrIRQ
+		}
+		t.statusRead = t.flags&csrTimer != 0
+		return status
+	case 1:
+		return t.pcr
+	case 2:
+		return t.ddr
+	case 3:
+		value := t.readPort()
+		t.inputLatched = false
+		t.flags &^= csrCP1
+		if t.pcr&pcrCP2Output == 0 {
+			t.flags &^= csrCP2
+		} else if t.pcr&pcrCP2Rising == 0 {
+			t.setCP2Output(false) // handshake: CP2 goes low when the port is read
+		}
+		t.update()
+		return value
+	case 4:
+		return t.readPort()
+	case 5:
+		return t.tcr
+	case 6:
+		t.lsb = uint8(t.counter)
+		t.clearTimerFlag()
+		return uint8(t.counter >> 8)
+	default:
+		t.clearTimerFlag()
+		return t.lsb
+	}
+}
+
+/** The timer flag is cleared by a read of the status register followed by a read
+ * of the counter */
+func (t *MC6846) clearTimerFlag() {
+	if t.statusRead {
+		t.statusRead = false
+		t.flags &^= csrTimer
+		t.update()
+	}
+}
+
+/** Write a register */
+func (t *MC6846) write(offset uint16, value uint8) {
+	defer t.reschedule()
+	switch offset & 7 {
+	case 0:
+	case 1:
+		t.pcr = value
+		if value&pcrReset != 0 {
+			t.ddr, t.pdr = 0, 0
+			t.flags &^= csrCP1 | csrCP2
+			t.inputLatched = false
+			t.port()
+		}
+		if value&(pcrCP2Output|pcrCP2Rising) == pcrCP2Output|pcrCP2Rising {
+			t.setCP2Output(value&pcrCP2IRQ != 0) // CP2 output driven by PCR3
+		}
+		t.update()
+	case 2:
+		t.ddr = value
+		t.port()
+	case 3, 4:
+		t.pdr = value
+		if offset&7 == 3 && t.pcr&pcrCP2Output == 0 {
+			t.flags &^= csrCP2
+			t.update()
+		}
+		t.port()
+	case 5:
+		previous := t.tcr
+		t.tcr = value
+		if value&tcrReset != 0 {
+			t.counter = t.latches
+			t.prescaler = 0
+			t.flags &^= csrTimer
+			t.output, t.fired, t.measuring, t.timedOut = false, false, false, false
+		} else if previous&tcrReset != 0 {
+			t.initialize()
+		}
+		t.update()
+	case 6:
+		t.msb = value
+	default:
+		t.latches = uint16(t.msb)<<8 | uint16(value)
+		if t.tcr&tcrReset != 0 {
+			t.counter = t.latches
+		} else if t.tcr&(tcrCompare|tcrNoInit) == 0 {
+			t.initialize()
+			t.flags &^= csrTimer
+		}
+		t.update()
+	}
+}
+
+// Snapshot saves the registers and the state of the timer
+func (t *MC6846) Snapshot() MemoryState {
+	s := *t
+	return &s
+}
+
+// Restore gives back the registers and the state of the timer. The callbacks are
+// kept and are called with the restored levels. The timer resumes counting from
+// the clock of the CPU: the cycles elapsed since the snapshot are not counted.
+func (t *MC6846) Restore(s MemoryState) {
+	saved := *s.(*MC6846)
+	saved.Clock, saved.IRQ, saved.Output, saved.TimerOutput, saved.CP2Output, saved.Reschedule = t.Clock, t.IRQ, t.Output, t.TimerOutput, t.CP2Output, t.Reschedule
+	saved.irq, saved.cto = t.irq, t.cto
+	saved.clock = t.clock
+	*t = saved
+	t.align()
+	t.update()
+	t.port()
+	t.reschedule()
+}
@@ -0,0 +1,445 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MC6846", func() {
+	var (
+		t     *MC6846
+		clock uint64
+		irq   bool
+	)
+
+	BeforeEach(func() {
+		clock = 0
+		irq = false
+		t = NewMC6846()
+		t.Clock = func() uint64 { return clock }
+		t.IRQ = func(asserted bool) { irq = asserted }
+	})
+
+	// Start the timer clocked by E with the given latches and control register
+	start := func(latches uint16, tcr uint8) {
+		t.Write(5, tcrReset)
+		t.Write(6, uint8(latches>>8))
+		t.Write(7, uint8(latches))
+		t.Write(5, tcr)
+	}
+
+	It("should be held in reset after a hardware reset", func() {
+		Expect(t.Read(5)).To(BeEquivalentTo(tcrReset))
+		Expect(t.Read(0)).To(BeEquivalentTo(0x00))
+		Expect(t.Read(6)).To(BeEquivalentTo(0xff))
+		Expect(t.Read(7)).To(BeEquivalentTo(0xff))
+		clock = 1000
+		Expect(t.Read(6)).To(BeEquivalentTo(0xff))
+	})
+
+	It("should time out every latches+1 clocks in continuous mode", func() {
+		start(99, tcrClockE|tcrIRQ)
+		clock = 99
+		t.Sync(clock)
+		Expect(t.Read(0)).To(BeEquivalentTo(0x00))
+		Expect(irq).To(BeFalse())
+
+		clock = 100
+		t.Sync(clock)
+		Expect(t.Read(0)).To(BeEquivalentTo(csrIRQ | csrTimer))
+		Expect(irq).To(BeTrue())
+		Expect(t.Read(6)).To(BeEquivalentTo(0x00))
+		Expect(t.Read(7)).To(BeEquivalentTo(99))
+		Expect(irq).To(BeFalse())
+
+		clock = 250
+		Expect(t.Read(6)).To(BeEquivalentTo(0x00))
+		Expect(t.Read(7)).To(BeEquivalentTo(49))
+	})
+
+	It("should only clear the timer flag when the counter is read after the status", func() {
+		start(9, tcrClockE)
+		clock = 10
+		t.Read(6)
+		Expect(t.Read(0) & csrTimer).NotTo(BeZero())
+		t.Read(7)
+		Expect(t.Read(0) & csrTimer).To(BeZero())
+	})
+
+	It("should divide the clock by 8 with the prescaler", func() {
+		start(9, tcrClockE|tcrPrescaler)
+		clock = 79
+		Expect(t.Read(0) & csrTimer).To(BeZero())
+		clock = 80
+		Expect(t.Read(0) & csrTimer).NotTo(BeZero())
+	})
+
+	It("should count the CTC pulses when the E clock is not selected", func() {
+		start(1, 0)
+		clock = 1000
+		t.ClockInput()
+		Expect(t.Read(0) & csrTimer).To(BeZero())
+		t.ClockInput()
+		Expect(t.Read(0) & csrTimer).NotTo(BeZero())
+	})
+
+	It("should initialize the counter when the latches are written unless TCR4 is set", func() {
+		start(999, tcrClockE)
+		clock = 500
+		t.Write(6, 0x00)
+		t.Write(7, 0x10)
+		Expect(t.Read(6)).To(BeEquivalentTo(0x00))
+		Expect(t.Read(7)).To(BeEquivalentTo(0x10))
+
+		t.Write(5, tcrClockE|tcrNoInit)
+		t.Write(6, 0x10)
+		t.Write(7, 0x00)
+		clock = 501
+		Expect(t.Read(6)).To(BeEquivalentTo(0x00))
+		Expect(t.Read(7)).To(BeEquivalentTo(0x0f))
+	})
+
+	It("should toggle the timer output at each time-out in continuous mode", func() {
+		levels := []bool{}
+		t.TimerOutput = func(level bool) { levels = append(levels, level) }
+		start(9, tcrClockE|tcrOutput)
+		for clock = 10; clock <= 30; clock += 10 {
+			t.Sync(clock)
+		}
+
+		Expect(levels).To(Equal([]bool{true, false, true}))
+	})
+
+	It("should time out once in single-shot mode", func() {
+		levels := []bool{}
+		t.TimerOutput = func(level bool) { levels = append(levels, level) }
+		start(9, tcrClockE|tcrSingle|tcrOutput)
+		clock = 10
+		t.Read(0)
+		t.Read(6)
+		clock = 30
+		t.Sync(clock)
+
+		Expect(levels).To(Equal([]bool{true, false}))
+		Expect(t.Read(0) & csrTimer).To(BeZero())
+	})
+
+	It("should not count while the gate is high", func() {
+		start(9, tcrClockE)
+		t.SetGate(true)
+		clock = 100
+		Expect(t.Read(0) & csrTimer).To(BeZero())
+		t.SetGate(false)
+		clock = 110
+		Expect(t.Read(0) & csrTimer).NotTo(BeZero())
+	})
+
+	It("should compare the gate period to the time-out", func() {
+		start(99, tcrClockE|tcrCompare)
+		t.SetGate(true)
+		t.SetGate(false)
+		clock = 150
+		t.SetGate(true)
+		t.SetGate(false)
+		Expect(t.Read(0) & csrTimer).To(BeZero()) // period longer than the time-out
+
+		clock = 200
+		t.SetGate(true)
+		t.SetGate(false)
+		Expect(t.Read(0) & csrTimer).NotTo(BeZero())
+	})
+
+	It("should compare the gate pulse width to the time-out", func() {
+		start(99, tcrClockE|tcrCompare|tcrNoInit|tcrSingle)
+		t.SetGate(true)
+		t.SetGate(false)
+		clock = 50
+		t.SetGate(true)
+		Expect(t.Read(0) & csrTimer).To(BeZero()) // pulse shorter than the time-out
+
+		t.SetGate(false)
+		clock = 200
+		t.Sync(clock)
+		Expect(t.Read(0) & csrTimer).NotTo(BeZero())
+	})
+
+	It("should read and write the port according to the data direction register", func() {
+		outputs := []uint8{}
+		t.Output = func(value uint8) { outputs = append(outputs, value) }
+		t.SetInput(0xa5)
+		t.Write(2, 0x0f)
+		t.Write(3, 0x3c)
+
+		Expect(t.Read(2)).To(BeEquivalentTo(0x0f))
+		Expect(t.Read(3)).To(BeEquivalentTo(0xac))
+		Expect(outputs).To(Equal([]uint8{0xf0, 0xfc}))
+	})
+
+	It("should latch the inputs and raise an interrupt on the CP1 active edge", func() {
+		t.Write(1, pcrCP1IRQ|pcrCP1Rising|pcrLatch)
+		t.SetInput(0x12)
+		t.SetCP1(true)
+		t.SetInput(0x34)
+
+		Expect(irq).To(BeTrue())
+		Expect(t.Read(0)).To(BeEquivalentTo(csrIRQ | csrCP1))
+		Expect(t.Read(4)).To(BeEquivalentTo(0x12))
+		Expect(t.Read(0)).To(BeEquivalentTo(csrIRQ | csrCP1))
+		Expect(t.Read(3)).To(BeEquivalentTo(0x12))
+		Expect(irq).To(BeFalse())
+		Expect(t.Read(3)).To(BeEquivalentTo(0x34))
+
+		t.SetCP1(false)
+		Expect(t.Read(0)).To(BeEquivalentTo(0x00))
+	})
+
+	It("should detect the CP2 active edge when CP2 is an input", func() {
+		t.Write(1, pcrCP2IRQ)
+		t.SetCP2(true)
+		Expect(irq).To(BeFalse())
+		t.SetCP2(false)
+		Expect(irq).To(BeTrue())
+		t.Write(3, 0x00)
+		Expect(irq).To(BeFalse())
+	})
+
+	It("should drive CP2 from PCR3 when CP2 is a manual output", func() {
+		levels := []bool{}
+		t.CP2Output = func(level bool) { levels = append(levels, level) }
+		t.Write(1, pcrCP2Output|pcrCP2Rising|pcrCP2IRQ)
+		t.Write(1, pcrCP2Output|pcrCP2Rising)
+
+		Expect(levels).To(Equal([]bool{true, false}))
+	})
+
+	It("should reset the port when PCR7 is set", func() {
+		t.Write(1, pcrCP1Rising)
+		t.Write(2, 0xff)
+		t.Write(3, 0x55)
+		t.SetCP1(true)
+		t.Write(1, pcrReset)
+
+		Expect(t.Read(2)).To(BeEquivalentTo(0x00))
+		Expect(t.Read(0)).To(BeEquivalentTo(0x00))
+	})
+
+	It("should advance the timer to the cycle of the accesses in cycle exact mode", func() {
+		t.Clock = nil
+		t.WriteCycle(0, 5, tcrReset)
+		t.WriteCycle(1, 6, 0x00)
+		t.WriteCycle(2, 7, 0x63)
+		t.WriteCycle(3, 5, tcrClockE)
+
+		Expect(t.ReadCycle(53, 6)).To(BeEquivalentTo(0x00))
+		Expect(t.ReadCycle(54, 7)).To(BeEquivalentTo(49))
+	})
+
+	It("should restore the state of the timer", func() {
+		start(99, tcrClockE|tcrIRQ)
+		clock = 50
+		t.Sync(clock)
+		s := t.Snapshot()
+		clock = 150
+		t.Sync(clock)
+		Expect(irq).To(BeTrue())
+
+		t.Restore(s)
+		Expect(irq).To(BeFalse())
+		Expect(t.Read(6)).To(BeEquivalentTo(0x00))
+		Expect(t.Read(7)).To(BeEquivalentTo(49))
+	})
+
+	It("should return the clock of the next time-out when ticked", func() {
+		rescheduled := 0
+		t.Reschedule = func() { rescheduled++ }
+		Expect(t.Tick(0)).To(Equal(never))
+
+		start(99, tcrClockE|tcrIRQ)
+		Expect(rescheduled).To(Equal(4))
+		Expect(t.Tick(40)).To(BeEquivalentTo(100))
+		Expect(t.Tick(100)).To(BeEquivalentTo(200))
+		Expect(irq).To(BeTrue())
+
+		t.Write(5, tcrClockE|tcrPrescaler)
+		Expect(t.Tick(203)).To(BeEquivalentTo(900)) // 12 pulses counted, 7 clocks in the prescaler
+		t.SetGate(true)
+		Expect(rescheduled).To(Equal(6))
+		Expect(t.Tick(210)).To(Equal(never))
+	})
+
+	It("should drive the periodic interrupt of the TO7/70", func() {
+		// Monitor starting the timer and counting the interrupts at $6000
+		//
+		//	F000: LDS #$8000
+		//	F004: LDA #$01
+		//	F006: STA $E7C5    timer in reset
+		//	F009: LDD #$03E7   1000 cycles
+		//	F00C: STD $E7C6
+		//	F00F: LDA #$42     E clock, interrupt enabled
+		//	F011: STA $E7C5
+		//	F014: ANDCC #$EF
+		//	F016: BRA $F016
+		//
+		//	F018: INC $6000    IRQ handler
+		//	F01B: LDA $E7C0
+		//	F01E: LDA $E7C6
+		//	F021: RTI
+		monitor := make([]uint8, 0x1800)
+		copy(monitor[0x0800:], []uint8{
+			0x10, 0xce, 0x80, 0x00,
+			0x86, 0x01,
+			0xb7, 0xe7, 0xc5,
+			0xcc, 0x03, 0xe7,
+			0xfd, 0xe7, 0xc6,
+			0x86, 0x42,
+			0xb7, 0xe7, 0xc5,
+			0x1c, 0xef,
+			0x20, 0xfe,
+			0x7c, 0x60, 0x00,
+			0xb6, 0xe7, 0xc0,
+			0xb6, 0xe7, 0xc6,
+			0x3b,
+		})
+		monitor[0x17f8], monitor[0x17f9] = 0xf0, 0x18 // IRQ vector
+		monitor[0x17fe], monitor[0x17ff] = 0xf0, 0x00 // reset vector
+		m, err := NewTO770(Profile{RAMSize: RAM16K, Monitor: monitor})
+		Expect(err).NotTo(HaveOccurred())
+		cpu := NewCPU(m.Bus, MC6809)
+		m.Connect(cpu)
+		cpu.PowerOn()
+		_, err = m.RunCycles(10500)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Bus.Read(0x6000)).To(BeEquivalentTo(10))
+
+		// the CPU ticks the timer without the wrapper
+		_, err = cpu.RunCycles(2000)
+		Expect(err).NotTo(HaveOccurred())
+		for cpu.Clock() < 14500 {
+			_, err = cpu.Step()
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(m.Bus.Read(0x6000)).To(BeEquivalentTo(14))
+	})
+
+	It("should raise the periodic interrupt when the registers are not read", func() {
+		// Same timer set-up with a handler which only counts the interrupts: the
+		// IRQ stays asserted so the handler runs again after each RTI
+		//
+		//	F018: INC $6000
+		//	F01B: RTI
+		monitor := make([]uint8, 0x1800)
+		copy(monitor[0x0800:], []uint8{
+			0x10, 0xce, 0x80, 0x00,
+			0x86, 0x01,
+			0xb7, 0xe7, 0xc5,
+			0xcc, 0x03, 0xe7,
+			0xfd, 0xe7, 0xc6,
+			0x86, 0x42,
+			0xb7, 0xe7, 0xc5,
+			0x1c, 0xef,
+			0x20, 0xfe,
+			0x7c, 0x60, 0x00,
+			0x3b,
+		})
+		monitor[0x17f8], monitor[0x17f9] = 0xf0, 0x18
+		monitor[0x17fe], monitor[0x17ff] = 0xf0, 0x00
+		m, _ := NewTO770(Profile{RAMSize: RAM16K, Monitor: monitor})
+		cpu := NewCPU(m.Bus, MC6809)
+		m.Connect(cpu)
+		cpu.PowerOn()
+		_, err := cpu.RunCycles(1000)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Bus.Read(0x6000)).To(BeEquivalentTo(0))
+
+		_, err = cpu.RunCycles(200)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Bus.Read(0x6000)).NotTo(BeZero())
+	})
+
+	// TO7/70 running the periodic interrupt handler reading the registers, the
+	// interrupts being counted at $6000
+	periodic := func() (*TO770, *CPU) {
+		monitor := make([]uint8, 0x1800)
+		copy(monitor[0x0800:], []uint8{
+			0x10, 0xce, 0x80, 0x00, // LDS #$8000
+			0x86, 0x01, // LDA #$01
+			0xb7, 0xe7, 0xc5, // STA $E7C5
+			0xcc, 0x03, 0xe7, // LDD #$03E7
+			0xfd, 0xe7, 0xc6, // STD $E7C6
+			0x86, 0x42, // LDA #$42
+			0xb7, 0xe7, 0xc5, // STA $E7C5
+			0x1c, 0xef, // ANDCC #$EF
+			0x20, 0xfe, // BRA $F016
+			0x7c, 0x60, 0x00, // INC $6000
+			0xb6, 0xe7, 0xc0, // LDA $E7C0
+			0xb6, 0xe7, 0xc6, // LDA $E7C6
+			0x3b, // RTI
+		})
+		monitor[0x17f8], monitor[0x17f9] = 0xf0, 0x18
+		monitor[0x17fe], monitor[0x17ff] = 0xf0, 0x00
+		m, err := NewTO770(Profile{RAMSize: RAM16K, Monitor: monitor})
+		Expect(err).NotTo(HaveOccurred())
+		cpu := NewCPU(m.Bus, MC6809)
+		m.Connect(cpu)
+		cpu.PowerOn()
+		return m, cpu
+	}
+
+	It("should count again after the CPU is powered on", func() {
+		m, cpu := periodic()
+		_, err := cpu.RunCycles(10500)
+		Expect(err).NotTo(HaveOccurred())
+
+		cpu.PowerOn()
+		m.Bus.Write(0x6000, 0)
+		_, err = cpu.RunCycles(10500)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Bus.Read(0x6000)).To(BeEquivalentTo(10))
+	})
+
+	It("should not count the cycles elapsed before a restore", func() {
+		m, cpu := periodic()
+		_, err := cpu.RunCycles(10500)
+		Expect(err).NotTo(HaveOccurred())
+		s := m.Bus.Snapshot()
+		_, err = cpu.RunCycles(5000)
+		Expect(err).NotTo(HaveOccurred())
+
+		m.Bus.Restore(s)
+		Expect(m.System.Read(0) & csrTimer).To(BeZero())
+		_, err = cpu.RunCycles(1000)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Bus.Read(0x6000)).To(BeEquivalentTo(11))
+	})
+
+	It("should follow the clock restored with the CPU state", func() {
+		m, cpu := periodic()
+		_, err := cpu.RunCycles(10500)
+		Expect(err).NotTo(HaveOccurred())
+		s, state := m.Bus.Snapshot(), cpu.State()
+		_, err = cpu.RunCycles(5000)
+		Expect(err).NotTo(HaveOccurred())
+
+		m.Bus.Restore(s)
+		cpu.SetState(state)
+		_, err = cpu.RunCycles(5000)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Bus.Read(0x6000)).To(BeEquivalentTo(15))
+	})
+
+	It("should need a connected CPU to run the TO7/70", func() {
+		m, _ := NewTO770(DefaultProfile)
+		_, err := m.RunCycles(100)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should select the video plane with bit 0 of the port", func() {
+		m, _ := NewTO770(DefaultProfile)
+		m.Bus.Write(0xe7c2, 0x01)
+		m.Bus.Write(0xe7c3, 0x00)
+		Expect(m.Video.Active()).To(Equal(ColorPlane))
+		m.Bus.Write(0xe7c3, 0x01)
+		Expect(m.Video.Active()).To(Equal(FormPlane))
+	})
+})
@@ -1,8 +1,8 @@
 package core
 
 import (
-	"fmt"
-	"strings"
+	"math/rand"
+	"os"
 )
 
 type Memory interface {
@@ -14,20 +14,43 @@ type Memory interface {
 	Dump()
 }
 
+// CycleMemory is implemented by memories which need to know when each access
+// happens. In cycle exact mode, the CPU issues every bus cycle through ReadCycle
+// and WriteCycle with the clock value of the cycle, dead cycles included.
+type CycleMemory interface {
+	Memory
+	ReadCycle(cycle uint64, address uint16) uint8
+	WriteCycle(cycle uint64, address uint16, value uint8)
+}
+
 type memoryImpl struct {
-	RAM []uint8
+	pages *Pages
 }
 
 func NewRam() Memory {
-	return &memoryImpl{RAM: make([]uint8, 0x10000)}
+	return &memoryImpl{pages: NewPages(0x10000)}
+}
+
+// NewRamFilled returns a 64K RAM holding the power-on content of a fill strategy
+func NewRamFilled(fill PowerOnFill, seed int64) Memory {
+	data := make([]uint8, 0x10000)
+	fill.Fill(data, rand.New(rand.NewSource(seed)))
+	mem := &memoryImpl{pages: NewPages(len(data))}
+	mem.pages.Load(0, data)
+	return mem
 }
 
 func (mem *memoryImpl) Read(address uint16) uint8 {
-	return (*mem).RAM[address]
+	return mem.pages.Get(int(address))
 }
 
 func (mem *memoryImpl) Write(address uint16, value uint8) {
-	(*mem).RAM[address] = value
+	mem.pages.Set(int(address), value)
+}
+
+// Changed returns the stamp of the last change of the page containing an address
+func (mem *memoryImpl) Changed(address uint16) uint64 {
+	return mem.pages.Changed(address)
 }
 
 func (mem *memoryImpl) Readw(address uint16) uint16 {
@@ -42,17 +65,42 @@ func (mem *memoryImpl) Writew(address uint16, value uint16) {
 }
 
 func (mem *memoryImpl) Dump() {
-	var sb strings.Builder
-	for a := 0; a < 0x10000; a += 16 {
-		sb.WriteString(fmt.Sprintf("%04x | ", a))
-		hexa := []string{}
-		for _, x := range mem.RAM[a : a+16] {
-			hexa = append(hexa, fmt.Sprintf("%02x", x))
+	DumpRange(os.Stdout, mem, 0x0000, 0xffff)
+}
+
+// PowerOnFill is the strategy filling the RAM at power on. A RAM which is not
+// cleared by the software shows this content, which helps to find the reads of
+// uninitialised variables.
+type PowerOnFill int
+
+const (
+	// ZeroFill clears the RAM
+	ZeroFill PowerOnFill = 0
+	// PatternFill alternates blocks of $00 and $FF bytes, the way the DRAM of the
+	// TO7/70 powers up
+	PatternFill PowerOnFill = 1
+	// RandomFill fills the RAM with pseudo random bytes from a seed, the same seed
+	// giving the same content
+	RandomFill PowerOnFill = 2
+)
+
+/** Size of the blocks of PatternFill */
+const fillPatternBlock = 64
+
+// Fill writes the power-on content to a RAM. The random source is only used by
+// RandomFill.
+func (f PowerOnFill) Fill(data []uint8, rnd *rand.Rand) {
+	for i := range data {
+		switch f {
+		case PatternFill:
+			data[i] = 0x00
+			if i/fillPatternBlock%2 == 1 {
+				data[i] = 0xff
+			}
+		case RandomFill:
+			data[i] = uint8(rnd.Intn(0x100))
+		default:
+			data[i] = 0x00
 		}
-		sb.WriteString(strings.Join(hexa[0:8], " "))
-		sb.WriteString("  ")
-		sb.WriteString(strings.Join(hexa[8:16], " "))
-		sb.WriteString("\n")
 	}
-	fmt.Printf(sb.String())
 }
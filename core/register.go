@@ -1,6 +1,6 @@
 package core
 
-import log "github.com/sirupsen/logrus"
+import "fmt"
 
 type r8 struct {
 	n string
@@ -15,7 +15,7 @@ type r16 struct {
 type register interface {
 	name() string
 	size() int
-	set(value interface{})
+	set(value int)
 	get() int
 	uint8() uint8
 	int8() int8
@@ -33,25 +33,8 @@ func (r r8) size() int {
 	return 8
 }
 
-func (r r8) checkAndConvert(value interface{}) int {
-	v1, ok := value.(int)
-	if ok {
-		return int(v1 & 0xff)
-	}
-	v2, ok := value.(uint8)
-	if ok {
-		return int(v2 & 0xff)
-	}
-	v3, ok := value.(int8)
-	if ok {
-		return int(v3) & 0xff
-	}
-	log.Fatalf("Type conversion error: %T is not an integer type", value)
-	return 0
-}
-
-func (r r8) set(value interface{}) {
-	*r.r = r.checkAndConvert(value)
+func (r r8) set(value int) {
+	*r.r = value & 0xff
 }
 
 func (r r8) get() int {
@@ -84,33 +67,8 @@ func (r r16) size() int {
 	return 16
 }
 
-func (r r16) checkAndConvert(value interface{}) int {
-	v1, ok := value.(int)
-	if ok {
-		return v1 & 0xffff
-	}
-	v2, ok := value.(uint8)
-	if ok {
-		return int(v2) & 0xffff
-	}
-	v3, ok := value.(int8)
-	if ok {
-		return int(v3) & 0xffff
-	}
-	v4, ok := value.(uint16)
-	if ok {
-		return int(v4) & 0xffff
-	}
-	v5, ok := value.(int16)
-	if ok {
-		return int(v5) & 0xffff
-	}
-	log.Fatalf("Type conversion error: %T is not an integer type", value)
-	return 0
-}
-
-func (r r16) set(value interface{}) {
-	*r.r = r.checkAndConvert(value)
+func (r r16) set(value int) {
+	*r.r = value & 0xffff
 }
 
 func (r r16) get() int {
@@ -142,3 +100,154 @@ func (r r16) dec() r16 {
 	*r.r--
 	return r
 }
+
+// Registers is a copy of the CPU register file. It is a plain value: assigning it
+// is enough to take a snapshot. The clock, the wait of CWAI and SYNC and the
+// interrupt lines are not registers, they are saved with State.
+type Registers struct {
+	A  uint8
+	B  uint8
+	X  uint16
+	Y  uint16
+	U  uint16
+	S  uint16
+	DP uint8
+	CC uint8
+	PC uint16
+	// HD6309 registers
+	E  uint8
+	F  uint8
+	V  uint16
+	MD uint8
+}
+
+// D returns the concatenation of registers A and B
+func (r Registers) D() uint16 {
+	return uint16(r.A)<<8 | uint16(r.B)
+}
+
+// W returns the concatenation of registers E and F (HD6309)
+func (r Registers) W() uint16 {
+	return uint16(r.E)<<8 | uint16(r.F)
+}
+
+// String formats the registers with the CC flags decoded (EFHINZVC), a cleared
+// flag being displayed as '-'
+func (r Registers) String() string {
+	flags := []byte("EFHINZVC")
+	for i := range flags {
+		if r.CC&(0x80>>uint(i)) == 0 {
+			flags[i] = '-'
+		}
+	}
+	return fmt.Sprintf("A=%02x B=%02x X=%04x Y=%04x U=%04x S=%04x DP=%02x CC=%s PC=%04x",
+		r.A, r.B, r.X, r.Y, r.U, r.S, r.DP, flags, r.PC)
+}
+
+// Registers returns a copy of the CPU registers
+func (c *CPU) Registers() Registers {
+	return Registers{
+		A:  c.a.uint8(),
+		B:  c.b.uint8(),
+		X:  c.x.uint16(),
+		Y:  c.y.uint16(),
+		U:  c.u.uint16(),
+		S:  c.s.uint16(),
+		DP: c.dp.uint8(),
+		CC: c.cc.uint8(),
+		PC: c.pc.uint16(),
+		E:  c.e.uint8(),
+		F:  c.f.uint8(),
+		V:  c.v.uint16(),
+		MD: c.md.uint8(),
+	}
+}
+
+// SetRegisters restores the CPU registers. Like the instructions loading S, it
+// arms NMI.
+func (c *CPU) SetRegisters(r Registers) {
+	c.a.set(int(r.A))
+	c.b.set(int(r.B))
+	c.x.set(int(r.X))
+	c.y.set(int(r.Y))
+	c.u.set(int(r.U))
+	c.s.set(int(r.S))
+	c.dp.set(int(r.DP))
+	c.cc.set(int(r.CC))
+	c.pc.set(int(r.PC))
+	c.e.set(int(r.E))
+	c.f.set(int(r.F))
+	c.v.set(int(r.V))
+	c.md.set(int(r.MD))
+	c.armNMI()
+}
+
+// WaitState tells what the CPU waits for between two instructions
+type WaitState int
+
+const (
+	// NotWaiting is the state of a CPU executing instructions
+	NotWaiting WaitState = WaitState(running)
+	// WaitSync is the state of a CPU waiting for an interrupt line after SYNC
+	WaitSync WaitState = WaitState(syncing)
+	// WaitCWAI is the state of a CPU waiting for an interrupt after CWAI, the
+	// entire state being stacked
+	WaitCWAI WaitState = WaitState(waiting)
+	// Locked is the state of a CPU locked by HCF until the next reset
+	Locked WaitState = WaitState(halted)
+)
+
+// State is a copy of the execution state of the CPU needed by the save states: the
+// registers, the clock, the wait and the interrupt lines. It is taken and restored
+// between two instructions, the decode cache is not part of it.
+type State struct {
+	Registers
+	/// Cycles elapsed since power on
+	Clock uint64
+	/// Wait of CWAI, SYNC or HCF
+	Wait WaitState
+	/// Levels of the IRQ, FIRQ, NMI and RESET lines, true when asserted
+	IRQ, FIRQ, NMI, Reset bool
+	/// A falling edge of NMI has been detected and is not serviced yet
+	NMILatch bool
+	/// NMI has been enabled by a load of S since the reset
+	NMIArmed bool
+	/// RESET has been asserted and the reset sequence has not been run yet
+	ResetLatch bool
+}
+
+// State returns a copy of the execution state
+func (c *CPU) State() State {
+	return State{
+		Registers:  c.Registers(),
+		Clock:      c.clock,
+		Wait:       WaitState(c.state),
+		IRQ:        c.lines.irq,
+		FIRQ:       c.lines.firq,
+		NMI:        c.lines.nmi,
+		Reset:      c.lines.reset,
+		NMILatch:   c.lines.nmiLatch,
+		NMIArmed:   c.lines.nmiArmed,
+		ResetLatch: c.lines.resetLatch,
+	}
+}
+
+// SetState restores an execution state. The devices take the restored clock as
+// their time base and are ticked again before the next instruction.
+func (c *CPU) SetState(s State) {
+	c.SetRegisters(s.Registers)
+	c.clock = s.Clock
+	c.bus = s.Clock
+	c.state = cpuState(s.Wait)
+	c.lines = interruptLines{
+		irq:        s.IRQ,
+		firq:       s.FIRQ,
+		nmi:        s.NMI,
+		nmiLatch:   s.NMILatch,
+		nmiArmed:   s.NMIArmed,
+		reset:      s.Reset,
+		resetLatch: s.ResetLatch,
+	}
+	c.setDeviceClocks()
+	c.Reschedule()
+}
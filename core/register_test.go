@@ -0,0 +1,86 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registers", func() {
+	var (
+		cpu CPU
+	)
+
+	BeforeEach(func() {
+		cpu = CPU{}
+		ram := NewRam()
+		cpu.Initialize(ram)
+	})
+
+	It("should return a copy of the registers", func() {
+		cpu.a.set(0x2f)
+		cpu.b.set(0x05)
+		cpu.x.set(0xe0ff)
+		cpu.s.set(0x2000)
+		cpu.cc.set(0xd5)
+		cpu.pc.set(0x1000)
+		regs := cpu.Registers()
+
+		Expect(regs).To(Equal(Registers{A: 0x2f, B: 0x05, X: 0xe0ff, S: 0x2000, CC: 0xd5, PC: 0x1000}))
+		Expect(regs.D()).To(BeEquivalentTo(0x2f05))
+
+		cpu.a.set(0x00)
+		Expect(regs.A).To(BeEquivalentTo(0x2f))
+	})
+
+	It("should restore the registers", func() {
+		cpu.SetRegisters(Registers{A: 0x2f, B: 0x05, X: 0xe0ff, Y: 0xa200, U: 0x7fff, S: 0x2000, DP: 0x18, CC: 0x81, PC: 0x1000})
+
+		ExpectD(cpu, 0x2f05)
+		ExpectX(cpu, 0xe0ff)
+		ExpectY(cpu, 0xa200)
+		ExpectU(cpu, 0x7fff)
+		ExpectS(cpu, 0x2000)
+		ExpectPC(cpu, 0x1000)
+		ExpectCCR(cpu, "EC", "FHINZV")
+		Expect(cpu.dp.get()).To(BeEquivalentTo(0x18))
+	})
+
+	It("should arm NMI when the registers are restored", func() {
+		cpu.SetRegisters(Registers{S: 0x2000, PC: 0x1000})
+		cpu.AssertNMI()
+		cpu.step()
+
+		ExpectPC(cpu, 0x0000) // NMI vector in the blank RAM
+		ExpectS(cpu, 0x2000-12)
+	})
+
+	It("should restore the execution state", func() {
+		cpu.write(0x1000, 0x13) // SYNC
+		cpu.SetRegisters(Registers{S: 0x2000, PC: 0x1000})
+		cpu.step()
+		cpu.AssertIRQ()
+		cpu.ReleaseIRQ()
+		cpu.AssertNMI()
+		saved := cpu.State()
+		Expect(saved.Wait).To(Equal(WaitSync))
+		Expect(saved.NMILatch).To(BeTrue())
+		Expect(saved.Clock).To(BeEquivalentTo(4))
+
+		cpu.ReleaseNMI()
+		cpu.step()
+		ExpectS(cpu, 0x2000-12)
+		cpu.SetState(saved)
+
+		Expect(cpu.State()).To(Equal(saved))
+		ExpectS(cpu, 0x2000)
+		ExpectClock(cpu, 4)
+		cpu.step()
+		ExpectS(cpu, 0x2000-12)
+	})
+
+	It("should format the registers with decoded flags", func() {
+		regs := Registers{A: 0x2f, B: 0x05, X: 0xe0ff, Y: 0xa200, U: 0x7fff, S: 0x2000, DP: 0x18, CC: 0xd5, PC: 0x1000}
+
+		Expect(regs.String()).To(Equal("A=2f B=05 X=e0ff Y=a200 U=7fff S=2000 DP=18 CC=EF-I-Z-C PC=1000"))
+	})
+})
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ROMSlot is the location where a ROM is mapped
+type ROMSlot int
+
+// ROM slots
+const (
+	// Monitor ROM at the top of memory
+	MonitorSlot ROMSlot = iota
+	// Cartridge ROM at the bottom of memory (BASIC, games)
+	CartridgeSlot
+)
+
+func (s ROMSlot) String() string {
+	if s == MonitorSlot {
+		return "monitor"
+	}
+	return "cartridge"
+}
+
+/** Check the size of an image against the size of the slot of the TO7/70: 6K for
+ * the monitor, up to 16K for a cartridge */
+func (s ROMSlot) checkSize(data []uint8) error {
+	if s == MonitorSlot && len(data) != monitorSize {
+		return fmt.Errorf("%d bytes instead of %d bytes for a monitor ROM", len(data), monitorSize)
+	}
+	if s == CartridgeSlot && (len(data) == 0 || len(data) > cartridgeSize) {
+		return fmt.Errorf("%d bytes, a cartridge ROM has 1 to %d bytes", len(data), cartridgeSize)
+	}
+	return nil
+}
+
+// ROMInfo identifies a ROM revision
+type ROMInfo struct {
+	/// Name of the revision
+	Name string
+	/// Machine running the ROM: "TO7", "TO7/70" or "MO5"
+	Machine string
+	/// Location of the ROM
+	Slot ROMSlot
+	/// SHA-1 hash of the content, lowercase hexadecimal
+	SHA1 string
+}
+
+// KnownROMs is the table of the ROM revisions identified by IdentifyROM. Entries
+// are added from verified dumps only. LoadROMs still loads a ROM missing from the
+// table when its size fits its slot, with a warning.
+var KnownROMs = []ROMInfo{}
+
+// ErrUnknownROM is returned when the hash of a ROM is not in KnownROMs
+var ErrUnknownROM = errors.New("unknown ROM")
+
+// IdentifyROM looks up the revision of a ROM image by its SHA-1 hash
+func IdentifyROM(data []uint8) (ROMInfo, error) {
+	sum := sha1.Sum(data)
+	hash := hex.EncodeToString(sum[:])
+	for _, info := range KnownROMs {
+		if info.SHA1 == hash {
+			return info, nil
+		}
+	}
+	return ROMInfo{}, fmt.Errorf("%w: sha1 %s, %d bytes", ErrUnknownROM, hash, len(data))
+}
+
+// LoadROM reads a raw ROM image from a file and identifies it
+func LoadROM(path string) ([]uint8, ROMInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, ROMInfo{}, fmt.Errorf("rom: %w", err)
+	}
+	return identify(path, data)
+}
+
+/** Identify the image read from a file */
+func identify(path string, data []uint8) ([]uint8, ROMInfo, error) {
+	info, err := IdentifyROM(data)
+	if err != nil {
+		return nil, info, fmt.Errorf("rom %s: %w", path, err)
+	}
+	return data, info, nil
+}
+
+// LoadROMs loads the ROMs of a TO7/70 into the profile. The monitor is required
+// and the cartridge is optional, it is skipped when the path is empty. Each ROM
+// must fit its slot. A known revision must also be a TO7/70 ROM for this slot, an
+// unknown one is loaded with a warning.
+func (p *Profile) LoadROMs(monitor string, cartridge string) error {
+	if monitor == "" {
+		return errors.New("rom: the monitor ROM is required")
+	}
+	data, err := loadSlot(monitor, MonitorSlot)
+	if err != nil {
+		return err
+	}
+	p.Monitor = data
+	if cartridge != "" {
+		if data, err = loadSlot(cartridge, CartridgeSlot); err != nil {
+			return err
+		}
+		p.Cartridge = data
+	}
+	return nil
+}
+
+/** Load a ROM and check that it runs on a TO7/70 in the given slot. The size is
+ * checked first so that a truncated image is not loaded as an unknown revision. */
+func loadSlot(path string, slot ROMSlot) ([]uint8, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("rom: %w", err)
+	}
+	if err := slot.checkSize(data); err != nil {
+		return nil, fmt.Errorf("rom %s: %w", path, err)
+	}
+	_, info, err := identify(path, data)
+	if errors.Is(err, ErrUnknownROM) {
+		log.Warnf("%v, loaded as a %s ROM", err, slot)
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Slot != slot {
+		return nil, fmt.Errorf("rom %s: %s is a %s ROM, not a %s ROM", path, info.Name, info.Slot, slot)
+	}
+	if info.Machine != "TO7/70" && !(slot == CartridgeSlot && info.Machine == "TO7") {
+		return nil, fmt.Errorf("rom %s: %s is a %s ROM", path, info.Name, info.Machine)
+	}
+	return data, nil
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ROM loader", func() {
+	var (
+		dir   string
+		known []ROMInfo
+	)
+
+	image := func(size int, seed uint8) []uint8 {
+		data := make([]uint8, size)
+		for i := range data {
+			data[i] = seed + uint8(i)
+		}
+		return data
+	}
+
+	hash := func(data []uint8) string {
+		sum := sha1.Sum(data)
+		return hex.EncodeToString(sum[:])
+	}
+
+	file := func(name string, data []uint8) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, data, 0644)).To(Succeed())
+		return path
+	}
+
+	monitor := image(0x1800, 0)
+	basic := image(0x4000, 1)
+	mo5 := image(0x1800, 2)
+	game := image(0x1800, 4)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "rom")
+		Expect(err).NotTo(HaveOccurred())
+		known = KnownROMs
+		KnownROMs = []ROMInfo{
+			{Name: "TO7/70 monitor", Machine: "TO7/70", Slot: MonitorSlot, SHA1: hash(monitor)},
+			{Name: "BASIC 1.0", Machine: "TO7", Slot: CartridgeSlot, SHA1: hash(basic)},
+			{Name: "MO5 monitor", Machine: "MO5", Slot: MonitorSlot, SHA1: hash(mo5)},
+			{Name: "Game", Machine: "TO7/70", Slot: CartridgeSlot, SHA1: hash(game)},
+		}
+	})
+
+	AfterEach(func() {
+		KnownROMs = known
+		os.RemoveAll(dir)
+	})
+
+	It("should identify a ROM by its hash", func() {
+		info, err := IdentifyROM(basic)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Name).To(Equal("BASIC 1.0"))
+	})
+
+	It("should reject an unknown ROM", func() {
+		_, _, err := LoadROM(file("unknown.rom", image(0x1800, 3)))
+
+		Expect(err).To(MatchError(ContainSubstring("unknown ROM")))
+	})
+
+	It("should load an unknown ROM which fits its slot", func() {
+		unknown := image(0x1800, 3)
+		p := DefaultProfile
+
+		Expect(p.LoadROMs(file("unknown.rom", unknown), "")).To(Succeed())
+		Expect(p.Monitor).To(Equal(unknown))
+		Expect(p.LoadROMs(file("unknown.rom", image(0x1000, 3)), "")).To(MatchError(ContainSubstring("instead of 6144 bytes")))
+	})
+
+	It("should report a missing file", func() {
+		_, _, err := LoadROM(filepath.Join(dir, "missing.rom"))
+
+		Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+	})
+
+	It("should map the ROMs of the profile", func() {
+		p := DefaultProfile
+		Expect(p.LoadROMs(file("to770.rom", monitor), file("basic.rom", basic))).To(Succeed())
+		m, err := NewTO770(p)
+		Expect(err).NotTo(HaveOccurred())
+		m.Bus.Write(0xe800, 0xff)
+
+		Expect(m.Bus.Read(0xe801)).To(BeEquivalentTo(0x01))
+		Expect(m.Bus.Read(0xe800)).To(BeEquivalentTo(0x00))
+		Expect(m.Bus.Read(0x3fff)).To(BeEquivalentTo(basic[0x3fff]))
+	})
+
+	It("should require the monitor ROM", func() {
+		p := DefaultProfile
+
+		Expect(p.LoadROMs("", file("basic.rom", basic))).To(MatchError(ContainSubstring("monitor ROM is required")))
+	})
+
+	It("should reject the ROMs of another machine or slot", func() {
+		p := DefaultProfile
+
+		Expect(p.LoadROMs(file("mo5.rom", mo5), "")).To(MatchError(ContainSubstring("is a MO5 ROM")))
+		Expect(p.LoadROMs(file("game.rom", game), "")).To(MatchError(ContainSubstring("not a monitor ROM")))
+	})
+
+	It("should check the size of the ROMs against their slot", func() {
+		p := DefaultProfile
+
+		Expect(p.LoadROMs(file("to770.rom", monitor[:0x1000]), "")).To(MatchError(ContainSubstring("4096 bytes instead of 6144 bytes")))
+		Expect(p.LoadROMs(file("basic.rom", basic), "")).To(MatchError(ContainSubstring("instead of 6144 bytes")))
+		Expect(p.LoadROMs(file("to770.rom", monitor), file("big.rom", image(0x4001, 5)))).To(MatchError(ContainSubstring("1 to 16384 bytes")))
+	})
+})
@@ -0,0 +1,112 @@
+package core
+
+import "sync/atomic"
+
+// Access is a memory access performed by the CPU
+type Access struct {
+	/// Address of the instruction performing the access, or of the instruction
+	/// interrupted by an interrupt sequence
+	PC uint16
+	/// Opcode of the instruction including the page prefix, -1 during the opcode
+	/// fetch and the interrupt sequences
+	Opcode int
+	/// Accessed address: the effective address for the operand accesses
+	Address uint16
+	/// Value read or written
+	Value uint16
+	/// The access is a write
+	Write bool
+	/// The access is a 16-bit access
+	Word bool
+	/// Clock cycle of the access in cycle exact mode, clock at the start of the
+	/// instruction otherwise
+	Cycle uint64
+	/// The value is discarded: dead cycle or dummy read (cycle exact mode)
+	Dummy bool
+}
+
+// StepResult describes what the CPU did during a call to Step
+type StepResult struct {
+	/// Program counter before the step
+	PC uint16
+	/// Opcode of the executed instruction including the page prefix, -1 if no
+	/// instruction has been executed (interrupt entry, SYNC, CWAI, reset...)
+	Opcode int
+	/// Number of cycles elapsed
+	Cycles uint64
+	/// Memory accesses in the order they have been performed. The slice is only
+	/// valid until the next call to Step.
+	Accesses []Access
+}
+
+// Clock returns the number of cycles elapsed since power on
+func (c *CPU) Clock() uint64 {
+	return c.clock
+}
+
+// PC returns the program counter
+func (c *CPU) PC() uint16 {
+	return c.pc.uint16()
+}
+
+// Step executes one instruction, or enters an interrupt, and returns what has been
+// done. A stop requested during the step, by a hook or a watchpoint, is consumed
+// by the step itself: it does not make the next RunCycles or RunUntil return.
+func (c *CPU) Step() (StepResult, error) {
+	result := StepResult{PC: c.pc.uint16()}
+	c.accesses = c.accesses[:0]
+	c.recording = true
+	pending := c.stopped()
+	cycles, err := c.step()
+	c.stopped()
+	if pending {
+		c.Stop()
+	}
+	c.recording = false
+	result.Opcode = c.op
+	result.Cycles = cycles
+	result.Accesses = c.accesses
+	return result, err
+}
+
+// RunCycles executes instructions until at least the given number of cycles has
+// elapsed, an error occurs or Stop is called. It returns the number of cycles
+// actually elapsed, which may exceed the budget by the length of the last
+// instruction.
+func (c *CPU) RunCycles(budget uint64) (uint64, error) {
+	start := c.clock
+	for !c.stopped() && c.clock-start < budget {
+		if _, err := c.step(); err != nil {
+			return c.clock - start, err
+		}
+	}
+	return c.clock - start, nil
+}
+
+// RunUntil executes instructions until the predicate, evaluated before each
+// instruction, returns true, an error occurs or Stop is called.
+func (c *CPU) RunUntil(predicate func(c *CPU) bool) error {
+	for !c.stopped() && !predicate(c) {
+		if _, err := c.step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop requests RunCycles or RunUntil to return after the current instruction. It
+// can be called from another goroutine or from a memory mapped device. A stop
+// requested while the CPU is not running makes the next call return at once.
+func (c *CPU) Stop() {
+	atomic.StoreInt32(&c.stop, 1)
+}
+
+/** A stop has been requested */
+func (c *CPU) stopping() bool {
+	return atomic.LoadInt32(&c.stop) != 0
+}
+
+/** Consume a stop request */
+func (c *CPU) stopped() bool {
+	return atomic.CompareAndSwapInt32(&c.stop, 1, 0)
+}
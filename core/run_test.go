@@ -0,0 +1,137 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Execution API", func() {
+	var (
+		cpu CPU
+	)
+
+	BeforeEach(func() {
+		cpu = CPU{}
+		ram := NewRam()
+		cpu.Initialize(ram)
+		cpu.pc.set(0x1000)
+		cpu.s.set(0x2000)
+	})
+
+	It("should return the executed instruction and its memory accesses", func() {
+		cpu.writew(0x1000, 0x10be) // LDY $3000
+		cpu.writew(0x1002, 0x3000)
+		cpu.writew(0x3000, 0xcafe)
+		result, err := cpu.Step()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.PC).To(BeEquivalentTo(0x1000))
+		Expect(result.Opcode).To(Equal(0x10be))
+		Expect(result.Cycles).To(BeEquivalentTo(7))
+		Expect(result.Accesses).To(Equal([]Access{
+			{PC: 0x1000, Opcode: -1, Address: 0x1000, Value: 0x10},
+			{PC: 0x1000, Opcode: -1, Address: 0x1001, Value: 0xbe},
+			{PC: 0x1000, Opcode: 0x10be, Address: 0x1002, Value: 0x3000, Word: true},
+			{PC: 0x1000, Opcode: 0x10be, Address: 0x3000, Value: 0xcafe, Word: true},
+		}))
+	})
+
+	It("should report the write accesses", func() {
+		cpu.a.set(0x2f)
+		cpu.write(0x1000, 0x97) // STA <$10
+		cpu.write(0x1001, 0x10)
+		result, _ := cpu.Step()
+
+		Expect(result.Accesses).To(ContainElement(Access{PC: 0x1000, Opcode: 0x97, Address: 0x0010, Value: 0x2f, Write: true}))
+	})
+
+	It("should report extra cycles in the step result", func() {
+		cpu.writew(0x1000, 0x1027) // LBEQ *+$0010
+		cpu.writew(0x1002, 0x0010)
+		cpu.cc.setZ()
+		result, _ := cpu.Step()
+
+		Expect(result.Cycles).To(BeEquivalentTo(6))
+		ExpectPC(cpu, 0x1014)
+	})
+
+	It("should report interrupt entry without opcode", func() {
+		cpu.writew(0xfff8, 0xc200)
+		cpu.AssertIRQ()
+		result, _ := cpu.Step()
+
+		Expect(result.Opcode).To(Equal(-1))
+		Expect(result.Cycles).To(BeEquivalentTo(19))
+		ExpectPC(cpu, 0xc200)
+	})
+
+	It("should run for a cycle budget", func() {
+		cpu.write(0x1000, 0x4c) // INCA
+		cpu.write(0x1001, 0x20) // BRA *-3
+		cpu.write(0x1002, 0xfd)
+		cycles, err := cpu.RunCycles(50)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cycles).To(BeEquivalentTo(50))
+		ExpectA(cpu, 10)
+		Expect(cpu.Clock()).To(BeEquivalentTo(50))
+	})
+
+	It("should stop on illegal instructions", func() {
+		cpu.write(0x1000, 0x12) // NOP
+		cpu.write(0x1001, 0x01)
+		cycles, err := cpu.RunCycles(50)
+
+		Expect(err).To(BeAssignableToTypeOf(&IllegalInstruction{}))
+		Expect(cycles).To(BeEquivalentTo(2))
+		Expect(cpu.PC()).To(BeEquivalentTo(0x1001))
+	})
+
+	It("should run until the predicate is true", func() {
+		cpu.write(0x1000, 0x4c) // INCA
+		cpu.write(0x1001, 0x20) // BRA *-3
+		cpu.write(0x1002, 0xfd)
+		err := cpu.RunUntil(func(c *CPU) bool { return c.a.get() == 5 && c.PC() == 0x1000 })
+
+		Expect(err).NotTo(HaveOccurred())
+		ExpectA(cpu, 5)
+	})
+
+	It("should run until stop is requested", func() {
+		ram := &stopMemory{Memory: NewRam(), cpu: &cpu}
+		cpu.Initialize(ram)
+		cpu.pc.set(0x1000)
+		cpu.write(0x1000, 0xb7) // STA $e7c0
+		cpu.writew(0x1001, 0xe7c0)
+		cpu.write(0x1003, 0x20) // BRA *-5
+		cpu.write(0x1004, 0xfb)
+		err := cpu.RunUntil(func(c *CPU) bool { return false })
+
+		Expect(err).NotTo(HaveOccurred())
+		ExpectPC(cpu, 0x1003)
+	})
+
+	It("should keep a stop requested before running", func() {
+		cpu.write(0x1000, 0x4c) // INCA
+		cpu.Stop()
+		cycles, err := cpu.RunCycles(50)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cycles).To(BeZero())
+		ExpectPC(cpu, 0x1000)
+		cycles, _ = cpu.RunCycles(2)
+		Expect(cycles).To(BeEquivalentTo(2))
+	})
+})
+
+type stopMemory struct {
+	Memory
+	cpu *CPU
+}
+
+func (mem *stopMemory) Write(address uint16, value uint8) {
+	mem.Memory.Write(address, value)
+	if address == 0xe7c0 {
+		mem.cpu.Stop()
+	}
+}
@@ -0,0 +1,205 @@
+package core
+
+/* Copy-on-write snapshots
+ *
+ * The RAM is stored in pages of 256 bytes. A snapshot copies the page table and
+ * starts a new generation: the pages are then shared between the memory and the
+ * snapshot until the memory writes them, a page being copied on its first write
+ * of the generation. Taking a snapshot costs the copy of the page table, one
+ * pointer per page, and the following writes copy the dirty pages only. A restore
+ * stamps all the pages as changed so that the CPU drops the instructions it has
+ * decoded from them.
+ */
+
+const (
+	pageBits = 8
+	pageSize = 1 << pageBits
+	pageMask = pageSize - 1
+)
+
+type page [pageSize]uint8
+
+// MemoryState is the saved content of a Snapshotter. It is immutable and can only
+// be restored by the Snapshotter which returned it.
+type MemoryState interface{}
+
+// Snapshotter is implemented by the memories and the handlers supporting copy-on-
+// write snapshots. A snapshot can be restored any number of times, to rewind or to
+// explore several executions from the same state. A restore drops the instructions
+// decoded from the restored memory.
+type Snapshotter interface {
+	Snapshot() MemoryState
+	Restore(s MemoryState)
+}
+
+// Pages is a copy-on-write paged storage
+type Pages struct {
+	/// Page table
+	pages []*page
+	/// Generation in which each page has been copied, the pages of previous
+	/// generations are shared with snapshots
+	owner []uint64
+	/// Current generation, incremented by the snapshots and the restores
+	gen uint64
+	/// Stamp of the last change of each page
+	changed []uint64
+	/// Size in bytes
+	size int
+}
+
+/** Saved page table */
+type pagesSnapshot struct {
+	pages []*page
+}
+
+// NewPages returns a zeroed storage of the given size
+func NewPages(size int) *Pages {
+	n := (size + pageMask) >> pageBits
+	p := &Pages{pages: make([]*page, n), owner: make([]uint64, n), changed: make([]uint64, n), size: size}
+	for i := range p.pages {
+		p.pages[i] = new(page)
+	}
+	return p
+}
+
+// Size returns the size of the storage
+func (p *Pages) Size() int {
+	return p.size
+}
+
+// Get returns the byte at an offset
+func (p *Pages) Get(offset int) uint8 {
+	return p.pages[offset>>pageBits][offset&pageMask]
+}
+
+// Set writes the byte at an offset, copying its page if it is shared with a
+// snapshot
+func (p *Pages) Set(offset int, value uint8) {
+	i := offset >> pageBits
+	if p.owner[i] != p.gen {
+		copied := *p.pages[i]
+		p.pages[i] = &copied
+		p.owner[i] = p.gen
+	}
+	p.pages[i][offset&pageMask] = value
+	p.changed[i] = changeNow()
+}
+
+// Changed returns the stamp of the last change of the page containing an offset
+func (p *Pages) Changed(offset uint16) uint64 {
+	return p.changed[int(offset)>>pageBits]
+}
+
+// Load writes bytes from an offset
+func (p *Pages) Load(offset int, data []uint8) {
+	for i, b := range data {
+		p.Set(offset+i, b)
+	}
+}
+
+// Bytes returns a copy of the content
+func (p *Pages) Bytes() []uint8 {
+	data := make([]uint8, 0, len(p.pages)*pageSize)
+	for _, pg := range p.pages {
+		data = append(data, pg[:]...)
+	}
+	return data[:p.size]
+}
+
+// Snapshot saves the content. The pages are shared until they are written: taking
+// a snapshot copies the page table, in O(size/256), and the writes which follow
+// copy the dirty pages only.
+func (p *Pages) Snapshot() MemoryState {
+	s := &pagesSnapshot{pages: make([]*page, len(p.pages))}
+	copy(s.pages, p.pages)
+	p.gen++
+	return s
+}
+
+// Restore gives back the content of a snapshot. All the pages are stamped as
+// changed.
+func (p *Pages) Restore(s MemoryState) {
+	copy(p.pages, s.(*pagesSnapshot).pages)
+	p.gen++
+	now := changeNow()
+	for i := range p.changed {
+		p.changed[i] = now
+	}
+}
+
+/** Saved bank contents and active bank */
+type bankedSnapshot struct {
+	banks  []MemoryState
+	active int
+}
+
+// Snapshot saves the content of all the banks and the active bank
+func (b *Banked) Snapshot() MemoryState {
+	s := &bankedSnapshot{banks: make([]MemoryState, len(b.banks)), active: b.active}
+	for i, bank := range b.banks {
+		s.banks[i] = bank.Snapshot()
+	}
+	return s
+}
+
+// Restore gives back the content of the banks and the active bank
+func (b *Banked) Restore(s MemoryState) {
+	saved := s.(*bankedSnapshot)
+	for i, bank := range b.banks {
+		bank.Restore(saved.banks[i])
+	}
+	b.Select(saved.active)
+}
+
+/** Saved handlers of a bus */
+type busSnapshot struct {
+	handlers []Snapshotter
+	states   []MemoryState
+	data     uint8
+}
+
+// Snapshot saves the handlers which support snapshots and the value of the data
+// bus. The mappings are not saved.
+func (b *Bus) Snapshot() MemoryState {
+	s := &busSnapshot{data: b.data}
+	for _, m := range b.mappings {
+		h, ok := m.Handler.(Snapshotter)
+		if !ok || containsSnapshotter(s.handlers, h) {
+			continue
+		}
+		s.handlers = append(s.handlers, h)
+		s.states = append(s.states, h.Snapshot())
+	}
+	return s
+}
+
+/** A handler mapped several times is saved once */
+func containsSnapshotter(handlers []Snapshotter, h Snapshotter) bool {
+	for _, x := range handlers {
+		if x == h {
+			return true
+		}
+	}
+	return false
+}
+
+// Restore gives back the content of the saved handlers and the value of the data
+// bus
+func (b *Bus) Restore(s MemoryState) {
+	saved := s.(*busSnapshot)
+	for i, h := range saved.handlers {
+		h.Restore(saved.states[i])
+	}
+	b.data = saved.data
+}
+
+// Snapshot saves the content of the memory
+func (mem *memoryImpl) Snapshot() MemoryState {
+	return mem.pages.Snapshot()
+}
+
+// Restore gives back the content of the memory, the decoded instructions being
+// dropped
+func (mem *memoryImpl) Restore(s MemoryState) {
+	mem.pages.Restore(s)
+}
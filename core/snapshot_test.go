@@ -0,0 +1,106 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory snapshots", func() {
+
+	It("should restore the content of the pages", func() {
+		p := NewPages(0x1000)
+		p.Set(0x0010, 0x01)
+		s := p.Snapshot()
+		p.Set(0x0010, 0x02)
+		p.Set(0x0fff, 0x03)
+		Expect(p.Get(0x0010)).To(BeEquivalentTo(0x02))
+
+		p.Restore(s)
+		Expect(p.Get(0x0010)).To(BeEquivalentTo(0x01))
+		Expect(p.Get(0x0fff)).To(BeEquivalentTo(0x00))
+
+		p.Set(0x0010, 0x04)
+		p.Restore(s)
+		Expect(p.Get(0x0010)).To(BeEquivalentTo(0x01))
+	})
+
+	It("should only copy the written pages", func() {
+		p := NewPages(0x10000)
+		s := p.Snapshot().(*pagesSnapshot)
+		p.Set(0x1234, 0x01)
+		p.Set(0x12ff, 0x02)
+
+		copied := 0
+		for i := range p.pages {
+			if p.pages[i] != s.pages[i] {
+				copied++
+			}
+		}
+		Expect(copied).To(Equal(1))
+		Expect(s.pages[0x12][0x34]).To(BeEquivalentTo(0x00))
+	})
+
+	It("should restore the banks and the handlers of a bus", func() {
+		bus := NewBus()
+		ram := NewRAM(0x100)
+		banked := NewBanked(2, 0x100)
+		Expect(bus.Map(Mapping{From: 0x0000, To: 0x0fff, Mask: 0xff, Handler: ram})).To(Succeed())
+		Expect(bus.Map(Mapping{From: 0x1000, To: 0x10ff, Handler: banked})).To(Succeed())
+		Expect(bus.Map(Mapping{From: 0x2000, To: 0x20ff, Handler: NewROM(make([]uint8, 0x100))})).To(Succeed())
+		bus.Write(0x0000, 0x11)
+		bus.Write(0x1000, 0x22)
+		s := bus.Snapshot()
+		Expect(s.(*busSnapshot).handlers).To(HaveLen(2))
+
+		bus.Write(0x0100, 0x33)
+		banked.Select(1)
+		bus.Write(0x1000, 0x44)
+		bus.Restore(s)
+
+		Expect(bus.Read(0x0000)).To(BeEquivalentTo(0x11))
+		Expect(banked.Active()).To(Equal(0))
+		Expect(bus.Read(0x1000)).To(BeEquivalentTo(0x22))
+		Expect(banked.Bank(1).Get(0)).To(BeEquivalentTo(0x00))
+	})
+
+	It("should execute the restored code when the decoded instructions are cached", func() {
+		ram := NewRam()
+		ram.Write(0x1000, 0x4c) // INCA
+		ram.Write(0x1001, 0x20) // BRA $1000
+		ram.Write(0x1002, 0xfd)
+		cpu := NewCPU(ram, MC6809)
+		cpu.SetDecodeCache(true)
+		cpu.pc.set(0x1000)
+		s := ram.(Snapshotter).Snapshot()
+		ram.Write(0x1000, 0x4a) // DECA
+		cpu.step()
+		cpu.step()
+		ExpectA(*cpu, 0xff)
+
+		ram.(Snapshotter).Restore(s)
+		cpu.step()
+		ExpectA(*cpu, 0x00)
+	})
+
+	It("should explore several executions from the same state", func() {
+		ram := NewRam()
+		for i, b := range benchmarkProgram {
+			ram.Write(0x1000+uint16(i), b)
+		}
+		cpu := NewCPU(ram, MC6809)
+		cpu.pc.set(0x1000)
+		cpu.s.set(0x8000)
+		cpu.RunCycles(1000)
+		registers := cpu.Registers()
+		s := ram.(Snapshotter).Snapshot()
+
+		cpu.RunCycles(5000)
+		after := CopyMemory(ram)
+		ram.(Snapshotter).Restore(s)
+		Expect(CopyMemory(ram)).NotTo(Equal(after))
+		cpu.SetRegisters(registers)
+		cpu.RunCycles(5000)
+
+		Expect(CopyMemory(ram)).To(Equal(after))
+	})
+})
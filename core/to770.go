@@ -1,11 +1,277 @@
 package core
 
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+/* TO7/70 memory map
+ *
+ *	$0000-$3FFF  cartridge ROM
+ *	$4000-$5FFF  video memory: form or colour plane
+ *	$6000-$9FFF  system and user RAM
+ *	$A000-$DFFF  RAM extension of the 48K configuration or paged extension
+ *	$E7C0-$E7FF  I/O page: 6846, 6821 and extensions
+ *	$E7C0-$E7C7  MC6846 timer and system port
+ *	$E7C8-$E7CB  MC6821 system PIA
+ *	$E800-$FFFF  monitor ROM
+ *
+ * The bank of the paged extension seen at $A000-$DFFF is selected by bits 3-7 of
+ * the port B of the system PIA, with the patterns of to770_update_ram_bank in the
+ * MAME thomson driver. The other patterns leave the active bank unchanged.
+ */
+
+const (
+	cartridgeStart = 0x0000
+	cartridgeSize  = 0x4000
+	videoStart     = 0x4000
+	videoSize      = 0x2000
+	ramStart       = 0x6000
+	extensionStart = 0xa000
+	extensionSize  = 0x4000
+	ioStart        = 0xe7c0
+	ioEnd          = 0xe7ff
+	mc6846Start    = 0xe7c0
+	mc6846End      = 0xe7c7
+	mc6821Start    = 0xe7c8
+	mc6821End      = 0xe7cb
+	monitorStart   = 0xe800
+	monitorSize    = 0x1800
+)
+
+/** Port B patterns of the system PIA selecting the banks of the paged extension */
+var extensionBanks = []uint8{0xf0, 0xe8, 0x18, 0x98, 0x58, 0xd8}
+
+// RAM sizes of the TO7/70 configurations. The 48K configuration counts the two 8K
+// planes of the video memory and maps 32K of RAM at $6000-$DFFF.
+const (
+	RAM16K = 16 * 1024
+	RAM48K = 48 * 1024
+)
+
+// Planes of the video memory
+const (
+	ColorPlane = 0
+	FormPlane  = 1
+)
+
+// Profile describes the configuration of a TO7/70
+type Profile struct {
+	/// Size of the RAM: RAM16K or RAM48K
+	RAMSize int
+	/// Content of the monitor ROM, blank if nil
+	Monitor []uint8
+	/// Content of the cartridge ROM, nil if no cartridge is inserted
+	Cartridge []uint8
+	/// Number of 16K banks of the paged RAM extension, up to 6, 0 if there is no
+	/// extension. The extension requires the 16K configuration.
+	Extension int
+	/// Content of the RAM at power on
+	Fill PowerOnFill
+	/// Seed of RandomFill
+	Seed int64
+}
+
+// DefaultProfile is the basic TO7/70 with 16K of RAM
+var DefaultProfile = Profile{RAMSize: RAM16K}
+
+// TO770 is the memory of a TO7/70 built from a Profile
+type TO770 struct {
+	/// Address decoder
+	Bus *Bus
+	/// Cartridge ROM, nil if no cartridge is inserted
+	Cartridge *ROM
+	/// Video memory, the form and colour planes
+	Video *Banked
+	/// System and user RAM, extension included
+	RAM *RAM
+	/// Monitor ROM
+	Monitor *ROM
+	/// Paged RAM extension, nil if there is no extension
+	Extension *Banked
+	/// MC6846 timer and system port, bit 0 of the port selecting the video plane
+	System *MC6846
+	/// MC6821 system PIA, bits 3-7 of port B selecting the bank of the paged
+	/// extension
+	PIA *MC6821
+	/// Configuration of the machine
+	profile Profile
+	/// CPU bound by Connect, nil if not connected
+	cpu *CPU
+}
+
+// NewTO770 maps the regions of a TO7/70 on a new bus. The unmapped addresses of
+// the I/O page read as open bus until devices are attached with MapDevice.
+func NewTO770(p Profile) (*TO770, error) {
+	if p.RAMSize != RAM16K && p.RAMSize != RAM48K {
+		return nil, fmt.Errorf("to770: unsupported RAM size %d", p.RAMSize)
+	}
+	if len(p.Cartridge) > cartridgeSize {
+		return nil, fmt.Errorf("to770: cartridge of %d bytes exceeds %d bytes", len(p.Cartridge), cartridgeSize)
+	}
+	monitor := p.Monitor
+	if monitor == nil {
+		monitor = make([]uint8, monitorSize)
+		for i := range monitor {
+			monitor[i] = 0xff
+		}
+	}
+	if len(monitor) != monitorSize {
+		return nil, fmt.Errorf("to770: monitor of %d bytes instead of %d bytes", len(monitor), monitorSize)
+	}
+	if p.Extension < 0 || p.Extension > len(extensionBanks) || p.Extension > 0 && p.RAMSize != RAM16K {
+		return nil, fmt.Errorf("to770: unsupported extension of %d banks with %d bytes of RAM", p.Extension, p.RAMSize)
+	}
+	if p.Fill < ZeroFill || p.Fill > RandomFill {
+		return nil, fmt.Errorf("to770: unknown power-on fill %d", p.Fill)
+	}
+	size := RAM16K
+	if p.RAMSize == RAM48K {
+		size += extensionSize
+	}
+	m := &TO770{
+		Bus:     NewBus(),
+		Video:   NewBanked(2, videoSize),
+		RAM:     NewRAM(size),
+		Monitor: NewROM(monitor),
+		profile: p,
+	}
+	mappings := []Mapping{
+		{From: videoStart, To: videoStart + videoSize - 1, Handler: m.Video},
+		{From: ramStart, To: uint16(ramStart + size - 1), Handler: m.RAM},
+		{From: monitorStart, To: monitorStart + monitorSize - 1, Handler: m.Monitor},
+	}
+	if len(p.Cartridge) > 0 {
+		m.Cartridge = NewROM(p.Cartridge)
+		mappings = append(mappings, Mapping{From: cartridgeStart, To: uint16(cartridgeStart + len(p.Cartridge) - 1), Handler: m.Cartridge})
+	}
+	for _, mapping := range mappings {
+		if err := m.Bus.Map(mapping); err != nil {
+			return nil, err
+		}
+	}
+	if p.Extension > 0 {
+		m.Extension = NewBanked(p.Extension, extensionSize)
+		if err := m.Bus.Map(Mapping{From: extensionStart, To: extensionStart + extensionSize - 1, Handler: m.Extension}); err != nil {
+			return nil, err
+		}
+	}
+	m.System = NewMC6846()
+	m.System.Output = func(value uint8) {
+		m.SelectPlane(value&0x01 != 0)
+	}
+	m.System.Reset()
+	if err := m.MapDevice(mc6846Start, mc6846End, m.System); err != nil {
+		return nil, err
+	}
+	m.PIA = NewMC6821()
+	m.PIA.OutputB = m.selectExtensionBank
+	if err := m.MapDevice(mc6821Start, mc6821End, m.PIA); err != nil {
+		return nil, err
+	}
+	m.FillRAM()
+	return m, nil
+}
+
+// FillRAM gives the video memory, the RAM and the extension their power-on
+// content, according to the Fill strategy of the profile
+func (m *TO770) FillRAM() {
+	rnd := rand.New(rand.NewSource(m.profile.Seed))
+	regions := []*RAM{m.Video.Bank(ColorPlane), m.Video.Bank(FormPlane), m.RAM}
+	if m.Extension != nil {
+		for i := 0; i < m.Extension.Banks(); i++ {
+			regions = append(regions, m.Extension.Bank(i))
+		}
+	}
+	for _, r := range regions {
+		data := make([]uint8, r.Size())
+		m.profile.Fill.Fill(data, rnd)
+		r.Load(0, data)
+	}
+}
+
+// MapDevice attaches a memory mapped device to a range of the I/O page
+func (m *TO770) MapDevice(from uint16, to uint16, h Handler) error {
+	if from < ioStart || to > ioEnd {
+		return fmt.Errorf("to770: range $%04x-$%04x outside of the I/O page", from, to)
+	}
+	return m.Bus.Map(Mapping{From: from, To: to, Priority: 1, Handler: h})
+}
+
+// SelectPlane is connected to the PIA output selecting the plane of the video
+// memory seen by the CPU: the form plane when high, the colour plane when low.
+func (m *TO770) SelectPlane(high bool) {
+	if high {
+		m.Video.Select(FormPlane)
+	} else {
+		m.Video.Select(ColorPlane)
+	}
+}
+
+/** Select the bank of the paged extension from the port B of the system PIA */
+func (m *TO770) selectExtensionBank(port uint8) {
+	if m.Extension == nil {
+		return
+	}
+	for bank, pattern := range extensionBanks {
+		if port&0xf8 == pattern && bank < m.Extension.Banks() {
+			m.Extension.Select(bank)
+			return
+		}
+	}
+}
+
+// Connect wires the MC6846 to a CPU: the timer follows the clock of the CPU, it is
+// ticked by the CPU at its time-outs and its IRQ output drives the IRQ line
+func (m *TO770) Connect(c *CPU) {
+	if m.cpu != nil {
+		m.cpu.RemoveDevice(m.System)
+	}
+	m.cpu = c
+	m.System.Clock = c.Clock
+	m.System.Reschedule = c.Reschedule
+	c.AddDevice(m.System)
+	m.System.IRQ = func(asserted bool) {
+		if asserted {
+			c.AssertIRQ()
+		} else {
+			c.ReleaseIRQ()
+		}
+	}
+}
+
+// RunCycles executes instructions on the connected CPU, see CPU.RunCycles
+func (m *TO770) RunCycles(budget uint64) (uint64, error) {
+	if m.cpu == nil {
+		return 0, errors.New("to770: no CPU connected")
+	}
+	return m.cpu.RunCycles(budget)
+}
+
 var (
-	Cpu CPU
-	Ram Memory
+	Cpu     CPU
+	Ram     Memory
+	Machine *TO770
 )
 
+// Start runs a TO7/70 with the default profile and a blank monitor ROM
 func Start() {
-	Ram = NewRam()
+	if err := StartProfile(DefaultProfile); err != nil {
+		panic(err)
+	}
+}
+
+// StartProfile runs a TO7/70 with the given profile
+func StartProfile(p Profile) error {
+	var err error
+	Machine, err = NewTO770(p)
+	if err != nil {
+		return err
+	}
+	Ram = Machine.Bus
 	Cpu.Initialize(Ram)
+	Machine.Connect(&Cpu)
+	Cpu.PowerOn()
+	return nil
 }
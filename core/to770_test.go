@@ -0,0 +1,161 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TO7/70 memory map", func() {
+
+	monitor := func() []uint8 {
+		rom := make([]uint8, 0x1800)
+		rom[0x17fe] = 0xf0 // reset vector $F000
+		return rom
+	}
+
+	It("should map the video memory and the RAM", func() {
+		m, err := NewTO770(Profile{RAMSize: RAM16K})
+		Expect(err).NotTo(HaveOccurred())
+		m.Bus.Write(0x4000, 0x11)
+		m.Bus.Write(0x6000, 0x22)
+		m.Bus.Write(0x9fff, 0x33)
+
+		Expect(m.Video.Bank(FormPlane).Get(0)).To(BeEquivalentTo(0x11)) // the port pins of the 6846 are pulled high at reset
+		Expect(m.RAM.Get(0)).To(BeEquivalentTo(0x22))
+		Expect(m.RAM.Get(0x3fff)).To(BeEquivalentTo(0x33))
+	})
+
+	It("should switch the video planes", func() {
+		m, _ := NewTO770(DefaultProfile)
+		m.SelectPlane(true)
+		m.Bus.Write(0x4000, 0xaa)
+		m.SelectPlane(false)
+		m.Bus.Write(0x4000, 0x47)
+
+		Expect(m.Bus.Read(0x4000)).To(BeEquivalentTo(0x47))
+		Expect(m.Video.Active()).To(Equal(ColorPlane))
+		Expect(m.Video.Bank(FormPlane).Get(0)).To(BeEquivalentTo(0xaa))
+		Expect(m.Video.Bank(ColorPlane).Get(0)).To(BeEquivalentTo(0x47))
+	})
+
+	It("should not map the RAM extension in the 16K configuration", func() {
+		m, _ := NewTO770(Profile{RAMSize: RAM16K})
+		m.Bus.Write(0xa000, 0x44)
+		m.Bus.Write(0x6000, 0x00)
+
+		Expect(m.Bus.Read(0xa000)).To(BeEquivalentTo(0x00))
+	})
+
+	It("should map the RAM extension in the 48K configuration", func() {
+		m, _ := NewTO770(Profile{RAMSize: RAM48K})
+		m.Bus.Write(0xdfff, 0x44)
+
+		Expect(m.RAM.Get(0x7fff)).To(BeEquivalentTo(0x44))
+	})
+
+	It("should page the banks of the RAM extension with the port B of the system PIA", func() {
+		m, err := NewTO770(Profile{RAMSize: RAM16K, Extension: 4})
+		Expect(err).NotTo(HaveOccurred())
+		m.Bus.Write(0xe7cb, 0x00) // data direction register B
+		m.Bus.Write(0xe7c9, 0xf8) // PB3-PB7 as outputs
+		m.Bus.Write(0xe7cb, 0x04) // data register B
+		for bank, pattern := range []uint8{0xf0, 0xe8, 0x18, 0x98} {
+			m.Bus.Write(0xe7c9, pattern)
+			m.Bus.Write(0xa000, uint8(0x10+bank))
+		}
+		m.Bus.Write(0xe7c9, 0xe8)
+		m.Bus.Write(0xe7c9, 0x58) // bank 4 is not installed
+		m.Bus.Write(0xe7c9, 0x00) // unknown pattern
+
+		Expect(m.Extension.Active()).To(Equal(1))
+		Expect(m.Bus.Read(0xa000)).To(BeEquivalentTo(0x11))
+		for bank := 0; bank < 4; bank++ {
+			Expect(m.Extension.Bank(bank).Get(0)).To(BeEquivalentTo(0x10 + bank))
+		}
+	})
+
+	It("should map the monitor and the cartridge ROM", func() {
+		m, _ := NewTO770(Profile{RAMSize: RAM16K, Monitor: monitor(), Cartridge: []uint8{0x12, 0x34}})
+		m.Bus.Write(0x0000, 0xff)
+		m.Bus.Write(0xfffe, 0xff)
+
+		Expect(m.Bus.Readw(0x0000)).To(BeEquivalentTo(0x1234))
+		Expect(m.Bus.Readw(0xfffe)).To(BeEquivalentTo(0xf000))
+	})
+
+	It("should attach the devices to the I/O page", func() {
+		m, _ := NewTO770(DefaultProfile)
+		device := &HandlerFuncs{ReadFunc: func(offset uint16) uint8 { return 0x80 | uint8(offset) }}
+
+		Expect(m.MapDevice(0xe7d0, 0xe7d3, device)).To(Succeed())
+		Expect(m.Bus.Read(0xe7d1)).To(BeEquivalentTo(0x81))
+		Expect(m.MapDevice(0xe7f0, 0xe800, device)).NotTo(Succeed())
+	})
+
+	It("should clear the RAM at power on by default", func() {
+		m, _ := NewTO770(DefaultProfile)
+
+		Expect(Search(m.Bus, 0x4000, 0x9fff, Pattern{0x00})).To(HaveLen(0x6000))
+	})
+
+	It("should fill the RAM with the DRAM pattern at power on", func() {
+		m, _ := NewTO770(Profile{RAMSize: RAM16K, Fill: PatternFill, Extension: 2})
+
+		Expect(m.RAM.Get(0x003f)).To(BeEquivalentTo(0x00))
+		Expect(m.RAM.Get(0x0040)).To(BeEquivalentTo(0xff))
+		Expect(m.RAM.Get(0x007f)).To(BeEquivalentTo(0xff))
+		Expect(m.RAM.Get(0x0080)).To(BeEquivalentTo(0x00))
+		Expect(m.Video.Bank(FormPlane).Get(0x0040)).To(BeEquivalentTo(0xff))
+		Expect(m.Extension.Bank(1).Get(0x0040)).To(BeEquivalentTo(0xff))
+	})
+
+	It("should fill the RAM with seeded random bytes at power on", func() {
+		p := Profile{RAMSize: RAM48K, Fill: RandomFill, Seed: 7070}
+		m1, _ := NewTO770(p)
+		m2, _ := NewTO770(p)
+		p.Seed++
+		m3, _ := NewTO770(p)
+
+		Expect(m1.RAM.Bytes()).To(Equal(m2.RAM.Bytes()))
+		Expect(m1.RAM.Bytes()).NotTo(Equal(m3.RAM.Bytes()))
+		Expect(m1.Video.Bank(ColorPlane).Bytes()).NotTo(Equal(m1.Video.Bank(FormPlane).Bytes()))
+	})
+
+	It("should fill a RAM with a power-on pattern", func() {
+		ram := NewRamFilled(PatternFill, 0)
+
+		Expect(Compare(CopyMemory(ram), CopyMemory(NewRam()))[0]).To(Equal(AddressRange{From: 0x0040, To: 0x007f}))
+		Expect(CopyMemory(NewRamFilled(RandomFill, 1))).To(Equal(CopyMemory(NewRamFilled(RandomFill, 1))))
+	})
+
+	It("should give the RAM its power-on content again", func() {
+		m, _ := NewTO770(Profile{RAMSize: RAM16K, Fill: RandomFill, Seed: 1})
+		before := CopyMemory(m.Bus)
+		Fill(m.Bus, 0x6000, 0x9fff, 0x55)
+		m.FillRAM()
+
+		Expect(Compare(before, CopyMemory(m.Bus))).To(BeEmpty())
+	})
+
+	It("should reject the invalid profiles", func() {
+		_, err := NewTO770(Profile{RAMSize: 32 * 1024})
+		Expect(err).To(HaveOccurred())
+		_, err = NewTO770(Profile{RAMSize: RAM16K, Monitor: make([]uint8, 0x1000)})
+		Expect(err).To(HaveOccurred())
+		_, err = NewTO770(Profile{RAMSize: RAM16K, Cartridge: make([]uint8, 0x4001)})
+		Expect(err).To(HaveOccurred())
+		_, err = NewTO770(Profile{RAMSize: RAM48K, Extension: 4})
+		Expect(err).To(HaveOccurred())
+		_, err = NewTO770(Profile{RAMSize: RAM16K, Extension: 7})
+		Expect(err).To(HaveOccurred())
+		_, err = NewTO770(Profile{RAMSize: RAM16K, Fill: RandomFill + 1})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should start the CPU on the TO7/70 memory", func() {
+		Start()
+
+		Expect(Ram).To(BeIdenticalTo(Machine.Bus))
+		ExpectPC(Cpu, 0xffff)
+	})
+})
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// AddressRange is a range of addresses, bounds included
+type AddressRange struct {
+	From uint16
+	To   uint16
+}
+
+// Tracer is a Hook writing one line per executed instruction. A line starts with
+// the address and the disassembly of the instruction in the syntax of the MAME
+// debugger trace command ("E01A: LDA   #$12") and is followed by the optional
+// columns: instruction bytes, registers after execution and clock when the
+// instruction started. Traces can be compared to a MAME trace run with the noloop
+// option once the optional columns are disabled.
+type Tracer struct {
+	/// Instructions are traced when their address is in one of the ranges, all of them if empty
+	Ranges []AddressRange
+	/// Instructions starting before this clock are not traced
+	FromClock uint64
+	/// Instructions starting at or after this clock are not traced, no limit if 0
+	ToClock uint64
+	/// The instruction bytes are written
+	Bytes bool
+	/// The registers after execution are written
+	Registers bool
+	/// The clock when the instruction started is written
+	Clock bool
+	/// Output
+	w io.Writer
+	/// Bytes of the current instruction, fetched before it is executed
+	inst [6]uint8
+	/// First write error
+	err error
+}
+
+// NewTracer returns a tracer writing all the instructions with all the columns
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{w: w, Bytes: true, Registers: true, Clock: true}
+}
+
+// Err returns the first error returned by the writer. Nothing is written after an
+// error.
+func (t *Tracer) Err() error {
+	return t.err
+}
+
+/** Check the filters */
+func (t *Tracer) traced(event InstructionEvent) bool {
+	if event.Clock < t.FromClock || (t.ToClock != 0 && event.Clock >= t.ToClock) {
+		return false
+	}
+	if len(t.Ranges) == 0 {
+		return true
+	}
+	for _, r := range t.Ranges {
+		if event.PC >= r.From && event.PC <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// BeforeInstruction keeps the instruction bytes before the instruction can modify
+// them. The bytes are peeked so that the devices and the watchpoints do not see
+// the reads, the bytes which cannot be peeked being kept as $FF.
+func (t *Tracer) BeforeInstruction(c *CPU, event InstructionEvent) {
+	if !t.traced(event) {
+		return
+	}
+	for i := range t.inst {
+		value, ok := peek(c.ram, event.PC+uint16(i))
+		if !ok {
+			value = 0xff
+		}
+		t.inst[i] = value
+	}
+}
+
+// AfterInstruction writes the trace line
+func (t *Tracer) AfterInstruction(c *CPU, event InstructionEvent) {
+	if t.err != nil || !t.traced(event) {
+		return
+	}
+	op, ok := c.opcodes.lookup(event.Opcode)
+	if !ok {
+		op, _ = c.undocumentedOpcode(event.Opcode)
+	}
+	prefix := 0
+	if event.Opcode > 0xff {
+		prefix = 1
+	}
+	instruction, size := disassemble(op, event.PC+uint16(prefix), t.inst[prefix:], &mameSyntax)
+	size += prefix
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%04X: ", event.PC))
+	if t.Bytes || t.Registers || t.Clock {
+		sb.WriteString(fmt.Sprintf("%-24s", instruction))
+	} else {
+		sb.WriteString(instruction)
+	}
+	if t.Bytes {
+		hexa := make([]string, size)
+		for i := range hexa {
+			hexa[i] = fmt.Sprintf("%02X", t.inst[i])
+		}
+		sb.WriteString(fmt.Sprintf(" %-14s", strings.Join(hexa, " ")))
+	}
+	if t.Registers {
+		sb.WriteString(" ")
+		sb.WriteString(c.Registers().String())
+	}
+	if t.Clock {
+		sb.WriteString(fmt.Sprintf(" clock=%d", event.Clock))
+	}
+	sb.WriteString("\n")
+	_, t.err = io.WriteString(t.w, sb.String())
+}
+
+// MemoryAccess does nothing
+func (t *Tracer) MemoryAccess(c *CPU, access Access) {
+}
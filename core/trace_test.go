@@ -0,0 +1,120 @@
+package core
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracer", func() {
+	var (
+		cpu    *CPU
+		out    *strings.Builder
+		tracer *Tracer
+	)
+
+	load := func(address uint16, bytes ...uint8) {
+		for i, b := range bytes {
+			cpu.write(address+uint16(i), b)
+		}
+	}
+
+	BeforeEach(func() {
+		cpu = NewCPU(NewRam(), MC6809)
+		cpu.pc.set(0x1000)
+		cpu.s.set(0x8000)
+		out = &strings.Builder{}
+		tracer = NewTracer(out)
+		cpu.AddHook(tracer)
+	})
+
+	It("should write the instructions with the MAME syntax", func() {
+		tracer.Bytes, tracer.Registers, tracer.Clock = false, false, false
+		load(0x1000,
+			0x8e, 0x20, 0x00, // LDX #$2000
+			0xa6, 0x80, // LDA ,X+
+			0xa7, 0x3f, // STA -1,Y
+			0xe6, 0xb8, 0xf0, // LDB [-$10,Y]
+			0x34, 0x16, // PSHS X,B,A
+			0x35, 0x16, // PULS A,B,X
+			0x1f, 0x89, // TFR A,B
+			0x12,                   // NOP
+			0x10, 0x26, 0x00, 0x00, // LBNE $1015
+			0x26, 0xe9, // BNE $1000
+		)
+		cpu.y.set(0x3000)
+		for i := 0; i < 10; i++ {
+			cpu.step()
+		}
+
+		Expect(out.String()).To(Equal(strings.Join([]string{
+			"1000: LDX   #$2000",
+			"1003: LDA   ,X+",
+			"1005: STA   -$1,Y",
+			"1007: LDB   [-$10,Y]",
+			"100A: PSHS  X,B,A",
+			"100C: PULS  A,B,X",
+			"100E: TFR   A,B",
+			"1010: NOP",
+			"1011: LBNE  $1015",
+			"1015: BNE   $1000",
+			"",
+		}, "\n")))
+	})
+
+	It("should write the bytes, the registers and the clock", func() {
+		load(0x1000, 0x86, 0x2f) // LDA #$2f
+		cpu.clock = 100
+		cpu.step()
+
+		Expect(out.String()).To(Equal("1000: LDA   #$2F" + strings.Repeat(" ", 15) + "86 2F" + strings.Repeat(" ", 10) +
+			"A=2f B=00 X=0000 Y=0000 U=0000 S=8000 DP=00 CC=-------- PC=1002 clock=100\n"))
+	})
+
+	It("should not read the instruction bytes through the memory", func() {
+		ram := NewRam()
+		cpu = NewCPU(ram, MC6809)
+		cpu.pc.set(0x1000)
+		watch := NewWatch(ram)
+		watch.Watchpoints = []Watchpoint{{Range: AddressRange{From: 0x1004, To: 0x1005}, Kinds: ReadAccess}}
+		watch.Attach(cpu)
+		cpu.AddHook(tracer)
+		for a := uint16(0x1000); a < 0x1010; a++ {
+			ram.Write(a, 0x12) // NOP
+		}
+		cycles, err := cpu.RunCycles(4)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cycles).To(BeEquivalentTo(4))
+		Expect(watch.Hit).To(BeNil())
+		Expect(strings.Count(out.String(), "NOP")).To(Equal(2))
+	})
+
+	It("should only trace the instructions in the address ranges", func() {
+		tracer.Ranges = []AddressRange{{0x1001, 0x1001}, {0x1003, 0x1004}}
+		load(0x1000, 0x12, 0x12, 0x12, 0x12, 0x12, 0x12)
+		for i := 0; i < 6; i++ {
+			cpu.step()
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[0]).To(HavePrefix("1001: NOP"))
+		Expect(lines[1]).To(HavePrefix("1003: NOP"))
+		Expect(lines[2]).To(HavePrefix("1004: NOP"))
+	})
+
+	It("should only trace the instructions in the clock window", func() {
+		tracer.FromClock, tracer.ToClock = 4, 8
+		load(0x1000, 0x12, 0x12, 0x12, 0x12, 0x12, 0x12)
+		for i := 0; i < 6; i++ {
+			cpu.step()
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(HavePrefix("1002: NOP"))
+		Expect(lines[1]).To(HaveSuffix("clock=6"))
+	})
+})
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IllegalOpcodePolicy defines how the CPU behaves when it fetches an opcode which is
+// not documented in the MC6809 datasheet. The policy does not apply to the HD6309,
+// which always takes its illegal instruction trap.
+type IllegalOpcodePolicy int
+
+const (
+	// TrapIllegal stops the execution with an IllegalInstruction error
+	TrapIllegal IllegalOpcodePolicy = 0
+	// EmulateUndocumented executes the undocumented opcodes the way the MC6809
+	// silicon does. Opcodes with no known behaviour still trap.
+	EmulateUndocumented IllegalOpcodePolicy = 1
+)
+
+// IllegalInstruction is the error returned when an undefined opcode is fetched
+type IllegalInstruction struct {
+	/// Address of the instruction
+	PC uint16
+	/// Opcode bytes, including the page prefix
+	Bytes []uint8
+	/// Clock when the opcode was fetched
+	Clock uint64
+}
+
+func (e *IllegalInstruction) Error() string {
+	hexa := []string{}
+	for _, x := range e.Bytes {
+		hexa = append(hexa, fmt.Sprintf("%02x", x))
+	}
+	return fmt.Sprintf("illegal instruction %s at pc=%04x", strings.Join(hexa, " "), e.PC)
+}
+
+/** Error of an undefined opcode, from the bytes already fetched */
+func (c *CPU) illegalInstruction(pc uint16, code int) error {
+	bytes := []uint8{uint8(code)}
+	if code > 0xff {
+		bytes = []uint8{uint8(code >> 8), uint8(code)}
+	}
+	return &IllegalInstruction{PC: pc, Bytes: bytes, Clock: c.clock}
+}
+
+// undocumentedOpcode returns the silicon behaviour of an undefined opcode. Undefined
+// page 1 and page 2 opcodes execute as their page 0 counterpart, the prefix costing
+// one extra cycle.
+func (c *CPU) undocumentedOpcode(code int) (opcode, bool) {
+	op, ok := c.undocumented.lookup(code)
+	if ok || code <= 0xff {
+		return op, ok
+	}
+	op, ok = c.opcodes.lookup(code & 0xff)
+	if !ok {
+		op, ok = c.undocumented.lookup(code & 0xff)
+	}
+	op.cycles++
+	return op, ok
+}
+
+func (c *CPU) initUndocumentedOpcodes() {
+	c.undocumented = new(opcodeTable)
+	for _, in := range undocumentedInstructions {
+		c.undocumented.set(in.code, c.bind(in))
+	}
+}
+
+/** Negate or Complement - NEG when C = 0, COM when C = 1 */
+func (c *CPU) xnc_(value int) int {
+	if c.cc.getC() {
+		return c.com_(value)
+	}
+	return c.neg_(value)
+}
+
+/** Negate or Complement - NEG when C = 0, COM when C = 1 */
+func (c *CPU) xnc(address uint16) {
+	c.writeInt(address, c.xnc_(c.readInt(address)))
+}
+
+/** Negate or Complement Register A - NEG when C = 0, COM when C = 1 */
+func (c *CPU) xnca() {
+	c.a.set(c.xnc_(c.a.get()))
+}
+
+/** Negate or Complement Register B - NEG when C = 0, COM when C = 1 */
+func (c *CPU) xncb() {
+	c.b.set(c.xnc_(c.b.get()))
+}
+
+/** Reset Interrupt - stack the entire state and jump through the reset vector */
+func (c *CPU) xres() {
+	c.pushEntireState()
+	c.cc.setF()
+	c.cc.setI()
+	c.pc.set(int(c.readw(vectorReset)))
+}
+
+/** Halt and Catch Fire - the CPU is locked until the next reset */
+func (c *CPU) hcf() {
+	c.state = halted
+}
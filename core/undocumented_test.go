@@ -0,0 +1,142 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Undocumented opcodes", func() {
+	var (
+		cpu CPU
+	)
+
+	BeforeEach(func() {
+		cpu = CPU{}
+		ram := NewRam()
+		cpu.Initialize(ram)
+		cpu.pc.set(0x1000)
+	})
+
+	Context("[Trap]", func() {
+
+		It("should return an IllegalInstruction error", func() {
+			cpu.write(0x1000, 0x01)
+			_, err := cpu.step()
+
+			Expect(err).To(HaveOccurred())
+			illegal, ok := err.(*IllegalInstruction)
+			Expect(ok).To(BeTrue())
+			Expect(illegal.PC).To(BeEquivalentTo(0x1000))
+			Expect(illegal.Bytes).To(Equal([]uint8{0x01}))
+			ExpectPC(cpu, 0x1000)
+			ExpectClock(cpu, 0)
+		})
+
+		It("should report the page prefix", func() {
+			cpu.writew(0x1000, 0x1086)
+			_, err := cpu.step()
+
+			Expect(err).To(MatchError("illegal instruction 10 86 at pc=1000"))
+			ExpectPC(cpu, 0x1000)
+		})
+
+		It("should not fetch the illegal opcode again", func() {
+			cpu.writew(0x1000, 0x1086)
+			cpu.CycleExact = true
+			result, err := cpu.Step()
+
+			Expect(err).To(MatchError("illegal instruction 10 86 at pc=1000"))
+			Expect(result.Accesses).To(Equal([]Access{
+				{PC: 0x1000, Opcode: -1, Address: 0x1000, Value: 0x10, Cycle: 0},
+				{PC: 0x1000, Opcode: -1, Address: 0x1001, Value: 0x86, Cycle: 1},
+			}))
+		})
+	})
+
+	Context("[Emulate]", func() {
+
+		BeforeEach(func() {
+			cpu.IllegalOpcodes = EmulateUndocumented
+		})
+
+		It("should execute $01 as NEG direct", func() {
+			cpu.dp.set(0x20)
+			cpu.write(0x1000, 0x01)
+			cpu.write(0x1001, 0x0a)
+			cpu.write(0x200a, 0x60)
+			_, err := cpu.step()
+
+			Expect(err).NotTo(HaveOccurred())
+			ExpectMemory(cpu, 0x200a, 0xa0)
+			ExpectPC(cpu, 0x1002)
+			ExpectClock(cpu, 6)
+		})
+
+		It("should execute $42 as NEGA when C is clear and COMA when C is set", func() {
+			cpu.write(0x1000, 0x42)
+			cpu.write(0x1001, 0x42)
+			cpu.a.set(0x5d)
+			cpu.step()
+			ExpectA(cpu, 0xa3)
+			ExpectCCR(cpu, "C", "")
+
+			cpu.step()
+			ExpectA(cpu, 0x5c)
+		})
+
+		It("should execute $87 as STA immediate", func() {
+			cpu.write(0x1000, 0x87)
+			cpu.a.set(0x80)
+			cpu.step()
+
+			ExpectMemory(cpu, 0x1001, 0x80)
+			ExpectPC(cpu, 0x1002)
+			ExpectCCR(cpu, "N", "ZV")
+		})
+
+		It("should execute $3E as a reset interrupt", func() {
+			cpu.s.set(0x2000)
+			cpu.writew(0xfffe, 0xf000)
+			cpu.write(0x1000, 0x3e)
+			cpu.step()
+
+			ExpectWord(cpu, 0x2000-2, 0x1001)
+			ExpectS(cpu, 0x2000-12)
+			ExpectPC(cpu, 0xf000)
+			ExpectClock(cpu, 19)
+			ExpectCCR(cpu, "EFI", "")
+		})
+
+		It("should execute undefined page 1 opcodes as page 0", func() {
+			cpu.writew(0x1000, 0x1086) // LDA #$2f
+			cpu.write(0x1002, 0x2f)
+			cpu.step()
+
+			ExpectA(cpu, 0x2f)
+			ExpectPC(cpu, 0x1003)
+			ExpectClock(cpu, 3)
+		})
+
+		It("should lock the CPU on HCF until reset", func() {
+			cpu.writew(0xfffe, 0x1000)
+			cpu.write(0x1000, 0x14)
+			cpu.step()
+			cpu.AssertNMI()
+			cpu.step()
+			ExpectPC(cpu, 0x1001)
+
+			cpu.AssertReset()
+			cpu.ReleaseReset()
+			cpu.step()
+			ExpectPC(cpu, 0x1000)
+		})
+
+		It("should still trap opcodes with no known behaviour", func() {
+			cpu.writew(0x1000, 0x1010)
+			cpu.write(0x1002, 0x10)
+			_, err := cpu.step()
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,282 @@
+package core
+
+import (
+	"fmt"
+	"io"
+)
+
+// AccessKind is the kind of a memory access recorded by a Watch. The kinds are
+// bit flags which can be combined in a Watchpoint.
+type AccessKind int
+
+const (
+	// ReadAccess is a data read
+	ReadAccess AccessKind = 1 << iota
+	// WriteAccess is a write
+	WriteAccess
+	// FetchAccess is the read of an opcode, page prefix included
+	FetchAccess
+)
+
+func (k AccessKind) String() string {
+	switch k {
+	case ReadAccess:
+		return "R"
+	case WriteAccess:
+		return "W"
+	case FetchAccess:
+		return "X"
+	}
+	return fmt.Sprintf("AccessKind(%d)", int(k))
+}
+
+// WatchRecord is a memory access recorded by a Watch
+type WatchRecord struct {
+	/// Kind of access
+	Kind AccessKind
+	/// Accessed address
+	Address uint16
+	/// Value read or written
+	Value uint8
+	/// Address of the instruction which performed the access
+	PC uint16
+	/// Clock when the instruction started, or cycle of the access in cycle exact
+	/// mode
+	Cycle uint64
+}
+
+// Watchpoint stops the execution when an address of its range is accessed
+type Watchpoint struct {
+	/// Watched addresses
+	Range AddressRange
+	/// Kinds of access triggering the watchpoint
+	Kinds AccessKind
+}
+
+// Watch is a Memory wrapping the memory of a CPU to record the accesses per
+// address range, stop the execution on watchpoints and stream an access log. The
+// accesses are attributed to the instruction being executed, including the
+// accesses made by the devices and the hooks, and the dead cycles of the cycle
+// exact mode are recorded as reads of $FFFF.
+//
+// A read watchpoint or a write watchpoint stops RunCycles and RunUntil after the
+// instruction performing the access. An execute watchpoint stops them before the
+// instruction at a watched address is executed, the first instruction after a
+// reset or of an interrupt handler included. The instruction is executed when the
+// execution resumes.
+type Watch struct {
+	/// Accesses are recorded and logged when their address is in one of the
+	/// ranges, all of them if empty
+	Ranges []AddressRange
+	/// The recorded accesses are appended to Records
+	Record bool
+	/// Recorded accesses
+	Records []WatchRecord
+	/// Watchpoints
+	Watchpoints []Watchpoint
+	/// Last access which triggered a watchpoint, nil if none
+	Hit *WatchRecord
+	/// Wrapped memory
+	memory Memory
+	/// CPU using the watch, nil when detached
+	cpu *CPU
+	/// Access log, nil if disabled
+	log io.Writer
+	/// First write error of the log
+	err error
+	/// Address of the instruction being executed
+	pc uint16
+	/// The last opcode fetch read a page prefix
+	prefix bool
+	/// The instruction being fetched triggers an execute watchpoint
+	breaking bool
+	/// Last execute watchpoint hit, not triggered again when the execution resumes
+	resume WatchRecord
+}
+
+// NewWatch returns a watch wrapping a memory
+func NewWatch(memory Memory) *Watch {
+	return &Watch{memory: memory}
+}
+
+// Attach makes a CPU use the watch as its memory
+func (w *Watch) Attach(c *CPU) {
+	w.cpu = c
+	w.pc = c.PC()
+	c.setMemory(w)
+	c.AddHook(w)
+}
+
+// Detach gives the wrapped memory back to the CPU
+func (w *Watch) Detach() {
+	if w.cpu == nil {
+		return
+	}
+	w.cpu.RemoveHook(w)
+	w.cpu.setMemory(w.memory)
+	w.cpu = nil
+}
+
+// Log streams the recorded accesses to a writer, one line per access: cycle, PC,
+// kind, address and value ("1234 E01A W 4000 2F"). A nil writer stops the log.
+func (w *Watch) Log(out io.Writer) {
+	w.log = out
+	w.err = nil
+}
+
+// Err returns the first error returned by the log writer. Nothing is logged after
+// an error.
+func (w *Watch) Err() error {
+	return w.err
+}
+
+/** Check the ranges */
+func (w *Watch) recorded(address uint16) bool {
+	if len(w.Ranges) == 0 {
+		return true
+	}
+	for _, r := range w.Ranges {
+		if address >= r.From && address <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+/** Check the watchpoints of a kind of access */
+func (w *Watch) watched(kind AccessKind, address uint16) bool {
+	for _, wp := range w.Watchpoints {
+		if wp.Kinds&kind != 0 && address >= wp.Range.From && address <= wp.Range.To {
+			return true
+		}
+	}
+	return false
+}
+
+/** Record an access */
+func (w *Watch) access(kind AccessKind, cycle uint64, address uint16, value uint8) {
+	if kind == ReadAccess && w.fetching(address) {
+		kind = FetchAccess
+		if !w.prefix {
+			w.pc = address
+			w.breaking = w.breaks(address)
+		}
+		w.prefix = !w.prefix && (value == 0x10 || value == 0x11)
+		if w.breaking {
+			return // the fetch is recorded when the execution resumes
+		}
+	}
+	record := WatchRecord{Kind: kind, Address: address, Value: value, PC: w.pc, Cycle: cycle}
+	if kind != FetchAccess && w.watched(kind, address) {
+		w.Hit = &record
+		if w.cpu != nil {
+			w.cpu.Stop()
+		}
+	}
+	if !w.recorded(address) {
+		return
+	}
+	if w.Record {
+		w.Records = append(w.Records, record)
+	}
+	if w.log != nil && w.err == nil {
+		_, w.err = fmt.Fprintf(w.log, "%d %04X %s %04X %02X\n", cycle, w.pc, kind, address, value)
+	}
+}
+
+/** An execute watchpoint stops the CPU before the instruction at an address,
+ * unless the execution resumes from it */
+func (w *Watch) breaks(address uint16) bool {
+	resuming := w.resume.Kind == FetchAccess && w.resume.Address == address && w.resume.Cycle == w.clock()
+	return !resuming && w.watched(FetchAccess, address)
+}
+
+/** The CPU reads the opcode at the program counter before the instruction starts */
+func (w *Watch) fetching(address uint16) bool {
+	return w.cpu != nil && w.cpu.op == -1 && address == w.cpu.PC()
+}
+
+/** Cycle of an access outside of the cycle exact mode */
+func (w *Watch) clock() uint64 {
+	if w.cpu == nil {
+		return 0
+	}
+	return w.cpu.Clock()
+}
+
+func (w *Watch) Read(address uint16) uint8 {
+	value := w.memory.Read(address)
+	w.access(ReadAccess, w.clock(), address, value)
+	return value
+}
+
+func (w *Watch) Write(address uint16, value uint8) {
+	w.memory.Write(address, value)
+	w.access(WriteAccess, w.clock(), address, value)
+}
+
+func (w *Watch) Readw(address uint16) uint16 {
+	hi := w.Read(address)
+	lo := w.Read(address + 1)
+	return uint16(hi)<<8 | uint16(lo)
+}
+
+func (w *Watch) Writew(address uint16, value uint16) {
+	w.Write(address, uint8(value>>8))
+	w.Write(address+1, uint8(value&0xff))
+}
+
+// ReadCycle reads an address in cycle exact mode
+func (w *Watch) ReadCycle(cycle uint64, address uint16) uint8 {
+	var value uint8
+	if timed, ok := w.memory.(CycleMemory); ok {
+		value = timed.ReadCycle(cycle, address)
+	} else {
+		value = w.memory.Read(address)
+	}
+	w.access(ReadAccess, cycle, address, value)
+	return value
+}
+
+// WriteCycle writes an address in cycle exact mode
+func (w *Watch) WriteCycle(cycle uint64, address uint16, value uint8) {
+	if timed, ok := w.memory.(CycleMemory); ok {
+		timed.WriteCycle(cycle, address, value)
+	} else {
+		w.memory.Write(address, value)
+	}
+	w.access(WriteAccess, cycle, address, value)
+}
+
+// Peek reads the wrapped memory without recording the access
+func (w *Watch) Peek(address uint16) (uint8, bool) {
+	return peek(w.memory, address)
+}
+
+func (w *Watch) Dump() {
+	w.memory.Dump()
+}
+
+// BeforeInstruction attributes the following accesses to the instruction and
+// stops the CPU before the instructions at the addresses of the execute
+// watchpoints
+func (w *Watch) BeforeInstruction(c *CPU, event InstructionEvent) {
+	w.pc = event.PC
+	if !w.breaking {
+		return
+	}
+	w.breaking = false
+	value, _ := peek(w.memory, event.PC)
+	w.Hit = &WatchRecord{Kind: FetchAccess, Address: event.PC, Value: value, PC: event.PC, Cycle: event.Clock}
+	w.resume = *w.Hit
+	c.Stop()
+}
+
+// AfterInstruction does nothing: the execute watchpoints are checked before the
+// instructions
+func (w *Watch) AfterInstruction(c *CPU, event InstructionEvent) {
+}
+
+// MemoryAccess does nothing: the accesses are recorded by the memory
+func (w *Watch) MemoryAccess(c *CPU, access Access) {
+}
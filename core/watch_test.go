@@ -0,0 +1,180 @@
+package core
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory watch", func() {
+	var (
+		cpu   *CPU
+		ram   Memory
+		watch *Watch
+	)
+
+	// Loop storing an increasing value
+	//
+	//	1000: LDA $2000
+	//	1003: INCA
+	//	1004: STA $2000
+	//	1007: LDY #$0000
+	//	100b: BRA $1000
+	program := []uint8{0xb6, 0x20, 0x00, 0x4c, 0xb7, 0x20, 0x00, 0x10, 0x8e, 0x00, 0x00, 0x20, 0xf3}
+
+	BeforeEach(func() {
+		ram = NewRam()
+		for i, b := range program {
+			ram.Write(0x1000+uint16(i), b)
+		}
+		cpu = NewCPU(ram, MC6809)
+		cpu.pc.set(0x1000)
+		watch = NewWatch(ram)
+		watch.Attach(cpu)
+	})
+
+	It("should record the accesses with their instruction", func() {
+		watch.Record = true
+		cpu.step()
+		cpu.step()
+		cpu.step()
+
+		Expect(watch.Records).To(Equal([]WatchRecord{
+			{Kind: FetchAccess, Address: 0x1000, Value: 0xb6, PC: 0x1000, Cycle: 0},
+			{Kind: ReadAccess, Address: 0x1001, Value: 0x20, PC: 0x1000, Cycle: 0},
+			{Kind: ReadAccess, Address: 0x1002, Value: 0x00, PC: 0x1000, Cycle: 0},
+			{Kind: ReadAccess, Address: 0x2000, Value: 0x00, PC: 0x1000, Cycle: 0},
+			{Kind: FetchAccess, Address: 0x1003, Value: 0x4c, PC: 0x1003, Cycle: 5},
+			{Kind: FetchAccess, Address: 0x1004, Value: 0xb7, PC: 0x1004, Cycle: 7},
+			{Kind: ReadAccess, Address: 0x1005, Value: 0x20, PC: 0x1004, Cycle: 7},
+			{Kind: ReadAccess, Address: 0x1006, Value: 0x00, PC: 0x1004, Cycle: 7},
+			{Kind: WriteAccess, Address: 0x2000, Value: 0x01, PC: 0x1004, Cycle: 7},
+		}))
+	})
+
+	It("should record the page prefix as an opcode fetch", func() {
+		cpu.pc.set(0x1007)
+		watch.Record = true
+		watch.Ranges = []AddressRange{{From: 0x1007, To: 0x1008}}
+		cpu.step()
+
+		Expect(watch.Records).To(Equal([]WatchRecord{
+			{Kind: FetchAccess, Address: 0x1007, Value: 0x10, PC: 0x1007},
+			{Kind: FetchAccess, Address: 0x1008, Value: 0x8e, PC: 0x1007},
+		}))
+	})
+
+	It("should record the cycle of the accesses in cycle exact mode", func() {
+		cpu.CycleExact = true
+		watch.Record = true
+		watch.Ranges = []AddressRange{{From: 0x2000, To: 0x2000}}
+		cpu.step()
+
+		Expect(watch.Records).To(Equal([]WatchRecord{
+			{Kind: ReadAccess, Address: 0x2000, Value: 0x00, PC: 0x1000, Cycle: 4},
+		}))
+	})
+
+	It("should stop after the instruction writing a watched address", func() {
+		watch.Watchpoints = []Watchpoint{{Range: AddressRange{From: 0x2000, To: 0x2000}, Kinds: WriteAccess}}
+		cpu.RunCycles(1000)
+
+		ExpectPC(*cpu, 0x1007)
+		Expect(*watch.Hit).To(Equal(WatchRecord{Kind: WriteAccess, Address: 0x2000, Value: 0x01, PC: 0x1004, Cycle: 7}))
+	})
+
+	It("should stop before executing a watched address", func() {
+		watch.Watchpoints = []Watchpoint{{Range: AddressRange{From: 0x1003, To: 0x1003}, Kinds: FetchAccess}}
+		cpu.RunCycles(1000)
+		ExpectPC(*cpu, 0x1003)
+		ExpectA(*cpu, 0x00)
+
+		cpu.RunCycles(1000)
+		ExpectPC(*cpu, 0x1003)
+		ExpectA(*cpu, 0x01)
+	})
+
+	It("should stop before the first instruction of an interrupt handler", func() {
+		ram.Write(0x3000, 0x4c) // INCA
+		ram.Writew(0xfff8, 0x3000)
+		watch.Record = true
+		watch.Watchpoints = []Watchpoint{{Range: AddressRange{From: 0x3000, To: 0x3000}, Kinds: FetchAccess}}
+		cpu.s.set(0x8000)
+		cpu.AssertIRQ()
+		cpu.RunCycles(1000)
+
+		ExpectPC(*cpu, 0x3000)
+		ExpectA(*cpu, 0x00)
+		Expect(*watch.Hit).To(Equal(WatchRecord{Kind: FetchAccess, Address: 0x3000, Value: 0x4c, PC: 0x3000, Cycle: 19}))
+		Expect(watch.Records).NotTo(ContainElement(WatchRecord{Kind: FetchAccess, Address: 0x3000, Value: 0x4c, PC: 0x3000, Cycle: 19}))
+
+		cpu.ReleaseIRQ()
+		cpu.Step()
+		ExpectA(*cpu, 0x01)
+		Expect(watch.Records).To(ContainElement(WatchRecord{Kind: FetchAccess, Address: 0x3000, Value: 0x4c, PC: 0x3000, Cycle: 19}))
+	})
+
+	It("should stop before the first instruction after a reset", func() {
+		ram.Writew(0xfffe, 0x1003)
+		watch.Watchpoints = []Watchpoint{{Range: AddressRange{From: 0x1003, To: 0x1003}, Kinds: FetchAccess}}
+		cpu.Reset()
+		cpu.RunCycles(1000)
+
+		ExpectPC(*cpu, 0x1003)
+		ExpectA(*cpu, 0x00)
+		Expect(cpu.Clock()).To(BeZero())
+	})
+
+	It("should execute the instructions stepped after a watchpoint hit", func() {
+		watch.Watchpoints = []Watchpoint{{Range: AddressRange{From: 0x2000, To: 0x2000}, Kinds: WriteAccess}}
+		cpu.Step()
+		cpu.Step()
+		cpu.Step()
+		Expect(watch.Hit).NotTo(BeNil())
+		cpu.Step()
+
+		ExpectPC(*cpu, 0x100b)
+	})
+
+	It("should not stop the execution resumed after a watchpoint hit by a step", func() {
+		watch.Watchpoints = []Watchpoint{{Range: AddressRange{From: 0x1003, To: 0x1003}, Kinds: FetchAccess}}
+		cpu.Step()
+		cpu.Step()
+		Expect(watch.Hit).NotTo(BeNil())
+		cycles, _ := cpu.RunCycles(10)
+
+		Expect(cycles).To(BeNumerically(">=", 10))
+	})
+
+	It("should keep a stop requested before a step", func() {
+		cpu.Stop()
+		cpu.Step()
+		cycles, _ := cpu.RunCycles(10)
+
+		Expect(cycles).To(BeZero())
+		ExpectPC(*cpu, 0x1003)
+	})
+
+	It("should stream the access log", func() {
+		var sb strings.Builder
+		watch.Log(&sb)
+		watch.Ranges = []AddressRange{{From: 0x2000, To: 0x2000}}
+		cpu.step()
+		cpu.step()
+		cpu.step()
+
+		Expect(sb.String()).To(Equal("0 1000 R 2000 00\n7 1004 W 2000 01\n"))
+		Expect(watch.Err()).NotTo(HaveOccurred())
+	})
+
+	It("should give the memory back when detached", func() {
+		watch.Record = true
+		watch.Detach()
+		cpu.step()
+
+		Expect(watch.Records).To(BeEmpty())
+		Expect(cpu.ram).To(BeIdenticalTo(ram))
+		Expect(cpu.hooks).To(BeNil())
+	})
+})
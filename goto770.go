@@ -12,8 +12,13 @@ import (
 	"github.com/jcsirot/goto770/core"
 )
 
+var (
+	monitor   = flag.String("monitor", "", "monitor ROM image")
+	cartridge = flag.String("cartridge", "", "cartridge ROM image")
+)
+
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: example -stderrthreshold=[INFO|WARN|FATAL] -log_dir=[string]\n")
+	fmt.Fprintf(os.Stderr, "usage: example -stderrthreshold=[INFO|WARN|FATAL] -log_dir=[string] -monitor=[file] -cartridge=[file]\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -29,7 +34,17 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		core.Start()
+		if *monitor == "" {
+			core.Start()
+			return
+		}
+		profile := core.DefaultProfile
+		if err := profile.LoadROMs(*monitor, *cartridge); err != nil {
+			log.Fatalln(err)
+		}
+		if err := core.StartProfile(profile); err != nil {
+			log.Fatalln(err)
+		}
 	}()
 	wg.Wait()
 	runtime.LockOSThread()